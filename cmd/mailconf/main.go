@@ -6,16 +6,37 @@ import (
 	"log"
 
 	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/checkcmd"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/configcmd"
+	"github.com/gianz74/mailconf/internal/exportcmd"
+	"github.com/gianz74/mailconf/internal/filtercmd"
 	"github.com/gianz74/mailconf/internal/help"
+	"github.com/gianz74/mailconf/internal/importcmd"
+	"github.com/gianz74/mailconf/internal/indexcmd"
+	"github.com/gianz74/mailconf/internal/maildir"
 	"github.com/gianz74/mailconf/internal/os"
 	"github.com/gianz74/mailconf/internal/profile"
 	"github.com/gianz74/mailconf/internal/setup"
+	"github.com/gianz74/mailconf/internal/template"
+	"github.com/gianz74/mailconf/internal/templates"
 )
 
+var configLocation string
+
 func init() {
+	flag.StringVar(&configLocation, "config", "", "Config set name, or directory holding the config (default $MAILCONF_CONFIG).")
 	base.Commands = []*base.Command{
 		setup.CmdSetup,
 		profile.CmdProfile,
+		template.CmdTemplate,
+		configcmd.CmdConfig,
+		exportcmd.CmdExport,
+		importcmd.CmdImport,
+		indexcmd.CmdIndex,
+		maildir.CmdMaildir,
+		filtercmd.CmdFilter,
+		checkcmd.CmdCheck,
 	}
 	base.Usage = mainUsage
 }
@@ -31,11 +52,27 @@ func main() {
 		base.Usage()
 	}
 
+	if configLocation == "" {
+		configLocation = os.Getenv("MAILCONF_CONFIG")
+	}
+	if err := config.SetLocation(configLocation); err != nil {
+		fmt.Fprintf(os.Stderr, "mailconf: %v\n", err)
+		os.Exit(2)
+	}
+
 	if args[0] == "help" {
 		help.Help(base.Commands, args[1:], []*base.Command{})
 		return
 	}
 
+	// broken overrides must not prevent restoring the defaults.
+	if args[0] != template.CmdTemplate.Name() {
+		if err := templates.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "mailconf: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	for _, cmd := range base.Commands {
 		cmd.Flag.Usage = cmd.Usage
 		if cmd.Name() == args[0] {
@@ -0,0 +1,116 @@
+// Package adopt reads hand-written mbsync, msmtp, goimapnotify and
+// mu4e configurations into profiles, so that existing setups can be
+// taken over by mailconf.
+package adopt
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+)
+
+// Result collects the profiles read from one or more files, and what
+// could not be mapped to them.
+type Result struct {
+	// MaildirRoot is the maildir root of the configuration the
+	// profiles are imported to; empty means ~/Maildir.
+	MaildirRoot string
+	Profiles    []*config.Profile
+	// Unmapped describes, one line each, the settings that were not
+	// imported.
+	Unmapped []string
+}
+
+// profile returns the profile called name or, failing that, the first
+// one for which match returns true. If there is none, a new profile
+// called name is added.
+func (r *Result) profile(name string, match func(*config.Profile) bool) *config.Profile {
+	for _, p := range r.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	if match != nil {
+		for _, p := range r.Profiles {
+			if match(p) {
+				return p
+			}
+		}
+	}
+	p := &config.Profile{Name: name}
+	r.Profiles = append(r.Profiles, p)
+	return p
+}
+
+func (r *Result) unmapped(file string, line int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if line > 0 {
+		r.Unmapped = append(r.Unmapped, fmt.Sprintf("%s:%d: %s", file, line, msg))
+		return
+	}
+	r.Unmapped = append(r.Unmapped, fmt.Sprintf("%s: %s", file, msg))
+}
+
+// Finish fills in what the files read did not set and that mailconf
+// can guess, and reports what is still missing.
+func (r *Result) Finish() {
+	for _, p := range r.Profiles {
+		if p.ImapPort == 0 && p.ImapHost != "" {
+			p.ImapPort = 993
+		}
+		if p.SmtpPort == 0 && p.SmtpHost != "" {
+			p.SmtpPort = 587
+		}
+		if p.Email == "" && isAddress(p.ImapUser) {
+			p.Email = p.ImapUser
+		}
+		if p.SmtpUser == "" && p.SmtpHost != "" {
+			p.SmtpUser = p.ImapUser
+		}
+		if reflect.DeepEqual(p.Folders, config.DefaultFolders()) {
+			p.Folders = nil
+		}
+		var missing []string
+		for _, f := range []struct {
+			name  string
+			value string
+		}{
+			{"email", p.Email},
+			{"full name", p.FullName},
+			{"imap host", p.ImapHost},
+			{"smtp host", p.SmtpHost},
+		} {
+			if f.value == "" {
+				missing = append(missing, f.name)
+			}
+		}
+		if len(missing) > 0 {
+			r.Unmapped = append(r.Unmapped, fmt.Sprintf("profile %s: no %s found", p.Name, strings.Join(missing, ", ")))
+		}
+	}
+}
+
+func isAddress(s string) bool {
+	_, err := mail.ParseAddress(s)
+	return err == nil
+}
+
+// unquote strips the double quotes around s, if any.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// keyValue splits a configuration line into its keyword and value.
+func keyValue(line string) (string, string) {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i+1:])
+}
@@ -0,0 +1,135 @@
+package adopt
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+func TestAdopt(t *testing.T) {
+	r := &Result{}
+	for _, read := range []struct {
+		fn   func(string) error
+		file string
+	}{
+		{r.Mbsyncrc, "testdata/mbsyncrc"},
+		{r.Goimapnotify, "testdata/imapnotify/Personal/notify.conf"},
+		{r.Msmtprc, "testdata/msmtprc"},
+		{r.Mu4e, "testdata/mu4e.el"},
+	} {
+		err := read.fn(read.file)
+		if err != nil {
+			t.Fatalf("cannot read %s: %v", read.file, err)
+		}
+	}
+	r.Finish()
+
+	want := []*config.Profile{
+		{
+			Name:     "Work",
+			Email:    "jdoe@gmail.com",
+			FullName: "John Doe",
+			ImapHost: "imap.gmail.com",
+			ImapPort: 993,
+			ImapUser: "jdoe@gmail.com",
+			SmtpHost: "smtp.gmail.com",
+			SmtpPort: 587,
+			SmtpUser: "jdoe@gmail.com",
+			Folders: []*config.Folder{
+				{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+				{Name: "archive", Remote: "[Gmail]/All Mail", Local: "archive", Patterns: []string{"*", "!Old Stuff"}, MaxMessages: 1000},
+			},
+		},
+		{
+			Name:     "Personal",
+			Email:    "john@example.org",
+			FullName: `John "Johnny" Doe`,
+			ImapHost: "imap.example.org",
+			ImapPort: 1993,
+			ImapUser: "john",
+			SmtpHost: "smtp.example.org",
+			SmtpPort: 465,
+			SmtpUser: "john",
+			Folders: []*config.Folder{
+				{Name: "personal", Remote: "INBOX", Local: "INBOX"},
+			},
+			Mbsync: &config.Mbsync{SSLType: "STARTTLS"},
+		},
+	}
+	if !reflect.DeepEqual(r.Profiles, want) {
+		for i, p := range r.Profiles {
+			t.Logf("profile %d: %+v", i, *p)
+		}
+		t.Fatalf("profiles differ")
+	}
+
+	unmapped := []string{
+		"testdata/mbsyncrc:2: global option Create Near not imported",
+		"testdata/mbsyncrc:7: PassCmd not imported",
+		"testdata/mbsyncrc:47: MaildirStore personal-local: mail in ~/mail/personal/ must be moved to ~/Maildir/Personal/",
+		"testdata/imapnotify/Personal/notify.conf: boxes",
+		"testdata/imapnotify/Personal/notify.conf: onNewMail not imported",
+		"testdata/imapnotify/Personal/notify.conf: passwordCmd not imported",
+		"testdata/msmtprc:10: account work: passwordeval not imported",
+		"testdata/msmtprc:16: account personal: maildomain example.org not imported",
+		"testdata/mu4e.el: context work: mu4e-sent-folder not imported",
+	}
+	if len(r.Unmapped) != len(unmapped) {
+		t.Fatalf("got unmapped:\n%s\nwant:\n%s", strings.Join(r.Unmapped, "\n"), strings.Join(unmapped, "\n"))
+	}
+	for i, msg := range unmapped {
+		if !strings.HasPrefix(r.Unmapped[i], msg) {
+			t.Fatalf("got: %s, want: %s...", r.Unmapped[i], msg)
+		}
+	}
+}
+
+func TestFinish(t *testing.T) {
+	r := &Result{
+		Profiles: []*config.Profile{
+			{
+				Name:     "Work",
+				ImapHost: "imap.gmail.com",
+				ImapUser: "jdoe@gmail.com",
+				Folders:  config.DefaultFolders(),
+			},
+		},
+	}
+	r.Finish()
+	p := r.Profiles[0]
+	if p.ImapPort != 993 || p.Email != "jdoe@gmail.com" || p.Folders != nil {
+		t.Fatalf("defaults not filled in: %+v", *p)
+	}
+	want := "profile Work: no full name, smtp host found"
+	if len(r.Unmapped) != 1 || r.Unmapped[0] != want {
+		t.Fatalf("got: %v, want: %s", r.Unmapped, want)
+	}
+}
+
+func TestMbsyncrcMaildirRoot(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Result{MaildirRoot: path.Join(home, "mail")}
+	err = r.Mbsyncrc("testdata/mbsyncrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range r.Profiles {
+		if p.Name == "Personal" && p.Maildir != path.Join(home, "mail", "personal") {
+			t.Fatalf("got maildir %q, want: %s", p.Maildir, path.Join(home, "mail", "personal"))
+		}
+	}
+	want := "testdata/mbsyncrc:16: MaildirStore Work-local: mail in ~/Maildir/Work/ must be moved to ~/mail/Work/"
+	for _, msg := range r.Unmapped {
+		if msg == want {
+			return
+		}
+	}
+	t.Fatalf("got unmapped:\n%s\nwant: %s", strings.Join(r.Unmapped, "\n"), want)
+}
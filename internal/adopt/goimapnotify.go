@@ -0,0 +1,75 @@
+package adopt
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// Goimapnotify reads a goimapnotify JSON configuration into the imap
+// settings of a profile. The profile is matched by imap host and user,
+// or else named after the directory holding the file, as in
+// ~/.config/imapnotify/<profile>/notify.conf.
+func (r *Result) Goimapnotify(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var conf map[string]interface{}
+	err = json.Unmarshal(data, &conf)
+	if err != nil {
+		return fmt.Errorf("%s: not a goimapnotify config: %w", file, err)
+	}
+
+	str := func(key string) string {
+		s, _ := conf[key].(string)
+		return s
+	}
+	host, user := str("host"), str("username")
+	name := path.Base(path.Dir(file))
+	if name == "imapnotify" || name == "." || name == "/" {
+		name = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+	p := r.profile(name, func(p *config.Profile) bool {
+		return p.ImapHost == host && p.ImapUser == user
+	})
+
+	keys := make([]string, 0, len(conf))
+	for key := range conf {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "host":
+			p.ImapHost = host
+		case "port":
+			port, ok := conf[key].(float64)
+			if !ok || port < 1 || port > 65535 {
+				r.unmapped(file, 0, "invalid port %v", conf[key])
+				continue
+			}
+			p.ImapPort = uint16(port)
+		case "username":
+			p.ImapUser = user
+		case "password", "passwordCmd":
+			r.unmapped(file, 0, "%s not imported: mailconf reads the password from the credentials store", key)
+		case "onNewMail", "onNewMailPost":
+			r.unmapped(file, 0, "%s not imported: mailconf runs mbsync and the indexer", key)
+		case "boxes":
+			boxes, _ := conf[key].([]interface{})
+			if len(boxes) != 1 || boxes[0] != "INBOX" {
+				r.unmapped(file, 0, "boxes %v not imported: mailconf watches INBOX", conf[key])
+			}
+		case "tls", "tlsOptions":
+		default:
+			r.unmapped(file, 0, "%s not imported", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package adopt
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// section is a block of an mbsyncrc, opened by one of the keywords in
+// sectionKeys.
+type section struct {
+	kind    string
+	name    string
+	line    int
+	entries []entry
+}
+
+type entry struct {
+	key   string
+	value string
+	line  int
+}
+
+var sectionKeys = map[string]bool{
+	"IMAPAccount":  true,
+	"IMAPStore":    true,
+	"MaildirStore": true,
+	"Channel":      true,
+	"Group":        true,
+}
+
+// Mbsyncrc reads the IMAPAccount, IMAPStore, MaildirStore and Channel
+// sections of an mbsync configuration: every account becomes a profile
+// and every channel a folder of the profile owning its far store.
+func (r *Result) Mbsyncrc(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	sections := parseMbsyncrc(string(data), func(line int, key, value string) {
+		r.unmapped(file, line, "global option %s %s not imported", key, value)
+	})
+
+	// accounts first, as stores and channels refer to them.
+	for _, s := range sections {
+		if s.kind == "IMAPAccount" {
+			r.imapAccount(file, s)
+		}
+	}
+	stores := make(map[string]*config.Profile)
+	maildirs := make(map[string]*section)
+	for _, s := range sections {
+		switch s.kind {
+		case "IMAPStore":
+			r.imapStore(file, s, stores)
+		case "MaildirStore":
+			maildirs[s.name] = s
+		}
+	}
+	for _, s := range sections {
+		if s.kind == "Channel" {
+			r.channel(file, s, stores, maildirs)
+		}
+	}
+	return nil
+}
+
+func parseMbsyncrc(data string, global func(line int, key, value string)) []*section {
+	var sections []*section
+	var cur *section
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			cur = nil
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		key, value := keyValue(line)
+		// every pattern can be quoted on its own.
+		if key != "Patterns" {
+			value = unquote(value)
+		}
+		inGroup := cur != nil && cur.kind == "Group" && (key == "Channel" || key == "Channels")
+		if sectionKeys[key] && !inGroup {
+			cur = &section{kind: key, name: value, line: i + 1}
+			sections = append(sections, cur)
+			continue
+		}
+		if cur == nil {
+			if key != "SyncState" {
+				global(i+1, key, value)
+			}
+			continue
+		}
+		cur.entries = append(cur.entries, entry{key, value, i + 1})
+	}
+	return sections
+}
+
+func (r *Result) imapAccount(file string, s *section) {
+	p := r.profile(s.name, nil)
+	for _, e := range s.entries {
+		switch e.key {
+		case "Host":
+			p.ImapHost = e.value
+		case "Port":
+			port, err := strconv.ParseUint(e.value, 10, 16)
+			if err != nil {
+				r.unmapped(file, e.line, "invalid port %q", e.value)
+				continue
+			}
+			p.ImapPort = uint16(port)
+		case "User":
+			p.ImapUser = e.value
+		case "Pass", "PassCmd", "UseKeychain":
+			r.unmapped(file, e.line, "%s not imported: mailconf reads the password from the credentials store", e.key)
+		case "SSLType", "TLSType":
+			switch e.value {
+			case "IMAPS":
+			case "STARTTLS", "None":
+				mbsync(p).SSLType = e.value
+			default:
+				r.unmapped(file, e.line, "%s %s not imported", e.key, e.value)
+			}
+		case "CertificateFile":
+			mbsync(p).CertificateFile = expandHome(e.value)
+		case "PipelineDepth":
+			depth, err := strconv.Atoi(e.value)
+			if err != nil || depth < 0 {
+				r.unmapped(file, e.line, "invalid pipeline depth %q", e.value)
+				continue
+			}
+			mbsync(p).PipelineDepth = depth
+		case "AuthMechs":
+			if e.value != "LOGIN" {
+				r.unmapped(file, e.line, "AuthMechs %s not imported: mailconf always uses LOGIN", e.value)
+			}
+		default:
+			r.unmapped(file, e.line, "%s %s not imported", e.key, e.value)
+		}
+	}
+}
+
+func (r *Result) imapStore(file string, s *section, stores map[string]*config.Profile) {
+	for _, e := range s.entries {
+		if e.key != "Account" {
+			r.unmapped(file, e.line, "%s %s not imported", e.key, e.value)
+			continue
+		}
+		for _, p := range r.Profiles {
+			if p.Name == e.value {
+				stores[s.name] = p
+			}
+		}
+	}
+	if stores[s.name] == nil {
+		r.unmapped(file, s.line, "IMAPStore %s has no known Account", s.name)
+	}
+}
+
+func (r *Result) channel(file string, s *section, stores map[string]*config.Profile, maildirs map[string]*section) {
+	var p *config.Profile
+	var local string
+	f := &config.Folder{}
+	for _, e := range s.entries {
+		switch e.key {
+		case "Master", "Far":
+			store, box := splitBox(e.value)
+			p = stores[store]
+			if p == nil {
+				r.unmapped(file, e.line, "channel %s: unknown store %s", s.name, store)
+				return
+			}
+			f.Remote = box
+		case "Slave", "Near":
+			local, f.Local = splitBox(e.value)
+		case "Expunge":
+			f.Expunge = e.value
+		case "Create":
+			switch e.value {
+			case "Near", "Slave":
+			case "Master":
+				f.Create = "Far"
+			default:
+				f.Create = e.value
+			}
+		case "Sync":
+			if e.value != "All" {
+				f.Sync = e.value
+			}
+		case "MaxMessages":
+			max, err := strconv.Atoi(e.value)
+			if err != nil || max < 0 {
+				r.unmapped(file, e.line, "channel %s: invalid MaxMessages %q", s.name, e.value)
+				continue
+			}
+			f.MaxMessages = max
+		case "CopyArrivalDate":
+			f.CopyArrivalDate = e.value == "yes"
+		case "Patterns":
+			f.Patterns = splitPatterns(e.value)
+		default:
+			r.unmapped(file, e.line, "channel %s: %s %s not imported", s.name, e.key, e.value)
+		}
+	}
+	if p == nil {
+		r.unmapped(file, s.line, "channel %s has no far side", s.name)
+		return
+	}
+	if f.Remote == "" && f.Patterns == nil {
+		f.Remote = "INBOX"
+	}
+	if f.Local == "" && f.Patterns == nil {
+		f.Local = "INBOX"
+	}
+	f.Name = strings.TrimPrefix(s.name, p.Name+"-")
+	p.Folders = append(p.Folders, f)
+
+	if m, ok := maildirs[local]; ok {
+		for _, e := range m.entries {
+			switch e.key {
+			case "Path":
+				r.maildir(file, e.line, local, p, e.value)
+			case "MaxSize":
+				mbsync(p).MaxSize = e.value
+			}
+		}
+		delete(maildirs, local)
+	}
+}
+
+// maildir sets the maildir of p to the Path of its MaildirStore, which
+// must be in the maildir root: mail anywhere else has to be moved.
+func (r *Result) maildir(file string, line int, store string, p *config.Profile, value string) {
+	home, _ := os.UserHomeDir()
+	tilde := func(dir string) string {
+		if strings.HasPrefix(dir, home+"/") {
+			return "~" + strings.TrimPrefix(dir, home)
+		}
+		return dir
+	}
+	dir := path.Clean(expandHome(value))
+	cfg := &config.Config{MaildirRoot: r.MaildirRoot}
+	named := cfg.MaildirPath(&config.Profile{Name: p.Name})
+	switch {
+	case dir == named:
+	case strings.HasPrefix(dir, cfg.MaildirRootPath()+"/"):
+		p.Maildir = dir
+	default:
+		r.unmapped(file, line, "MaildirStore %s: mail in %s must be moved to %s/", store, value, tilde(named))
+	}
+}
+
+// splitBox splits a channel side, ":store:mailbox", into the store and
+// the mailbox.
+func splitBox(s string) (string, string) {
+	s = strings.TrimPrefix(s, ":")
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// mbsync returns the mbsync options of p, adding them if missing.
+func mbsync(p *config.Profile) *config.Mbsync {
+	if p.Mbsync == nil {
+		p.Mbsync = &config.Mbsync{}
+	}
+	return p.Mbsync
+}
+
+// expandHome replaces a leading "~/" in file with the home directory.
+func expandHome(file string) string {
+	if !strings.HasPrefix(file, "~/") {
+		return file
+	}
+	home, _ := os.UserHomeDir()
+	return path.Join(home, file[2:])
+}
+
+// splitPatterns splits the value of Patterns, where every pattern can
+// be quoted.
+func splitPatterns(value string) []string {
+	var patterns []string
+	var cur strings.Builder
+	quoted, started := false, false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if started {
+				patterns = append(patterns, cur.String())
+			}
+			cur.Reset()
+			started = false
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		patterns = append(patterns, cur.String())
+	}
+	return patterns
+}
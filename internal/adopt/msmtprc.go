@@ -0,0 +1,103 @@
+package adopt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// Msmtprc reads the accounts of an msmtp configuration into the smtp
+// settings of the profiles. An account is matched to a profile by
+// name, then by its from address, then by its user name.
+func (r *Result) Msmtprc(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	type account struct {
+		name    string
+		line    int
+		entries []entry
+	}
+	accounts := make(map[string]*account)
+	var order []*account
+	var defaults []entry
+	var cur *account
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value := keyValue(line)
+		value = unquote(value)
+		switch key {
+		case "defaults":
+			cur = nil
+			continue
+		case "account":
+			name, parent := value, ""
+			if j := strings.Index(value, ":"); j >= 0 {
+				name, parent = strings.TrimSpace(value[:j]), strings.TrimSpace(value[j+1:])
+			}
+			if name == "default" {
+				cur = nil
+				continue
+			}
+			cur = &account{name: name, line: i + 1}
+			cur.entries = append(cur.entries, defaults...)
+			for _, p := range strings.Split(parent, ",") {
+				if a, ok := accounts[strings.TrimSpace(p)]; ok {
+					cur.entries = append(cur.entries, a.entries...)
+				}
+			}
+			accounts[name] = cur
+			order = append(order, cur)
+			continue
+		}
+		e := entry{key, value, i + 1}
+		if cur == nil {
+			defaults = append(defaults, e)
+			continue
+		}
+		cur.entries = append(cur.entries, e)
+	}
+
+	for _, a := range order {
+		values := make(map[string]entry)
+		for _, e := range a.entries {
+			values[e.key] = e
+		}
+		from, user := values["from"].value, values["user"].value
+		p := r.profile(a.name, func(p *config.Profile) bool {
+			return (from != "" && strings.EqualFold(p.Email, from)) || (user != "" && p.ImapUser == user)
+		})
+		for _, e := range a.entries {
+			switch e.key {
+			case "host":
+				p.SmtpHost = e.value
+			case "port":
+				port, err := strconv.ParseUint(e.value, 10, 16)
+				if err != nil {
+					r.unmapped(file, e.line, "invalid port %q", e.value)
+					continue
+				}
+				p.SmtpPort = uint16(port)
+			case "user":
+				p.SmtpUser = e.value
+			case "from":
+				if p.Email == "" {
+					p.Email = e.value
+				}
+			case "password", "passwordeval":
+				r.unmapped(file, e.line, "account %s: %s not imported: mailconf reads the password from the credentials store", a.name, e.key)
+			case "auth", "tls", "tls_starttls", "tls_trust_file", "logfile", "syslog":
+			default:
+				r.unmapped(file, e.line, "account %s: %s %s not imported", a.name, e.key, e.value)
+			}
+		}
+	}
+	return nil
+}
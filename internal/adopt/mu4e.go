@@ -0,0 +1,74 @@
+package adopt
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var (
+	contextName = regexp.MustCompile(`:name\s+"([^"]*)"`)
+	contextVar  = regexp.MustCompile(`\(\s*([a-z0-9-]+)\s*\.\s*("(?:[^"\\]|\\.)*"|[0-9]+)\s*\)`)
+)
+
+// Mu4e reads the make-mu4e-context forms of an emacs configuration into
+// the identity and smtp settings of the profiles. A context is matched
+// to a profile by name, then by its user-mail-address.
+func (r *Result) Mu4e(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	contexts := strings.Split(string(data), "(make-mu4e-context")
+	for _, ctx := range contexts[1:] {
+		m := contextName.FindStringSubmatch(ctx)
+		if m == nil {
+			r.unmapped(file, 0, "mu4e context without a name not imported")
+			continue
+		}
+		vars := make(map[string]string)
+		for _, v := range contextVar.FindAllStringSubmatch(ctx, -1) {
+			vars[v[1]] = v[2]
+			if s, err := strconv.Unquote(v[2]); err == nil {
+				vars[v[1]] = s
+			}
+		}
+		email := vars["user-mail-address"]
+		p := r.profile(m[1], func(p *config.Profile) bool {
+			return email != "" && strings.EqualFold(p.Email, email)
+		})
+		keys := make([]string, 0, len(vars))
+		for key := range vars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := vars[key]
+			switch key {
+			case "user-mail-address":
+				p.Email = value
+			case "user-full-name":
+				p.FullName = value
+			case "smtpmail-smtp-user":
+				p.SmtpUser = value
+			case "smtpmail-smtp-server":
+				p.SmtpHost = value
+			case "smtpmail-smtp-service":
+				port, err := strconv.ParseUint(value, 10, 16)
+				if err != nil {
+					r.unmapped(file, 0, "context %s: invalid smtp port %q", m[1], value)
+					continue
+				}
+				p.SmtpPort = uint16(port)
+			default:
+				r.unmapped(file, 0, "context %s: %s not imported", m[1], key)
+			}
+		}
+	}
+	return nil
+}
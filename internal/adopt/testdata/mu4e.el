@@ -0,0 +1,13 @@
+(setq mu4e-contexts
+      (list
+       (make-mu4e-context
+        :name "work"
+        :match-func (lambda (msg) t)
+        :vars '((user-mail-address . "jdoe@gmail.com")
+                (user-full-name . "John Doe")
+                (mu4e-sent-folder . "/Work/sent")))
+       (make-mu4e-context
+        :name "Personal"
+        :vars '((user-mail-address . "john@example.org")
+                (user-full-name . "John \"Johnny\" Doe")
+                (smtpmail-smtp-service . 465)))))
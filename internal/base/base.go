@@ -35,3 +35,7 @@ func (c *Command) Usage() {
 }
 
 var Usage func()
+
+// Version is the version of mailconf, set at link time with
+// -ldflags "-X github.com/gianz74/mailconf/internal/base.Version=...".
+var Version = "devel"
@@ -0,0 +1,161 @@
+// Package bundle reads and writes the portable files produced by
+// "mailconf export" and consumed by "mailconf import".
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the version of the bundle format.
+const Version = 1
+
+var (
+	ErrVersion    = errors.New("bundle written by a newer mailconf")
+	ErrPassphrase = errors.New("wrong passphrase or corrupted credentials")
+	ErrNoCreds    = errors.New("bundle has no credentials")
+)
+
+// Bundle holds a set of profiles and, optionally, their credentials
+// encrypted with a passphrase.
+type Bundle struct {
+	Version     int               `json:"version"`
+	Profiles    []*config.Profile `json:"profiles"`
+	Credentials *Sealed           `json:"credentials,omitempty"`
+}
+
+// Sealed is a list of Credential encrypted with AES-GCM under a key
+// derived from the passphrase with scrypt.
+type Sealed struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Credential is a password kept in the credentials store.
+type Credential struct {
+	Service  string `json:"service"`
+	User     string `json:"user"`
+	Host     string `json:"host"`
+	Port     uint16 `json:"port"`
+	Password string `json:"password"`
+}
+
+func New(profiles []*config.Profile) *Bundle {
+	return &Bundle{
+		Version:  Version,
+		Profiles: profiles,
+	}
+}
+
+// DropFolders leaves the folder maps of the profiles out of the
+// bundle, so that the importing machine uses its default folder map.
+// The profiles passed to New are not changed.
+func (b *Bundle) DropFolders() {
+	profiles := make([]*config.Profile, 0, len(b.Profiles))
+	for _, p := range b.Profiles {
+		cp := p.Copy()
+		cp.Folders = nil
+		profiles = append(profiles, cp)
+	}
+	b.Profiles = profiles
+}
+
+// Read reads the bundle in file.
+func Read(file string) (*Bundle, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	err = json.Unmarshal(data, &b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a mailconf bundle: %w", file, err)
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("%s: version %d: %w", file, b.Version, ErrVersion)
+	}
+	return &b, nil
+}
+
+// Marshal returns the bundle encoded as JSON.
+func (b *Bundle) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Seal encrypts creds with passphrase and stores them in the bundle.
+func (b *Bundle) Seal(creds []*Credential, passphrase string) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+	b.Credentials = &Sealed{
+		Salt:  salt,
+		Nonce: nonce,
+		Data:  aead.Seal(nil, nonce, plain, nil),
+	}
+	return nil
+}
+
+// Open decrypts the credentials in the bundle with passphrase. It
+// returns ErrNoCreds if the bundle was exported without credentials.
+func (b *Bundle) Open(passphrase string) ([]*Credential, error) {
+	if b.Credentials == nil {
+		return nil, ErrNoCreds
+	}
+	aead, err := newAEAD(passphrase, b.Credentials.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Credentials.Nonce) != aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	plain, err := aead.Open(nil, b.Credentials.Nonce, b.Credentials.Data, nil)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	var creds []*Credential
+	err = json.Unmarshal(plain, &creds)
+	if err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,120 @@
+package bundle
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+var work = &config.Profile{
+	Name:     "Work",
+	FullName: "John Doe",
+	Email:    "jdoe@gmail.com",
+	ImapHost: "imap.gmail.com",
+	ImapPort: 993,
+	ImapUser: "user@gmail.com",
+	SmtpHost: "smtp.gmail.com",
+	SmtpPort: 587,
+	SmtpUser: "user@gmail.com",
+	Folders: []*config.Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+	},
+}
+
+var creds = []*Credential{
+	{"imap", "user@gmail.com", "imap.gmail.com", 993, "imapsecret"},
+	{"smtp", "user@gmail.com", "smtp.gmail.com", 587, "smtpsecret"},
+}
+
+func TestBundle(t *testing.T) {
+	tt := []struct {
+		name   string
+		creds  []*Credential
+		seal   string
+		open   string
+		err    error
+		expect []*Credential
+	}{
+		{
+			"no credentials",
+			nil,
+			"",
+			"secret",
+			ErrNoCreds,
+			nil,
+		},
+		{
+			"credentials",
+			creds,
+			"secret",
+			"secret",
+			nil,
+			creds,
+		},
+		{
+			"wrong passphrase",
+			creds,
+			"secret",
+			"guess",
+			ErrPassphrase,
+			nil,
+		},
+	}
+	for _, tc := range tt {
+		os.Set(&afero.Afero{Fs: afero.NewMemMapFs()})
+		b := New([]*config.Profile{work})
+		if tc.seal != "" {
+			err := b.Seal(tc.creds, tc.seal)
+			if err != nil {
+				t.Fatalf("%s: cannot seal credentials: %v", tc.name, err)
+			}
+		}
+		data, err := b.Marshal()
+		if err != nil {
+			t.Fatalf("%s: cannot marshal bundle: %v", tc.name, err)
+		}
+		os.WriteFile("/tmp/bundle.json", data, 0600)
+
+		got, err := Read("/tmp/bundle.json")
+		if err != nil {
+			t.Fatalf("%s: cannot read bundle: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got.Profiles, b.Profiles) {
+			t.Fatalf("%s: got profiles %+v, want: %+v", tc.name, got.Profiles, b.Profiles)
+		}
+		opened, err := got.Open(tc.open)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if !reflect.DeepEqual(opened, tc.expect) {
+			t.Fatalf("%s: got credentials %+v, want: %+v", tc.name, opened, tc.expect)
+		}
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	os.Set(&afero.Afero{Fs: afero.NewMemMapFs()})
+	os.WriteFile("/tmp/bundle.json", []byte(`{"version": 1000, "profiles": []}`), 0600)
+	_, err := Read("/tmp/bundle.json")
+	if !errors.Is(err, ErrVersion) {
+		t.Fatalf("got error %v, want: %v", err, ErrVersion)
+	}
+}
+
+func TestDropFolders(t *testing.T) {
+	b := New([]*config.Profile{work})
+	b.DropFolders()
+	if got := b.Profiles[0].Folders; got != nil {
+		t.Fatalf("got folders %+v, want none", got)
+	}
+	if b.Profiles[0].Name != work.Name || b.Profiles[0].ImapHost != work.ImapHost {
+		t.Fatalf("got profile %+v, want: %+v without folders", b.Profiles[0], work)
+	}
+	if len(work.Folders) != 1 {
+		t.Fatalf("exported profile changed: %+v", work.Folders)
+	}
+}
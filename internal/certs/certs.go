@@ -0,0 +1,184 @@
+// Package certs manages the certificates file of imapfilter, holding
+// the server certificates imapfilter accepts even if they cannot be
+// verified, in the format imapfilter writes: the subject, issuer and
+// serial number of every certificate followed by its PEM encoding.
+// mailconf adds the server every certificate was trusted for, which
+// imapfilter ignores like the other lines before the certificate.
+package certs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// File returns the path of the certificates file.
+func File() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".imapfilter/certificates"), nil
+}
+
+// Entry is a certificate of the certificates file with the server,
+// "host:port", it was trusted for. Server is empty for the certificates
+// imapfilter stored itself, after asking the user.
+type Entry struct {
+	Server string
+	Cert   *x509.Certificate
+}
+
+// Server returns the name of the server at host and port in the
+// entries.
+func Server(host string, port uint16) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// serverField precedes the server of an entry, among the lines that
+// imapfilter ignores before the PEM encoding of the certificate.
+const serverField = "Server: "
+
+// Read returns the entries of the certificates file, none if it does
+// not exist.
+func Read() ([]*Entry, error) {
+	file, err := File()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for {
+		// the lines before the certificate describe it.
+		var server string
+		if i := bytes.Index(data, []byte("-----BEGIN ")); i >= 0 {
+			for _, line := range strings.Split(string(data[:i]), "\n") {
+				if strings.HasPrefix(line, serverField) {
+					server = strings.TrimSpace(strings.TrimPrefix(line, serverField))
+				}
+			}
+		}
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		entries = append(entries, &Entry{Server: server, Cert: cert})
+	}
+	return entries, nil
+}
+
+// Write replaces the certificates file with entries.
+func Write(entries []*Entry) error {
+	file, err := File()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, e := range entries {
+		b.Write(Format(e))
+	}
+	return io.Write(file, b.Bytes(), 0600)
+}
+
+// Format returns e as imapfilter writes it in the certificates file,
+// preceded by the server it was trusted for.
+func Format(e *Entry) []byte {
+	var b bytes.Buffer
+	if e.Server != "" {
+		fmt.Fprintf(&b, "%s%s\n", serverField, e.Server)
+	}
+	fmt.Fprintf(&b, "Subject: %s\n", oneline(e.Cert.Subject))
+	fmt.Fprintf(&b, "Issuer: %s\n", oneline(e.Cert.Issuer))
+	fmt.Fprintf(&b, "Serial: %X\n", e.Cert.SerialNumber.Bytes())
+	pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: e.Cert.Raw})
+	return b.Bytes()
+}
+
+// Fingerprint returns the SHA-256 fingerprint of cert, as colon
+// separated hex bytes.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// For returns the entries trusted for server. Certificates are matched
+// on the server they were trusted for, not on the names they are valid
+// for: the certificates file is meant for the ones that cannot be
+// verified, such as self-signed ones.
+func For(entries []*Entry, server string) []*Entry {
+	var found []*Entry
+	for _, e := range entries {
+		if e.Server == server {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Remove returns entries without the ones trusted for server.
+func Remove(entries []*Entry, server string) []*Entry {
+	var left []*Entry
+	for _, e := range entries {
+		if e.Server != server {
+			left = append(left, e)
+		}
+	}
+	return left
+}
+
+// names are the short names of the attributes of a distinguished name,
+// as printed by OpenSSL.
+var names = map[string]string{
+	"2.5.4.3":  "CN",
+	"2.5.4.5":  "serialNumber",
+	"2.5.4.6":  "C",
+	"2.5.4.7":  "L",
+	"2.5.4.8":  "ST",
+	"2.5.4.9":  "street",
+	"2.5.4.10": "O",
+	"2.5.4.11": "OU",
+}
+
+// oneline returns name in the OpenSSL one line format, such as
+// "/C=US/O=Google Trust Services/CN=GTS CA 1O1".
+func oneline(name pkix.Name) string {
+	var b strings.Builder
+	for _, attr := range name.Names {
+		key, ok := names[attr.Type.String()]
+		if !ok {
+			key = attr.Type.String()
+		}
+		fmt.Fprintf(&b, "/%s=%v", key, attr.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+func newCertificate(t *testing.T, serial int64, hosts ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{Country: []string{"US"}, Organization: []string{"Example"}, CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCertificates(t *testing.T) {
+	oldFs := os.Set(&afero.Afero{Fs: afero.NewMemMapFs()})
+	defer os.Set(oldFs)
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+
+	stored, err := Read()
+	if err != nil || stored != nil {
+		t.Fatalf("got %v (%v) without a certificates file, want none", stored, err)
+	}
+
+	gmail := newCertificate(t, 0x1f, "imap.gmail.com")
+	example := newCertificate(t, 2, "mx.internal")
+	own := newCertificate(t, 3, "imap.own.org")
+	err = Write([]*Entry{
+		{Server: Server("imap.gmail.com", 993), Cert: gmail},
+		{Server: Server("imap.example.com", 143), Cert: example},
+	})
+	if err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+	data, _ := os.ReadFile("/home/user/.imapfilter/certificates")
+	header := "Server: imap.gmail.com:993\nSubject: /C=US/O=Example/CN=imap.gmail.com\nIssuer: /C=US/O=Example/CN=imap.gmail.com\nSerial: 1F\n-----BEGIN CERTIFICATE-----\n"
+	if !strings.HasPrefix(string(data), header) {
+		t.Fatalf("got certificates file:\n%s\nwant it to start with:\n%s", data, header)
+	}
+	// an entry stored by imapfilter itself.
+	os.WriteFile("/home/user/.imapfilter/certificates", append(data, Format(&Entry{Cert: own})...), 0600)
+	stored, err = Read()
+	if err != nil || len(stored) != 3 {
+		t.Fatalf("got %d certificates (%v), want the three written", len(stored), err)
+	}
+	if stored[0].Server != "imap.gmail.com:993" || !stored[0].Cert.Equal(gmail) ||
+		stored[1].Server != "imap.example.com:143" || !stored[1].Cert.Equal(example) ||
+		stored[2].Server != "" || !stored[2].Cert.Equal(own) {
+		t.Fatalf("got entries %+v", stored)
+	}
+
+	// certificates are matched on the server, even if not valid for it.
+	if found := For(stored, "imap.example.com:143"); len(found) != 1 || !found[0].Cert.Equal(example) {
+		t.Fatalf("got %d certificates for imap.example.com:143, want: 1", len(found))
+	}
+	if found := For(stored, "imap.example.com:993"); len(found) != 0 {
+		t.Fatalf("got %d certificates for imap.example.com:993, want: none", len(found))
+	}
+	if left := Remove(stored, "imap.example.com:143"); len(left) != 2 || left[0] != stored[0] || left[1] != stored[2] {
+		t.Fatalf("got %d certificates left, want the ones of the other servers", len(left))
+	}
+	if fp := Fingerprint(gmail); len(fp) != 95 || strings.Count(fp, ":") != 31 {
+		t.Fatalf("got fingerprint %s, want 32 hex bytes", fp)
+	}
+}
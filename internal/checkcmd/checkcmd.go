@@ -0,0 +1,69 @@
+package checkcmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdCheck = &base.Command{
+	UsageLine: "check [-dry-run -v]",
+	Short:     "check updates the server certificates trusted by imapfilter",
+	Long: `
+
+Check connects to the IMAP server of every profile and compares its
+certificate with the one trusted in ~/.imapfilter/certificates. A
+certificate seen for the first time, or changed since it was trusted,
+is shown with its SHA-256 fingerprint and stored only if the user
+trusts it: imapfilter refuses servers whose certificate is not stored
+there and cannot be verified. Certificates of servers no profile uses
+anymore are removed.
+
+The certificate of a server is first trusted when its profile is
+added; check is the way to accept a renewed certificate, or one that
+could not be fetched at the time.
+
+The -dry-run option allows the user to preview the changes without
+actually writing the certificates file.
+
+The -v option increases verbosity, printing the files about to be
+written.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdCheck.Run = runCheck
+	CmdCheck.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without writing them.")
+	CmdCheck.Flag.BoolVar(&verbose, "v", false, "Print files about to be written.")
+}
+
+func runCheck(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf %s\n", CmdCheck.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.Check(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot check: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"path"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// aerc has an account for every profile in aerc/accounts.conf, which
+// aerc only reads if nobody else can.
+type aerc struct {
+	cfg *config.Config
+}
+
+func (a aerc) file() (string, error) {
+	cfgdir, err := os.XDGConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, "aerc/accounts.conf"), nil
+}
+
+func (a aerc) GenConf(force bool) error {
+	file, err := a.file()
+	if err != nil {
+		return err
+	}
+	return generate(a.cfg, nil, "aerc/accounts.conf.tmpl", file, 0600, force)
+}
+
+func (a aerc) Remove() error {
+	file, err := a.file()
+	if err != nil {
+		return err
+	}
+	return io.Remove(file)
+}
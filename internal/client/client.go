@@ -0,0 +1,92 @@
+// Package client generates the account configuration of the mail
+// clients other than mu4e: aerc and neomutt.
+package client
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"reflect"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/templates"
+)
+
+const (
+	Aerc    = "aerc"
+	Neomutt = "neomutt"
+)
+
+var (
+	ErrModified = errors.New("Config modified externally")
+
+	// Names lists the supported clients.
+	Names = []string{Aerc, Neomutt}
+
+	//go:embed templates
+	embedded embed.FS
+)
+
+func init() {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		panic(err)
+	}
+	templates.Register(sub)
+}
+
+// Client is the account configuration of a mail client.
+type Client interface {
+	// GenConf writes the configuration of the client. Unless force
+	// is set, it returns ErrModified if a file differs from the one
+	// it would write.
+	GenConf(force bool) error
+	// Remove removes the configuration written by GenConf.
+	Remove() error
+}
+
+// New returns the client called name, or nil if it is not supported.
+func New(name string, cfg *config.Config) Client {
+	switch name {
+	case Aerc:
+		return aerc{cfg: cfg}
+	case Neomutt:
+		return neomutt{cfg: cfg}
+	default:
+		return nil
+	}
+}
+
+// Generate writes the configuration of every client selected by cfg.
+func Generate(cfg *config.Config, force bool) error {
+	for _, name := range cfg.Clients {
+		c := New(name, cfg)
+		if c == nil {
+			continue
+		}
+		err := c.GenConf(force)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate executes the template name for profile into file.
+func generate(cfg *config.Config, profile *config.Profile, name, file string, perm fs.FileMode, force bool) error {
+	ctx, err := templates.NewContext(cfg, profile)
+	if err != nil {
+		return err
+	}
+	data, err := templates.Execute(name, ctx)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.ReadFile(file)
+	if err == nil && !(reflect.DeepEqual(tmp, data) || force) {
+		return ErrModified
+	}
+	return io.Write(file, data, perm)
+}
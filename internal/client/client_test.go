@@ -0,0 +1,112 @@
+package client
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+var oldFs os.FsAccess
+
+func setup() {
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	oldFs = os.Set(&afero.Afero{
+		Fs: afero.NewMemMapFs(),
+	})
+}
+
+func restore() {
+	os.Set(oldFs)
+}
+
+func fixture(path string) []byte {
+	b, err := ioutil.ReadFile("testdata/fixtures" + path)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func twoProfiles() *config.Config {
+	return &config.Config{
+		Clients: []string{Aerc, Neomutt},
+		Profiles: []*config.Profile{
+			{
+				Name:     "Work",
+				FullName: "Doe, John",
+				Email:    "jdoe@gmail.com",
+				SmtpHost: "smtp.gmail.com",
+				SmtpPort: 587,
+				SmtpUser: "user@gmail.com",
+			},
+			{
+				Name:     "Home",
+				FullName: "John Doe",
+				Email:    "jdoe@home.org",
+				SmtpHost: "mail.home.org",
+				SmtpPort: 465,
+				SmtpUser: "jdoe",
+				ReadOnly: true,
+				Folders: []*config.Folder{
+					{Name: "inbox", Remote: "INBOX", Local: "INBOX"},
+					{Name: "sent", Remote: "Sent Items", Local: "Sent Items"},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	setup()
+	defer restore()
+	cfg := twoProfiles()
+	err := Generate(cfg, false)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	for _, file := range []string{
+		"/home/user/.config/aerc/accounts.conf",
+		"/home/user/.config/neomutt/mailconf.muttrc",
+		"/home/user/.config/neomutt/mailconf/Work.muttrc",
+		"/home/user/.config/neomutt/mailconf/Home.muttrc",
+	} {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("%s not saved: %v", file, err)
+		}
+		want := fixture("/two_profiles/" + path.Base(file))
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s: got: %s, want: %s", file, got, want)
+		}
+	}
+
+	os.WriteFile("/home/user/.config/aerc/accounts.conf", []byte("edited"), 0600)
+	err = Generate(cfg, false)
+	if err != ErrModified {
+		t.Fatalf("got error %v, want: %v", err, ErrModified)
+	}
+
+	cfg.Profiles = cfg.Profiles[:1]
+	err = Generate(cfg, true)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	if _, err := os.ReadFile("/home/user/.config/neomutt/mailconf/Home.muttrc"); err == nil {
+		t.Fatalf("account of a removed profile not removed")
+	}
+
+	err = New(Neomutt, cfg).Remove()
+	if err != nil {
+		t.Fatalf("cannot remove: %v", err)
+	}
+	if _, err := os.ReadFile("/home/user/.config/neomutt/mailconf/Work.muttrc"); err == nil {
+		t.Fatalf("neomutt accounts not removed")
+	}
+}
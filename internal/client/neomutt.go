@@ -0,0 +1,67 @@
+package client
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// neomutt has an account file for every profile in neomutt/mailconf,
+// and neomutt/mailconf.muttrc, to be sourced by the neomuttrc, which
+// lists the mailboxes and switches account with folder hooks.
+type neomutt struct {
+	cfg *config.Config
+}
+
+func (n neomutt) dir() (string, error) {
+	cfgdir, err := os.XDGConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, "neomutt"), nil
+}
+
+func (n neomutt) GenConf(force bool) error {
+	dir, err := n.dir()
+	if err != nil {
+		return err
+	}
+	err = generate(n.cfg, nil, "neomutt/mailconf.muttrc.tmpl", path.Join(dir, "mailconf.muttrc"), 0644, force)
+	if err != nil {
+		return err
+	}
+	accounts := make(map[string]bool)
+	for _, p := range n.cfg.Profiles {
+		accounts[p.Name+".muttrc"] = true
+		err = generate(n.cfg, p, "neomutt/account.muttrc.tmpl", path.Join(dir, "mailconf", p.Name+".muttrc"), 0644, force)
+		if err != nil {
+			return err
+		}
+	}
+	// the accounts of removed profiles.
+	files, _ := os.ReadDir(path.Join(dir, "mailconf"))
+	for _, f := range files {
+		if !accounts[f.Name()] && strings.HasSuffix(f.Name(), ".muttrc") {
+			err = io.Remove(path.Join(dir, "mailconf", f.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n neomutt) Remove() error {
+	dir, err := n.dir()
+	if err != nil {
+		return err
+	}
+	err = io.Remove(path.Join(dir, "mailconf.muttrc"))
+	if err != nil {
+		return err
+	}
+	return io.Remove(path.Join(dir, "mailconf"))
+}
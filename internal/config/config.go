@@ -2,58 +2,263 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"path"
 
+	"github.com/gianz74/mailconf/internal/options"
 	"github.com/gianz74/mailconf/internal/os"
+	"gopkg.in/yaml.v3"
 )
 
 var (
-	cfile string
+	ErrNotFound      = errors.New("config not found")
+	ErrFutureVersion = errors.New("config written by a newer mailconf")
 )
 
 type Profile struct {
-	Name     string `json:"profile_name"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
-	ImapHost string `json:"imaphost"`
-	ImapPort uint16 `json:"imapport"`
-	ImapUser string `json:"imapuser"`
-	SmtpHost string `json:"smtphost"`
-	SmtpPort uint16 `json:"smtpport"`
-	SmtpUser string `json:"smtpuser"`
+	Name     string    `json:"profile_name" yaml:"profile_name" toml:"profile_name"`
+	Email    string    `json:"email" yaml:"email" toml:"email"`
+	FullName string    `json:"full_name" yaml:"full_name" toml:"full_name"`
+	ImapHost string    `json:"imaphost" yaml:"imaphost" toml:"imaphost"`
+	ImapPort uint16    `json:"imapport" yaml:"imapport" toml:"imapport"`
+	ImapUser string    `json:"imapuser" yaml:"imapuser" toml:"imapuser"`
+	SmtpHost string    `json:"smtphost" yaml:"smtphost" toml:"smtphost"`
+	SmtpPort uint16    `json:"smtpport" yaml:"smtpport" toml:"smtpport"`
+	SmtpUser string    `json:"smtpuser" yaml:"smtpuser" toml:"smtpuser"`
+	Folders  []*Folder `json:"folders,omitempty" yaml:"folders,omitempty" toml:"folders,omitempty"`
+	// Disabled profiles keep their configuration and credentials, but
+	// are not synced.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty" toml:"disabled,omitempty"`
+	// ReadOnly profiles cannot send mail from their mu4e context.
+	ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty" toml:"read_only,omitempty"`
+	// SyncInterval overrides, in seconds, the sync interval of the
+	// configuration for this profile.
+	SyncInterval int `json:"sync_interval,omitempty" yaml:"sync_interval,omitempty" toml:"sync_interval,omitempty"`
+	// SignatureFile is the file holding the signature of the mu4e
+	// context of the profile.
+	SignatureFile string `json:"signature_file,omitempty" yaml:"signature_file,omitempty" toml:"signature_file,omitempty"`
+	// Identities are the alias addresses mail is also sent from and
+	// received at.
+	Identities []*Identity `json:"identities,omitempty" yaml:"identities,omitempty" toml:"identities,omitempty"`
+	// Include lists the remote mailboxes, or patterns of them such
+	// as "Projects/*", synced besides the folders by the patterns
+	// channel of the profile.
+	Include []string `json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"`
+	// Exclude lists the mailboxes, or patterns of them, left out of
+	// the patterns channel.
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty" toml:"exclude,omitempty"`
+	// Rules are the imapfilter rules run on the mailboxes of the
+	// profile after every sync.
+	Rules []*Rule `json:"rules,omitempty" yaml:"rules,omitempty" toml:"rules,omitempty"`
+	// Mbsync holds the options of the mbsync account and channels
+	// of the profile.
+	Mbsync *Mbsync `json:"mbsync,omitempty" yaml:"mbsync,omitempty" toml:"mbsync,omitempty"`
+	// Maildir overrides the maildir of the profile, <maildir
+	// root>/<name>. It must be in the maildir root.
+	Maildir string `json:"maildir,omitempty" yaml:"maildir,omitempty" toml:"maildir,omitempty"`
+}
+
+// Identity is an alias address of a profile. FullName and
+// SignatureFile default to the ones of the profile.
+type Identity struct {
+	Email         string `json:"email" yaml:"email" toml:"email"`
+	FullName      string `json:"full_name,omitempty" yaml:"full_name,omitempty" toml:"full_name,omitempty"`
+	SignatureFile string `json:"signature_file,omitempty" yaml:"signature_file,omitempty" toml:"signature_file,omitempty"`
+}
+
+// Addresses returns the address of the profile followed by the ones
+// of its identities.
+func (p *Profile) Addresses() []string {
+	addrs := []string{p.Email}
+	for _, id := range p.Identities {
+		addrs = append(addrs, id.Email)
+	}
+	return addrs
+}
+
+// Copy returns a deep copy of the profile, sharing no folders,
+// identities, patterns, rules or mbsync options with it.
+func (p *Profile) Copy() *Profile {
+	c := *p
+	c.Folders = nil
+	for _, f := range p.Folders {
+		folder := *f
+		folder.Patterns = append([]string(nil), f.Patterns...)
+		c.Folders = append(c.Folders, &folder)
+	}
+	c.Identities = nil
+	for _, id := range p.Identities {
+		identity := *id
+		c.Identities = append(c.Identities, &identity)
+	}
+	c.Include = append([]string(nil), p.Include...)
+	c.Exclude = append([]string(nil), p.Exclude...)
+	c.Rules = nil
+	for _, r := range p.Rules {
+		c.Rules = append(c.Rules, r.copy())
+	}
+	if p.Mbsync != nil {
+		mbsync := *p.Mbsync
+		c.Mbsync = &mbsync
+	}
+	return &c
 }
 
 type Config struct {
-	EmacsCfgDir string     `json:"emacs_cfg_dir"`
-	BinDir      string     `json:"bindir"`
-	Profiles    []*Profile `json:"profiles"`
+	Version     int        `json:"version" yaml:"version" toml:"version"`
+	EmacsCfgDir string     `json:"emacs_cfg_dir" yaml:"emacs_cfg_dir" toml:"emacs_cfg_dir"`
+	BinDir      string     `json:"bindir" yaml:"bindir" toml:"bindir"`
+	Profiles    []*Profile `json:"profiles" yaml:"profiles" toml:"profiles"`
+	// SyncInterval is the number of seconds between two syncs; zero
+	// means DefaultSyncInterval.
+	SyncInterval int `json:"sync_interval,omitempty" yaml:"sync_interval,omitempty" toml:"sync_interval,omitempty"`
+	// PerProfileSync syncs every profile with its own mbsync service,
+	// instead of syncing them together.
+	PerProfileSync bool `json:"per_profile_sync,omitempty" yaml:"per_profile_sync,omitempty" toml:"per_profile_sync,omitempty"`
+	// UseMsmtp makes mu4e send mail with msmtp, instead of smtpmail.
+	UseMsmtp bool `json:"use_msmtp,omitempty" yaml:"use_msmtp,omitempty" toml:"use_msmtp,omitempty"`
+	// Frontend is the program indexing the mail, "mu4e" or
+	// "notmuch"; empty means "mu4e".
+	Frontend string `json:"frontend,omitempty" yaml:"frontend,omitempty" toml:"frontend,omitempty"`
+	// Clients lists the mail clients configured for the profiles,
+	// "aerc" and "neomutt".
+	Clients []string `json:"clients,omitempty" yaml:"clients,omitempty" toml:"clients,omitempty"`
+	// Mu4e holds the preferences written to mu4e.el.
+	Mu4e *Mu4e `json:"mu4e,omitempty" yaml:"mu4e,omitempty" toml:"mu4e,omitempty"`
+	// MuVersion is the version of mu found by setup, such as
+	// "1.8.13"; empty if unknown.
+	MuVersion string `json:"mu_version,omitempty" yaml:"mu_version,omitempty" toml:"mu_version,omitempty"`
+	// Mu4eDir is the directory holding mu4e.el, added to the Emacs
+	// load-path; empty if unknown.
+	Mu4eDir string `json:"mu4e_dir,omitempty" yaml:"mu4e_dir,omitempty" toml:"mu4e_dir,omitempty"`
+	// MbsyncVersion is the version of mbsync found by setup, such
+	// as "1.4.4"; empty if unknown.
+	MbsyncVersion string `json:"mbsync_version,omitempty" yaml:"mbsync_version,omitempty" toml:"mbsync_version,omitempty"`
+	// MaildirRoot is the directory holding the maildirs of the
+	// profiles; empty means ~/Maildir.
+	MaildirRoot string `json:"maildir_root,omitempty" yaml:"maildir_root,omitempty" toml:"maildir_root,omitempty"`
+
+	// format is the format of the file the config was read from.
+	format string
+	// yaml is the document read from config.yaml, kept to preserve
+	// its comments.
+	yaml *yaml.Node
+	// migrated is the document read, if it was written by an older
+	// mailconf, which Save backs up to backup before replacing it
+	// with the upgraded one.
+	migrated []byte
+	backup   string
 }
 
-func Read() *Config {
+// Read reads the configuration from config.yaml, config.toml or
+// data.json, whichever is found in the directory selected with
+// SetLocation, upgrading it to CurrentVersion if it was written by an
+// older mailconf. Nothing is written: the upgraded configuration
+// replaces the original one, which is backed up, only when it is
+// saved. It returns ErrNotFound if there is no configuration yet, and
+// a ValidationError if the configuration is not valid.
+func Read() (*Config, error) {
 	cfgdir, err := configdir()
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	di, err := os.ReadFile(path.Join(cfgdir, "data.json"))
+	cfile, format, di, err := locate(cfgdir)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	doc, err := decode(format, di)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", cfile, err)
 	}
 
-	cfg := &Config{}
+	from, err := migrate(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfile, err)
+	}
 
-	err = json.Unmarshal(di, cfg)
+	cfg := &Config{
+		format: format,
+	}
+	upgraded, err := json.Marshal(doc)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	err = json.Unmarshal(upgraded, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", cfile, err)
+	}
+	if format == YAML {
+		cfg.yaml = &yaml.Node{}
+		err = yaml.Unmarshal(di, cfg.yaml)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", cfile, err)
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfile, err)
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %v; rename it with \"mailconf profile rename\".\n", w)
+	}
+
+	if from < CurrentVersion {
+		cfg.migrated = di
+		cfg.backup = fmt.Sprintf("%s.v%d.bak", cfile, from)
 	}
 
-	return cfg
+	return cfg, nil
 }
 
 func NewConfig() *Config {
 	return &Config{}
 }
 
+// Format returns the format the config is saved in.
+func (c *Config) Format() string {
+	if c.format == "" {
+		return JSON
+	}
+	return c.format
+}
+
+// SetFormat changes the format the config is saved in.
+func (c *Config) SetFormat(format string) error {
+	if _, err := filename(format); err != nil {
+		return err
+	}
+	c.format = format
+	return nil
+}
+
+// File returns the path of the file the config is saved to.
+func (c *Config) File() (string, error) {
+	cfgdir, err := configdir()
+	if err != nil {
+		return "", err
+	}
+	name, err := filename(c.Format())
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, name), nil
+}
+
+// Save writes the configuration to the file returned by File. The
+// document read by Read from an older mailconf is backed up first, to
+// <file>.v<version>.bak. In dry run mode nothing is written.
 func (c *Config) Save() error {
+	cfile, err := c.File()
+	if err != nil {
+		return err
+	}
+	if options.Dryrun() {
+		fmt.Printf("writing to %s\n", cfile)
+		return nil
+	}
 	cfgdir, err := configdir()
 	if err != nil {
 		return err
@@ -62,25 +267,32 @@ func (c *Config) Save() error {
 	if err != nil {
 		return err
 	}
-	cfile := path.Join(cfgdir, "data.json")
+	if c.migrated != nil {
+		err = os.WriteFile(c.backup, c.migrated, 0640)
+		if err != nil {
+			return fmt.Errorf("cannot back up the config to %s: %w", c.backup, err)
+		}
+		c.migrated = nil
+	}
 
-	conf, err := json.MarshalIndent(c, "", "\t")
+	c.Version = CurrentVersion
+	conf, err := c.encode()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(cfile, conf, 0640)
+	err = os.WriteFileAtomic(cfile, conf, 0640)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-func configdir() (string, error) {
-	cfgdir, err := os.UserConfigDir()
+	// the first configuration saved is the one the files were
+	// generated from.
+	active, err := Active()
 	if err != nil {
-		return "", err
+		return err
+	}
+	if active == "" {
+		return Activate()
 	}
-	cfgdir = path.Join(cfgdir, "mailconf")
-	return cfgdir, nil
+	return nil
 }
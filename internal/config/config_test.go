@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+const work = `{"profile_name": "Work", "email": "jdoe@gmail.com", "full_name": "John Doe", "imaphost": "imap.gmail.com", "imapport": 993, "imapuser": "user@gmail.com", "smtphost": "smtp.gmail.com", "smtpport": 587, "smtpuser": "user@gmail.com"}`
+
+func setup(data string) {
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	fs := &afero.Afero{
+		Fs: afero.NewMemMapFs(),
+	}
+	if data != "" {
+		fs.WriteFile("/home/user/.config/mailconf/data.json", []byte(data), 0640)
+	}
+	os.Set(fs)
+}
+
+func TestRead(t *testing.T) {
+	tt := []struct {
+		name    string
+		data    string
+		backup  string
+		version int
+		err     error
+	}{
+		{
+			"missing",
+			"",
+			"",
+			0,
+			ErrNotFound,
+		},
+		{
+			"unversioned",
+			`{"emacs_cfg_dir": "/home/user/.emacs.d", "profiles": [` + work + `]}`,
+			"/home/user/.config/mailconf/data.json.v0.bak",
+			CurrentVersion,
+			nil,
+		},
+		{
+			"current",
+			`{"version": 1, "emacs_cfg_dir": "/home/user/.emacs.d", "profiles": [` + work + `]}`,
+			"",
+			CurrentVersion,
+			nil,
+		},
+		{
+			"future",
+			`{"version": 1000, "profiles": []}`,
+			"",
+			0,
+			ErrFutureVersion,
+		},
+	}
+	for _, tc := range tt {
+		setup(tc.data)
+		cfg, err := Read()
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if tc.err != nil {
+			continue
+		}
+		if cfg.Version != tc.version {
+			t.Fatalf("%s: got version %d, want: %d", tc.name, cfg.Version, tc.version)
+		}
+		if len(cfg.Profiles) != 1 || cfg.Profiles[0].Name != "Work" {
+			t.Fatalf("%s: profiles not read: %+v", tc.name, cfg.Profiles)
+		}
+		if tc.backup == "" {
+			continue
+		}
+		// the upgraded config is only written when it is saved.
+		if got, _ := os.ReadFile("/home/user/.config/mailconf/data.json"); string(got) != tc.data {
+			t.Fatalf("%s: config written on read: %s", tc.name, got)
+		}
+		if _, err := os.ReadFile(tc.backup); err == nil {
+			t.Fatalf("%s: backup %s written on read", tc.name, tc.backup)
+		}
+		options.Set(options.OptDryrun(true))
+		err = cfg.Save()
+		options.Set(options.OptDryrun(false))
+		if err != nil {
+			t.Fatalf("%s: cannot save config: %v", tc.name, err)
+		}
+		if _, err := os.ReadFile(tc.backup); err == nil {
+			t.Fatalf("%s: backup %s written in dry run mode", tc.name, tc.backup)
+		}
+		err = cfg.Save()
+		if err != nil {
+			t.Fatalf("%s: cannot save config: %v", tc.name, err)
+		}
+		got, err := os.ReadFile(tc.backup)
+		if err != nil {
+			t.Fatalf("%s: missing backup %s", tc.name, tc.backup)
+		}
+		if string(got) != tc.data {
+			t.Fatalf("%s: got backup: %s, want: %s", tc.name, got, tc.data)
+		}
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		data string
+	}{
+		{"not json", `profiles: []`},
+		{"bad version", `{"version": "one"}`},
+		{"bad field", `{"version": 1, "profiles": [{"imapport": "imaps"}]}`},
+		{"invalid profile", `{"version": 1, "profiles": [{"profile_name": "Work"}]}`},
+	}
+	for _, tc := range tt {
+		setup(tc.data)
+		cfg, err := Read()
+		if err == nil {
+			t.Fatalf("%s: got %+v, want an error", tc.name, cfg)
+		}
+	}
+}
+
+func TestReadUnsafeName(t *testing.T) {
+	setup(`{"version": 1, "profiles": [` + strings.Replace(work, `"Work"`, `"my work"`, 1) + `]}`)
+	out, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatalf("cannot create stderr: %v", err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = out
+	defer func() { os.Stderr = oldStderr }()
+
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("got error %v, want the profile to be read to be renamed", err)
+	}
+	if len(cfg.Profiles) != 1 || cfg.Profiles[0].Name != "my work" {
+		t.Fatalf("profiles not read: %+v", cfg.Profiles)
+	}
+	warning, _ := ioutil.ReadFile(out.Name())
+	if !strings.Contains(string(warning), `warning: profile "my work": profile_name: contains white space`) {
+		t.Fatalf("got stderr %q, want a warning about the name", warning)
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Folder maps a remote IMAP folder to a local maildir folder. Each
+// folder becomes an mbsync channel named <profile>-<name>.
+type Folder struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`
+	Remote  string `json:"remote" yaml:"remote" toml:"remote"`
+	Local   string `json:"local" yaml:"local" toml:"local"`
+	Expunge string `json:"expunge,omitempty" yaml:"expunge,omitempty" toml:"expunge,omitempty"`
+	// Create, Sync, MaxMessages and CopyArrivalDate override the
+	// mbsync options of the profile for the channel of the folder.
+	Create          string `json:"create,omitempty" yaml:"create,omitempty" toml:"create,omitempty"`
+	Sync            string `json:"sync,omitempty" yaml:"sync,omitempty" toml:"sync,omitempty"`
+	MaxMessages     int    `json:"max_messages,omitempty" yaml:"max_messages,omitempty" toml:"max_messages,omitempty"`
+	CopyArrivalDate bool   `json:"copy_arrival_date,omitempty" yaml:"copy_arrival_date,omitempty" toml:"copy_arrival_date,omitempty"`
+	// Patterns makes the channel sync the mailboxes below Remote
+	// matching them, such as "Projects/*", into the ones below Local;
+	// Remote and Local can then be empty.
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty" toml:"patterns,omitempty"`
+}
+
+// DefaultFolders returns the folder map used by profiles that do not
+// set their own, which fits Gmail accounts.
+func DefaultFolders() []*Folder {
+	return []*Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+		{Name: "trash", Remote: "[Gmail]/Bin", Local: "trash"},
+		{Name: "sent", Remote: "[Gmail]/Sent Mail", Local: "sent", Expunge: "Both"},
+		{Name: "allmail", Remote: "email-archive", Local: "email-archive", Expunge: "Slave"},
+	}
+}
+
+// FolderMap returns the folders of the profile, or DefaultFolders if
+// it does not set any.
+func (p *Profile) FolderMap() []*Folder {
+	if len(p.Folders) == 0 {
+		return DefaultFolders()
+	}
+	return p.Folders
+}
+
+// LocalFolder returns the local maildir folder of the folder called
+// name, or def if the profile has no such folder.
+func (p *Profile) LocalFolder(name, def string) string {
+	for _, f := range p.FolderMap() {
+		if f.Name == name {
+			return f.Local
+		}
+	}
+	return def
+}
+
+// RemoteFolder returns the remote IMAP folder of the folder called
+// name, or def if the profile has no such folder.
+func (p *Profile) RemoteFolder(name, def string) string {
+	for _, f := range p.FolderMap() {
+		if f.Name == name {
+			return f.Remote
+		}
+	}
+	return def
+}
+
+// PatternsChannel names the channel, <profile>-patterns, syncing the
+// mailboxes matching Include.
+const PatternsChannel = "patterns"
+
+// Channels returns the folders of the profile followed, if the profile
+// includes more mailboxes, by the folder of its patterns channel.
+func (p *Profile) Channels() []*Folder {
+	folders := p.FolderMap()
+	patterns := p.Patterns()
+	if patterns == nil {
+		return folders
+	}
+	return append(append([]*Folder{}, folders...), &Folder{Name: PatternsChannel, Patterns: patterns})
+}
+
+// Patterns returns the Patterns of the patterns channel: the ones in
+// Include, then the ones in Exclude and the mailboxes of the folders,
+// which are synced by their own channels, negated. It returns nil if
+// Include is empty.
+func (p *Profile) Patterns() []string {
+	if len(p.Include) == 0 {
+		return nil
+	}
+	patterns := append([]string{}, p.Include...)
+	seen := make(map[string]bool)
+	exclude := func(pattern string) {
+		if pattern == "" || seen[pattern] {
+			return
+		}
+		seen[pattern] = true
+		patterns = append(patterns, "!"+pattern)
+	}
+	for _, pattern := range p.Exclude {
+		exclude(pattern)
+	}
+	// patterns match the mailboxes of both sides.
+	for _, f := range p.FolderMap() {
+		exclude(f.Remote)
+		exclude(f.Local)
+	}
+	return patterns
+}
+
+// Shortcut is a key jumping to a maildir folder of a profile in mu4e.
+type Shortcut struct {
+	Folder string
+	Key    string
+}
+
+// IncludedShortcuts returns the shortcuts of the first nine mailboxes
+// named in Include without wildcards, on the keys 1 to 9. The mailboxes
+// keep their remote name in the maildir.
+func (p *Profile) IncludedShortcuts() []*Shortcut {
+	var shortcuts []*Shortcut
+	for _, name := range p.Include {
+		if strings.ContainsAny(name, "*%") || len(shortcuts) == 9 {
+			continue
+		}
+		shortcuts = append(shortcuts, &Shortcut{Folder: name, Key: strconv.Itoa(len(shortcuts) + 1)})
+	}
+	return shortcuts
+}
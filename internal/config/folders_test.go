@@ -0,0 +1,30 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	p := withFolders(profile("Work", "jdoe@work.com"),
+		&Folder{Name: "inbox", Remote: "INBOX", Local: "INBOX"},
+		&Folder{Name: "sent", Remote: "Sent", Local: "sent"},
+	)
+	if got := p.Channels(); len(got) != 2 {
+		t.Fatalf("got %d channels without include, want: 2", len(got))
+	}
+
+	p.Include = []string{"Projects/*", "Clients", "Lists/golang-nuts"}
+	p.Exclude = []string{"Projects/Old", "Sent"}
+	want := []string{"Projects/*", "Clients", "Lists/golang-nuts", "!Projects/Old", "!Sent", "!INBOX", "!sent"}
+	channels := p.Channels()
+	if len(channels) != 3 || channels[2].Name != PatternsChannel || !reflect.DeepEqual(channels[2].Patterns, want) {
+		t.Fatalf("got patterns channel %+v, want patterns: %q", channels[len(channels)-1], want)
+	}
+
+	shortcuts := p.IncludedShortcuts()
+	wantShortcuts := []*Shortcut{{Folder: "Clients", Key: "1"}, {Folder: "Lists/golang-nuts", Key: "2"}}
+	if !reflect.DeepEqual(shortcuts, wantShortcuts) {
+		t.Fatalf("got shortcuts %+v, want: %+v", shortcuts, wantShortcuts)
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gianz74/mailconf/internal/os"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JSON = "json"
+	YAML = "yaml"
+	TOML = "toml"
+)
+
+var ErrFormat = errors.New("unknown config format")
+
+// files maps each format to the name of its file in the mailconf
+// config dir, in the order they are looked up.
+var files = []struct {
+	format string
+	name   string
+}{
+	{YAML, "config.yaml"},
+	{TOML, "config.toml"},
+	{JSON, "data.json"},
+}
+
+func filename(format string) (string, error) {
+	for _, f := range files {
+		if f.format == format {
+			return f.name, nil
+		}
+	}
+	return "", fmt.Errorf("%q: %w", format, ErrFormat)
+}
+
+// locate returns the config file found in cfgdir, its format and its
+// content. It fails if more than one is found, as it would not be
+// clear which one is in use.
+func locate(cfgdir string) (string, string, []byte, error) {
+	var found []string
+	var file, format string
+	var data []byte
+	for _, f := range files {
+		name := path.Join(cfgdir, f.name)
+		di, err := os.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", nil, fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		if found == nil {
+			file, format, data = name, f.format, di
+		}
+		found = append(found, name)
+	}
+	if len(found) == 0 {
+		return "", "", nil, ErrNotFound
+	}
+	if len(found) > 1 {
+		return "", "", nil, fmt.Errorf("more than one config file found: %s", strings.Join(found, ", "))
+	}
+	return file, format, data, nil
+}
+
+// decode parses data, written in format, into a generic document.
+func decode(format string, data []byte) (map[string]any, error) {
+	doc := map[string]any{}
+	var err error
+	switch format {
+	case JSON:
+		err = json.Unmarshal(data, &doc)
+	case YAML:
+		err = yaml.Unmarshal(data, &doc)
+	case TOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = ErrFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// encode marshals c in its format. YAML documents are merged into the
+// one originally read, so that the comments survive.
+func (c *Config) encode() ([]byte, error) {
+	switch c.Format() {
+	case JSON:
+		return json.MarshalIndent(c, "", "\t")
+	case YAML:
+		doc := &yaml.Node{}
+		err := doc.Encode(c)
+		if err != nil {
+			return nil, err
+		}
+		if c.yaml != nil {
+			mergeYAML(c.yaml, doc)
+			doc = c.yaml
+		}
+		out := &bytes.Buffer{}
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		err = enc.Encode(doc)
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case TOML:
+		out := &bytes.Buffer{}
+		err := toml.NewEncoder(out).Encode(c)
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	default:
+		return nil, ErrFormat
+	}
+}
+
+// mergeYAML updates dst with the values in src, keeping the comments
+// and the key order of dst. Keys missing from src are removed;
+// sequence items are matched by profile name when they have one.
+func mergeYAML(dst, src *yaml.Node) {
+	if dst.Kind == yaml.DocumentNode && len(dst.Content) > 0 {
+		dst = dst.Content[0]
+	}
+	if src.Kind == yaml.DocumentNode && len(src.Content) > 0 {
+		src = src.Content[0]
+	}
+	if dst.Kind != src.Kind {
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		return
+	}
+	switch src.Kind {
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		used := make(map[string]bool)
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key := dst.Content[i].Value
+			val := mappingValue(src, key)
+			if val == nil {
+				continue
+			}
+			mergeYAML(dst.Content[i+1], val)
+			content = append(content, dst.Content[i], dst.Content[i+1])
+			used[key] = true
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			if !used[src.Content[i].Value] {
+				content = append(content, src.Content[i], src.Content[i+1])
+			}
+		}
+		dst.Content = content
+	case yaml.SequenceNode:
+		var content []*yaml.Node
+		for i, item := range src.Content {
+			old := sequenceItem(dst, item, i)
+			if old == nil {
+				content = append(content, item)
+				continue
+			}
+			mergeYAML(old, item)
+			content = append(content, old)
+		}
+		dst.Content = content
+	default:
+		if dst.Value != src.Value || dst.Tag != src.Tag {
+			dst.Value, dst.Tag = src.Value, src.Tag
+			dst.Style = src.Style
+		}
+	}
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceItem returns the item of seq corresponding to item, which
+// is at index i of the new sequence.
+func sequenceItem(seq, item *yaml.Node, i int) *yaml.Node {
+	if name := mappingValue(item, "profile_name"); item.Kind == yaml.MappingNode && name != nil {
+		for _, old := range seq.Content {
+			if n := mappingValue(old, "profile_name"); old.Kind == yaml.MappingNode && n != nil && n.Value == name.Value {
+				return old
+			}
+		}
+		return nil
+	}
+	if i < len(seq.Content) {
+		return seq.Content[i]
+	}
+	return nil
+}
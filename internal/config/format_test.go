@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+const yamlConfig = `# mailconf configuration, kept in my dotfiles
+version: 1
+emacs_cfg_dir: /home/user/.emacs.d # where mu4e.el goes
+bindir: /home/user/.local/bin
+profiles:
+  # my job
+  - profile_name: Work
+    email: jdoe@gmail.com
+    full_name: John Doe
+    imaphost: imap.gmail.com
+    imapport: 993 # IMAPS
+    imapuser: user@gmail.com
+    smtphost: smtp.gmail.com
+    smtpport: 587
+    smtpuser: user@gmail.com
+`
+
+const tomlConfig = `version = 1
+emacs_cfg_dir = "/home/user/.emacs.d"
+bindir = "/home/user/.local/bin"
+
+[[profiles]]
+  profile_name = "Work"
+  email = "jdoe@gmail.com"
+  full_name = "John Doe"
+  imaphost = "imap.gmail.com"
+  imapport = 993
+  imapuser = "user@gmail.com"
+  smtphost = "smtp.gmail.com"
+  smtpport = 587
+  smtpuser = "user@gmail.com"
+`
+
+func setupFiles(files map[string]string) {
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	fs := &afero.Afero{
+		Fs: afero.NewMemMapFs(),
+	}
+	for name, data := range files {
+		fs.WriteFile("/home/user/.config/mailconf/"+name, []byte(data), 0640)
+	}
+	os.Set(fs)
+}
+
+func TestFormats(t *testing.T) {
+	tt := []struct {
+		name   string
+		files  map[string]string
+		format string
+		err    error
+	}{
+		{
+			"json",
+			map[string]string{"data.json": `{"version": 1, "profiles": [` + work + `]}`},
+			JSON,
+			nil,
+		},
+		{
+			"yaml",
+			map[string]string{"config.yaml": yamlConfig},
+			YAML,
+			nil,
+		},
+		{
+			"toml",
+			map[string]string{"config.toml": tomlConfig},
+			TOML,
+			nil,
+		},
+		{
+			"ambiguous",
+			map[string]string{"config.yaml": yamlConfig, "data.json": `{}`},
+			"",
+			errors.New("more than one config file found"),
+		},
+	}
+	for _, tc := range tt {
+		setupFiles(tc.files)
+		cfg, err := Read()
+		if tc.err != nil {
+			if err == nil || !strings.Contains(err.Error(), tc.err.Error()) {
+				t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: got error %v, want: <nil>", tc.name, err)
+		}
+		if cfg.Format() != tc.format {
+			t.Fatalf("%s: got format %s, want: %s", tc.name, cfg.Format(), tc.format)
+		}
+		if len(cfg.Profiles) != 1 || cfg.Profiles[0].ImapPort != 993 {
+			t.Fatalf("%s: profiles not read: %+v", tc.name, cfg.Profiles)
+		}
+
+		cfg.Profiles[0].FullName = "John Doe Jr"
+		err = cfg.Save()
+		if err != nil {
+			t.Fatalf("%s: cannot save: %v", tc.name, err)
+		}
+		cfg, err = Read()
+		if err != nil {
+			t.Fatalf("%s: cannot read saved config: %v", tc.name, err)
+		}
+		if cfg.Format() != tc.format || cfg.Profiles[0].FullName != "John Doe Jr" {
+			t.Fatalf("%s: config not saved in %s: %+v", tc.name, tc.format, cfg.Profiles[0])
+		}
+	}
+}
+
+func TestYAMLComments(t *testing.T) {
+	setupFiles(map[string]string{"config.yaml": yamlConfig})
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("cannot read config: %v", err)
+	}
+	cfg.Profiles[0].SmtpPort = 465
+	home := profile("Home", "jdoe@home.com")
+	cfg.Profiles = append(cfg.Profiles, home)
+	err = cfg.Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	got, _ := os.ReadFile("/home/user/.config/mailconf/config.yaml")
+	for _, want := range []string{
+		"# mailconf configuration, kept in my dotfiles",
+		"emacs_cfg_dir: /home/user/.emacs.d # where mu4e.el goes",
+		"# my job",
+		"imapport: 993 # IMAPS",
+		"smtpport: 465",
+		"profile_name: Home",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Fatalf("saved config misses %q:\n%s", want, got)
+		}
+	}
+}
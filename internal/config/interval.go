@@ -0,0 +1,43 @@
+package config
+
+const (
+	// DefaultSyncInterval is the sync interval, in seconds, used when
+	// the configuration does not set one.
+	DefaultSyncInterval = 300
+	// MinSyncInterval is the shortest sync interval allowed.
+	MinSyncInterval = 60
+)
+
+// Interval returns the sync interval of the configuration in seconds.
+func (c *Config) Interval() int {
+	if c.SyncInterval == 0 {
+		return DefaultSyncInterval
+	}
+	return c.SyncInterval
+}
+
+// ProfileInterval returns the sync interval of p in seconds.
+func (c *Config) ProfileInterval(p *Profile) int {
+	if p.SyncInterval == 0 {
+		return c.Interval()
+	}
+	return p.SyncInterval
+}
+
+// OwnSchedule reports whether p is synced by its own mbsync service,
+// rather than with the other profiles: either because its interval
+// differs from the shared one or because of PerProfileSync.
+func (c *Config) OwnSchedule(p *Profile) bool {
+	return !p.Disabled && (c.PerProfileSync || c.ProfileInterval(p) != c.Interval())
+}
+
+// SharedSchedule reports whether any profile is synced by the shared
+// mbsync service.
+func (c *Config) SharedSchedule() bool {
+	for _, p := range c.Profiles {
+		if !p.Disabled && !c.OwnSchedule(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var (
+	ErrLocation = errors.New("invalid config location")
+	ErrInactive = errors.New("config is not the active one")
+	ErrDetached = errors.New("config is outside the mailconf config dir")
+
+	// location is the config selected with SetLocation.
+	location string
+)
+
+// SetLocation selects the configuration mailconf works on. loc is
+// either the path of a directory, or of a config file in it, or the
+// name of a config set, kept in [user config dir]/mailconf/sets/<name>.
+// An empty loc selects the default [user config dir]/mailconf.
+//
+// Only the configuration of mailconf moves with the location: the
+// generated files, such as ~/.mbsyncrc, mu4e.el and the services, are
+// shared by all of them. Only one configuration, the active one, can
+// therefore generate them, and never one in a directory outside
+// [user config dir]/mailconf; see CheckActive.
+func SetLocation(loc string) error {
+	if loc == "" || isPath(loc) {
+		location = loc
+		return nil
+	}
+	// paths, ".." included, are handled above.
+	if strings.ContainsRune(loc, '\\') {
+		return fmt.Errorf("%q: %w", loc, ErrLocation)
+	}
+	location = loc
+	return nil
+}
+
+// Location returns the location set with SetLocation.
+func Location() string {
+	return location
+}
+
+// Sets returns the names of the existing config sets.
+func Sets() ([]string, error) {
+	dir, err := setsdir()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sets []string
+	for _, info := range infos {
+		if info.IsDir() {
+			sets = append(sets, info.Name())
+		}
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
+
+func isPath(loc string) bool {
+	return strings.ContainsRune(loc, '/') || strings.HasPrefix(loc, ".") || strings.HasPrefix(loc, "~")
+}
+
+// defaultdir is the directory of the default configuration, which
+// holds the config sets too.
+func defaultdir() (string, error) {
+	cfgdir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, "mailconf"), nil
+}
+
+func setsdir() (string, error) {
+	dir, err := defaultdir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "sets"), nil
+}
+
+func configdir() (string, error) {
+	if location == "" {
+		return defaultdir()
+	}
+	if !isPath(location) {
+		dir, err := setsdir()
+		if err != nil {
+			return "", err
+		}
+		return path.Join(dir, location), nil
+	}
+
+	loc := location
+	if loc == "~" || strings.HasPrefix(loc, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		loc = path.Join(home, loc[1:])
+	}
+	loc, err := filepath.Abs(loc)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if path.Base(loc) == f.name {
+			return path.Dir(loc), nil
+		}
+	}
+	if path.Ext(loc) != "" {
+		return "", fmt.Errorf("%q: config files must be named config.yaml, config.toml or data.json: %w", location, ErrLocation)
+	}
+	return loc, nil
+}
+
+// detached reports whether the selected configuration is neither the
+// default one nor a config set.
+func detached() (bool, error) {
+	cfgdir, err := configdir()
+	if err != nil {
+		return false, err
+	}
+	dir, err := defaultdir()
+	if err != nil {
+		return false, err
+	}
+	return cfgdir != dir && path.Dir(cfgdir) != path.Join(dir, "sets"), nil
+}
+
+// activefile is the file recording the directory of the active
+// configuration: the one of the default configuration, shared by the
+// config sets, or the one in the directory of a detached
+// configuration, which never touches the default one.
+func activefile() (string, error) {
+	dir, err := defaultdir()
+	if err != nil {
+		return "", err
+	}
+	ok, err := detached()
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		dir, err = configdir()
+		if err != nil {
+			return "", err
+		}
+	}
+	return path.Join(dir, ".active"), nil
+}
+
+// Active returns the directory of the active configuration, the one
+// the generated files were last generated from; empty if none was
+// recorded yet.
+func Active() (string, error) {
+	file, err := activefile()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Activate records the configuration selected with SetLocation as the
+// active one.
+func Activate() error {
+	if options.Dryrun() {
+		return nil
+	}
+	cfgdir, err := configdir()
+	if err != nil {
+		return err
+	}
+	file, err := activefile()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFileAtomic(file, []byte(cfgdir+"\n"), 0640)
+}
+
+// CheckAttached fails with ErrDetached if the configuration selected
+// with SetLocation is in a directory outside [user config dir]/mailconf:
+// the files generated from it would replace the ones in the home
+// directory, which such a configuration, say a test sandbox, must not
+// touch.
+func CheckAttached() error {
+	ok, err := detached()
+	if err != nil {
+		return err
+	}
+	if ok {
+		cfgdir, err := configdir()
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s cannot generate files; only the default config and the config sets can", ErrDetached, cfgdir)
+	}
+	return nil
+}
+
+// CheckActive fails with ErrDetached as CheckAttached does, or with
+// ErrInactive if another configuration than the one selected with
+// SetLocation is active, as the files generated from the selected one
+// would replace the ones of the active one.
+func CheckActive() error {
+	err := CheckAttached()
+	if err != nil {
+		return err
+	}
+	active, err := Active()
+	if err != nil {
+		return err
+	}
+	cfgdir, err := configdir()
+	if err != nil {
+		return err
+	}
+	if active != "" && active != cfgdir {
+		return fmt.Errorf("%w: the generated files belong to %s; switch to this one with \"mailconf config sets -activate\"", ErrInactive, active)
+	}
+	return nil
+}
+
+// ReadActive reads the active configuration, if it is not the one
+// selected with SetLocation; otherwise it returns nil.
+func ReadActive() (*Config, error) {
+	active, err := Active()
+	if err != nil {
+		return nil, err
+	}
+	cfgdir, err := configdir()
+	if err != nil {
+		return nil, err
+	}
+	if active == "" || active == cfgdir {
+		return nil, nil
+	}
+	selected := location
+	defer func() { location = selected }()
+	location = active
+	return Read()
+}
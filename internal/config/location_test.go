@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+func TestLocation(t *testing.T) {
+	tt := []struct {
+		name     string
+		location string
+		want     string
+		err      error
+	}{
+		{
+			"default",
+			"",
+			"/home/user/.config/mailconf",
+			nil,
+		},
+		{
+			"set",
+			"personal",
+			"/home/user/.config/mailconf/sets/personal",
+			nil,
+		},
+		{
+			"dir",
+			"/srv/mail/conf",
+			"/srv/mail/conf",
+			nil,
+		},
+		{
+			"home",
+			"~/mailconf",
+			"/home/user/mailconf",
+			nil,
+		},
+		{
+			"file",
+			"/srv/mail/conf/config.yaml",
+			"/srv/mail/conf",
+			nil,
+		},
+		{
+			"unknown file",
+			"/srv/mail/conf/mail.ini",
+			"",
+			ErrLocation,
+		},
+	}
+	defer SetLocation("")
+	for _, tc := range tt {
+		setup("")
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		err := SetLocation(tc.location)
+		if err != nil {
+			t.Fatalf("%s: cannot set location: %v", tc.name, err)
+		}
+		got, err := configdir()
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got: %s, want: %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	defer SetLocation("")
+	setup("")
+	sets, err := Sets()
+	if err != nil || sets != nil {
+		t.Fatalf("got: %v (%v), want no sets", sets, err)
+	}
+
+	os.WriteFile("/home/user/.config/mailconf/sets/work/data.json", []byte(`{"profiles": [`+work+`]}`), 0640)
+	os.WriteFile("/home/user/.config/mailconf/sets/home/config.yaml", []byte("profiles: []\n"), 0640)
+	os.WriteFile("/home/user/.config/mailconf/sets/README", []byte("notes\n"), 0640)
+	sets, err = Sets()
+	if err != nil {
+		t.Fatalf("cannot list sets: %v", err)
+	}
+	if want := []string{"home", "work"}; !reflect.DeepEqual(sets, want) {
+		t.Fatalf("got: %v, want: %v", sets, want)
+	}
+
+	SetLocation("work")
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("cannot read set: %v", err)
+	}
+	if len(cfg.Profiles) != 1 || cfg.Profiles[0].Name != "Work" {
+		t.Fatalf("profiles not read: %+v", cfg.Profiles)
+	}
+}
+
+func TestActive(t *testing.T) {
+	defer SetLocation("")
+	setup("")
+	err := CheckActive()
+	if err != nil {
+		t.Fatalf("no active config: got error %v", err)
+	}
+
+	// the first config saved becomes the active one.
+	SetLocation("work")
+	err = (&Config{}).Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	active, err := Active()
+	if want := "/home/user/.config/mailconf/sets/work"; err != nil || active != want {
+		t.Fatalf("got active %q (%v), want: %q", active, err, want)
+	}
+	if err := CheckActive(); err != nil {
+		t.Fatalf("active config: got error %v", err)
+	}
+
+	SetLocation("home")
+	err = (&Config{}).Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	err = CheckActive()
+	if !errors.Is(err, ErrInactive) {
+		t.Fatalf("inactive config: got error %v, want: %v", err, ErrInactive)
+	}
+	old, err := ReadActive()
+	if err != nil || old == nil {
+		t.Fatalf("cannot read the active config: %v", err)
+	}
+	if Location() != "home" {
+		t.Fatalf("got location %q after reading the active config, want: home", Location())
+	}
+
+	err = Activate()
+	if err != nil {
+		t.Fatalf("cannot activate config: %v", err)
+	}
+	if err := CheckActive(); err != nil {
+		t.Fatalf("activated config: got error %v", err)
+	}
+	if old, err := ReadActive(); old != nil || err != nil {
+		t.Fatalf("got active config %+v (%v), want none besides the selected one", old, err)
+	}
+}
+
+func TestDetached(t *testing.T) {
+	defer SetLocation("")
+	setup("")
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+
+	SetLocation("/srv/mail/conf")
+	err := (&Config{}).Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	if _, err := os.ReadFile("/home/user/.config/mailconf/.active"); err == nil {
+		t.Fatalf("detached config recorded in the user config dir")
+	}
+	active, err := Active()
+	if want := "/srv/mail/conf"; err != nil || active != want {
+		t.Fatalf("got active %q (%v), want: %q", active, err, want)
+	}
+	err = CheckActive()
+	if !errors.Is(err, ErrDetached) {
+		t.Fatalf("detached config: got error %v, want: %v", err, ErrDetached)
+	}
+
+	// the default config, named by its path, is not detached.
+	SetLocation("~/.config/mailconf")
+	if err := CheckActive(); err != nil {
+		t.Fatalf("default config: got error %v", err)
+	}
+}
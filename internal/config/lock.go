@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var ErrLocked = errors.New("another mailconf is running")
+
+// Lock takes the advisory lock on the config dir, which commands hold
+// for their whole read-modify-generate-save cycle, and returns the
+// function releasing it. If the lock is held by another mailconf, Lock
+// fails with ErrLocked. In dry run mode nothing is written, so nothing
+// is locked.
+func Lock() (func(), error) {
+	if options.Dryrun() {
+		return func() {}, nil
+	}
+	cfgdir, err := configdir()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(cfgdir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	lockfile := path.Join(cfgdir, ".lock")
+	unlock, err := os.Lock(lockfile)
+	if errors.Is(err, os.ErrLocked) {
+		return nil, fmt.Errorf("%w (%s is locked)", ErrLocked, lockfile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock %s: %w", lockfile, err)
+	}
+	return unlock, nil
+}
+
+// ErrNoConfig is returned by Load and LockAndRead when there is no
+// configuration yet.
+var ErrNoConfig = errors.New("Missing config file.")
+
+// Load reads the configuration for a command, telling the user on
+// stderr why it cannot: if there is no configuration yet, it fails
+// with ErrNoConfig.
+func Load() (*Config, error) {
+	cfg, err := Read()
+	if errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "missing config: run \"mailconf setup\" first.\n")
+		return nil, ErrNoConfig
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read config: %v\n", err)
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LockAndRead takes the lock with Lock and reads the configuration
+// with Load, as commands changing the configuration do. The lock is
+// released if the configuration cannot be read; otherwise unlock must
+// be called once the configuration is saved.
+func LockAndRead() (cfg *Config, unlock func(), err error) {
+	unlock, err = Lock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return nil, nil, err
+	}
+	cfg, err = Load()
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return cfg, unlock, nil
+}
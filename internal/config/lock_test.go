@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+func TestLock(t *testing.T) {
+	setup("")
+	unlock, err := Lock()
+	if err != nil {
+		t.Fatalf("cannot lock: %v", err)
+	}
+	_, err = Lock()
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("got error %v, want: %v", err, ErrLocked)
+	}
+	unlock()
+
+	unlock, err = Lock()
+	if err != nil {
+		t.Fatalf("cannot lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+func TestLockAndRead(t *testing.T) {
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+	os.Stderr = nil
+
+	setup("")
+	_, _, err := LockAndRead()
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("got error %v, want: %v", err, ErrNoConfig)
+	}
+	// the lock is released when the config cannot be read.
+	unlock, err := Lock()
+	if err != nil {
+		t.Fatalf("cannot lock after a failed read: %v", err)
+	}
+	unlock()
+
+	setup(`{"profiles": [` + work + `]}`)
+	cfg, unlock, err := LockAndRead()
+	if err != nil {
+		t.Fatalf("cannot read config: %v", err)
+	}
+	if len(cfg.Profiles) != 1 {
+		t.Fatalf("got %d profiles, want: 1", len(cfg.Profiles))
+	}
+	_, err = Lock()
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("got error %v, want: %v", err, ErrLocked)
+	}
+	unlock()
+}
+
+func TestSaveAtomic(t *testing.T) {
+	setup(`{"profiles": [` + work + `]}`)
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("cannot read config: %v", err)
+	}
+	err = cfg.Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	infos, err := os.ReadDir("/home/user/.config/mailconf")
+	if err != nil {
+		t.Fatalf("cannot read config dir: %v", err)
+	}
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), ".tmp") {
+			t.Fatalf("temporary file %s left behind", info.Name())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// DefaultMaildirRoot is the maildir root, relative to the home
+// directory, used when the configuration does not set one.
+const DefaultMaildirRoot = "Maildir"
+
+// MaildirRootPath returns the absolute path of the directory holding
+// the maildirs of all the profiles, which mu and notmuch index.
+func (c *Config) MaildirRootPath() string {
+	if c.MaildirRoot != "" {
+		return filepath.Clean(c.MaildirRoot)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, DefaultMaildirRoot)
+}
+
+// MaildirPath returns the absolute path of the maildir of p, which is
+// named after the profile in the maildir root unless p overrides it.
+func (c *Config) MaildirPath(p *Profile) string {
+	if p.Maildir != "" {
+		return filepath.Clean(p.Maildir)
+	}
+	return filepath.Join(c.MaildirRootPath(), p.Name)
+}
+
+// MaildirFolder returns the maildir of p relative to the maildir root,
+// the prefix of its folders in mu4e and notmuch queries.
+func (c *Config) MaildirFolder(p *Profile) string {
+	rel, err := filepath.Rel(c.MaildirRootPath(), c.MaildirPath(p))
+	if err != nil || !inside(rel) {
+		return p.Name
+	}
+	return filepath.ToSlash(rel)
+}
+
+// inside reports whether the relative path rel stays below the
+// directory it is relative to.
+func inside(rel string) bool {
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateMaildirs checks the maildir root and the maildirs of the
+// profiles. Profile maildirs must be in the root, the only directory
+// indexed by mu and notmuch, and cannot contain one another.
+func (c *Config) validateMaildirs() ValidationError {
+	var errs ValidationError
+	if c.MaildirRoot != "" {
+		if !filepath.IsAbs(c.MaildirRoot) {
+			errs = append(errs, &FieldError{Field: "maildir_root", Msg: "must be an absolute path"})
+		} else if strings.ContainsAny(c.MaildirRoot, " \t\n\"") {
+			errs = append(errs, &FieldError{Field: "maildir_root", Msg: "contains white space or quotes, which break the mbsync and neomutt configs"})
+		}
+	}
+	root := c.MaildirRootPath()
+	var checked []*Profile
+	for _, p := range c.Profiles {
+		if p.Maildir != "" {
+			switch {
+			case !filepath.IsAbs(p.Maildir):
+				errs = append(errs, &FieldError{p.Name, "maildir", "must be an absolute path"})
+				continue
+			case strings.ContainsAny(p.Maildir, " \t\n\""):
+				errs = append(errs, &FieldError{p.Name, "maildir", "contains white space or quotes, which break the mbsync and neomutt configs"})
+				continue
+			}
+			if rel, err := filepath.Rel(root, c.MaildirPath(p)); err != nil || !inside(rel) {
+				errs = append(errs, &FieldError{p.Name, "maildir", fmt.Sprintf("must be in the maildir root %s, the only directory mu and notmuch index", root)})
+				continue
+			}
+		}
+		if p.Maildir == "" && len(nameProblems(p.Name)) > 0 {
+			// reported as an invalid name.
+			continue
+		}
+		dir := c.MaildirPath(p)
+		for _, other := range checked {
+			if other.Name == p.Name {
+				// reported as a duplicate name.
+				continue
+			}
+			odir := c.MaildirPath(other)
+			switch {
+			case dir == odir:
+				errs = append(errs, &FieldError{p.Name, "maildir", fmt.Sprintf("%s is also the maildir of profile %q", dir, other.Name)})
+			case within(dir, odir):
+				errs = append(errs, &FieldError{p.Name, "maildir", fmt.Sprintf("%s is inside the maildir of profile %q", dir, other.Name)})
+			case within(odir, dir):
+				errs = append(errs, &FieldError{p.Name, "maildir", fmt.Sprintf("%s contains the maildir of profile %q", dir, other.Name)})
+			}
+		}
+		checked = append(checked, p)
+	}
+	return errs
+}
+
+// within reports whether path is below dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && inside(rel)
+}
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestMaildirFolder(t *testing.T) {
+	cfg := &Config{
+		MaildirRoot: "/home/user/Mail/",
+		Profiles: []*Profile{
+			profile("Work", "jdoe@work.com"),
+			withMaildir(profile("Home", "jdoe@home.org"), "/home/user/Mail/archive/home"),
+		},
+	}
+	tt := []struct {
+		profile *Profile
+		path    string
+		folder  string
+	}{
+		{cfg.Profiles[0], "/home/user/Mail/Work", "Work"},
+		{cfg.Profiles[1], "/home/user/Mail/archive/home", "archive/home"},
+	}
+	for _, tc := range tt {
+		if got := cfg.MaildirPath(tc.profile); got != tc.path {
+			t.Fatalf("%s: got maildir %s, want: %s", tc.profile.Name, got, tc.path)
+		}
+		if got := cfg.MaildirFolder(tc.profile); got != tc.folder {
+			t.Fatalf("%s: got folder %s, want: %s", tc.profile.Name, got, tc.folder)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mbsync collects the mbsync options of a profile. Its zero value is
+// what mailconf always generated: IMAPS, every folder created on the
+// near side and fully synced, with no limit on the messages.
+type Mbsync struct {
+	// SSLType is "IMAPS", "STARTTLS" or "None"; empty means "IMAPS".
+	SSLType string `json:"ssl_type,omitempty" yaml:"ssl_type,omitempty" toml:"ssl_type,omitempty"`
+	// CertificateFile holds the certificates the server is checked
+	// against, besides the system ones.
+	CertificateFile string `json:"certificate_file,omitempty" yaml:"certificate_file,omitempty" toml:"certificate_file,omitempty"`
+	// PipelineDepth is the number of IMAP commands sent at once;
+	// zero leaves the mbsync default.
+	PipelineDepth int `json:"pipeline_depth,omitempty" yaml:"pipeline_depth,omitempty" toml:"pipeline_depth,omitempty"`
+	// MaxSize, such as "10m", keeps larger messages on the server.
+	MaxSize string `json:"max_size,omitempty" yaml:"max_size,omitempty" toml:"max_size,omitempty"`
+	// Create, Sync, MaxMessages and CopyArrivalDate are the defaults
+	// of the channels of the folders, which can override them.
+	Create          string `json:"create,omitempty" yaml:"create,omitempty" toml:"create,omitempty"`
+	Sync            string `json:"sync,omitempty" yaml:"sync,omitempty" toml:"sync,omitempty"`
+	MaxMessages     int    `json:"max_messages,omitempty" yaml:"max_messages,omitempty" toml:"max_messages,omitempty"`
+	CopyArrivalDate bool   `json:"copy_arrival_date,omitempty" yaml:"copy_arrival_date,omitempty" toml:"copy_arrival_date,omitempty"`
+}
+
+// SSLTypes are the valid values of Mbsync.SSLType, besides empty.
+var SSLTypes = []string{"IMAPS", "STARTTLS", "None"}
+
+// CreateModes are the valid values of Create, where Master and Slave
+// are the names mbsync used before 1.4 for Far and Near.
+var CreateModes = []string{"None", "Near", "Far", "Both", "Slave", "Master"}
+
+// SyncOps are the words a Sync value is made of.
+var SyncOps = []string{"None", "All", "Pull", "Push", "New", "ReNew", "Old", "Upgrade", "Gone", "Delete", "Flags", "Full"}
+
+var maxSizeRe = regexp.MustCompile(`^[0-9]+[kKmM]?[bB]?$`)
+
+// MbsyncOptions returns the mbsync options of the profile, which are
+// all defaults if it has no mbsync section.
+func (p *Profile) MbsyncOptions() *Mbsync {
+	if p.Mbsync == nil {
+		return &Mbsync{}
+	}
+	return p.Mbsync
+}
+
+// SSL returns the SSLType of the IMAP account.
+func (m *Mbsync) SSL() string {
+	if m.SSLType == "" {
+		return "IMAPS"
+	}
+	return m.SSLType
+}
+
+// DefaultPort returns the port mbsync connects to when the account
+// does not set one.
+func (m *Mbsync) DefaultPort() uint16 {
+	if m.SSL() == "IMAPS" {
+		return 993
+	}
+	return 143
+}
+
+// Channel returns f with the channel options it does not set taken
+// from the profile, or from the defaults: Create Near and Sync All.
+func (p *Profile) Channel(f *Folder) *Folder {
+	m := p.MbsyncOptions()
+	ch := *f
+	if ch.Create == "" {
+		ch.Create = m.Create
+	}
+	if ch.Create == "" {
+		ch.Create = "Near"
+	}
+	if ch.Sync == "" {
+		ch.Sync = m.Sync
+	}
+	if ch.Sync == "" {
+		ch.Sync = "All"
+	}
+	if ch.MaxMessages == 0 {
+		ch.MaxMessages = m.MaxMessages
+	}
+	ch.CopyArrivalDate = ch.CopyArrivalDate || m.CopyArrivalDate
+	return &ch
+}
+
+// MbsyncAtLeast reports whether the version of mbsync is known and is
+// at least version, such as "1.4".
+func (c *Config) MbsyncAtLeast(version string) bool {
+	return AtLeast(c.MbsyncVersion, version)
+}
+
+func (m *Mbsync) validate() []string {
+	var msgs []string
+	if m.SSLType != "" && !oneOf(m.SSLType, SSLTypes) {
+		msgs = append(msgs, fmt.Sprintf("ssl_type %q is none of %v", m.SSLType, SSLTypes))
+	}
+	if m.CertificateFile != "" && !filepath.IsAbs(m.CertificateFile) {
+		msgs = append(msgs, "certificate_file must be an absolute path")
+	}
+	if m.PipelineDepth < 0 {
+		msgs = append(msgs, "pipeline_depth cannot be negative")
+	}
+	if m.MaxSize != "" && !maxSizeRe.MatchString(m.MaxSize) {
+		msgs = append(msgs, fmt.Sprintf("max_size %q is not a size such as 10m", m.MaxSize))
+	}
+	msgs = append(msgs, channelProblems(m.Create, m.Sync, m.MaxMessages)...)
+	return msgs
+}
+
+// channelProblems explains why the channel options create, sync and
+// maxMessages are not valid.
+func channelProblems(create, sync string, maxMessages int) []string {
+	var msgs []string
+	if create != "" && !oneOf(create, CreateModes) {
+		msgs = append(msgs, fmt.Sprintf("create %q is none of %v", create, CreateModes))
+	}
+	for _, op := range strings.Fields(sync) {
+		if !oneOf(op, SyncOps) {
+			msgs = append(msgs, fmt.Sprintf("sync %q is none of %v", op, SyncOps))
+			break
+		}
+	}
+	if maxMessages < 0 {
+		msgs = append(msgs, "max_messages cannot be negative")
+	}
+	return msgs
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannel(t *testing.T) {
+	p := profile("Work", "jdoe@work.com")
+	p.Mbsync = &Mbsync{Sync: "Pull", MaxMessages: 1000, CopyArrivalDate: true}
+	tt := []struct {
+		folder *Folder
+		want   *Folder
+	}{
+		{
+			&Folder{Name: "inbox", Remote: "INBOX", Local: "INBOX"},
+			&Folder{Name: "inbox", Remote: "INBOX", Local: "INBOX", Create: "Near", Sync: "Pull", MaxMessages: 1000, CopyArrivalDate: true},
+		},
+		{
+			&Folder{Name: "sent", Remote: "Sent", Local: "sent", Create: "Both", Sync: "All", MaxMessages: 10},
+			&Folder{Name: "sent", Remote: "Sent", Local: "sent", Create: "Both", Sync: "All", MaxMessages: 10, CopyArrivalDate: true},
+		},
+	}
+	for _, tc := range tt {
+		if got := p.Channel(tc.folder); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %+v, want: %+v", tc.folder.Name, *got, *tc.want)
+		}
+	}
+	if got := (&Profile{}).Channel(&Folder{}); got.Create != "Near" || got.Sync != "All" {
+		t.Fatalf("got defaults %+v, want: Create Near, Sync All", *got)
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+)
+
+// A migration upgrades a decoded document by one version, in place.
+type migration func(doc map[string]any) error
+
+// migrations[n] upgrades a document from version n to version n+1.
+// Append new steps, never edit the existing ones: users may skip
+// any number of releases.
+var migrations = []migration{
+	// documents written before versioning was introduced carry no
+	// "version" key; their layout is the one of version 1.
+	func(doc map[string]any) error {
+		return nil
+	},
+}
+
+// CurrentVersion is the version of the documents written by Save.
+var CurrentVersion = len(migrations)
+
+// migrate upgrades doc to CurrentVersion, returning the version doc
+// had originally.
+func migrate(doc map[string]any) (int, error) {
+	from, err := version(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > CurrentVersion {
+		return from, fmt.Errorf("version %d, this mailconf supports up to version %d: %w", from, CurrentVersion, ErrFutureVersion)
+	}
+	for v := from; v < CurrentVersion; v++ {
+		err := migrations[v](doc)
+		if err != nil {
+			return from, fmt.Errorf("cannot upgrade from version %d to %d: %w", v, v+1, err)
+		}
+		doc["version"] = v + 1
+	}
+	return from, nil
+}
+
+func version(doc map[string]any) (int, error) {
+	v, ok := doc["version"]
+	if !ok {
+		return 0, nil
+	}
+	var n float64
+	switch v := v.(type) {
+	case float64:
+		n = v
+	case int:
+		n = float64(v)
+	case int64:
+		n = float64(v)
+	default:
+		return 0, fmt.Errorf("invalid version %v", v)
+	}
+	if n < 0 || n != float64(int(n)) {
+		return 0, fmt.Errorf("invalid version %v", v)
+	}
+	return int(n), nil
+}
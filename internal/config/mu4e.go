@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mu4e collects the preferences written to mu4e.el. Its zero value
+// needs nothing but mu4e itself: the Emacs default browser, no global
+// key binding, the first context picked and the default bookmarks.
+type Mu4e struct {
+	// Browser opens links and HTML messages: "chrome", "firefox",
+	// "eww", or empty for the Emacs default.
+	Browser string `json:"browser,omitempty" yaml:"browser,omitempty" toml:"browser,omitempty"`
+	// ChromeProfile is the Chrome profile directory, such as
+	// "Profile 1", used when Browser is "chrome".
+	ChromeProfile string `json:"chrome_profile,omitempty" yaml:"chrome_profile,omitempty" toml:"chrome_profile,omitempty"`
+	// Keybinding is the global key starting mu4e, in kbd syntax such
+	// as "C-c m"; empty binds no key.
+	Keybinding string `json:"keybinding,omitempty" yaml:"keybinding,omitempty" toml:"keybinding,omitempty"`
+	// ContextPolicy is mu4e-context-policy; empty means "pick-first".
+	ContextPolicy string `json:"context_policy,omitempty" yaml:"context_policy,omitempty" toml:"context_policy,omitempty"`
+	// ComposeContextPolicy is mu4e-compose-context-policy; empty
+	// keeps the current context.
+	ComposeContextPolicy string `json:"compose_context_policy,omitempty" yaml:"compose_context_policy,omitempty" toml:"compose_context_policy,omitempty"`
+	// ComposeInNewFrame composes messages in a new frame.
+	ComposeInNewFrame bool `json:"compose_in_new_frame,omitempty" yaml:"compose_in_new_frame,omitempty" toml:"compose_in_new_frame,omitempty"`
+	// Signature is the signature of every context; empty signs with
+	// the full name of the profile.
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty" toml:"signature,omitempty"`
+	// Bookmarks replace DefaultBookmarks.
+	Bookmarks []*Bookmark `json:"bookmarks,omitempty" yaml:"bookmarks,omitempty" toml:"bookmarks,omitempty"`
+	// ContextKeys maps profile names to the global key, in kbd
+	// syntax, switching to their context.
+	ContextKeys map[string]string `json:"context_keys,omitempty" yaml:"context_keys,omitempty" toml:"context_keys,omitempty"`
+}
+
+// Bookmark is a mu4e bookmark. Each context restricts its query to the
+// inbox and sent folders of its profile.
+type Bookmark struct {
+	Name  string `json:"name" yaml:"name" toml:"name"`
+	Query string `json:"query" yaml:"query" toml:"query"`
+	// Key is the letter or digit selecting the bookmark.
+	Key string `json:"key" yaml:"key" toml:"key"`
+}
+
+// ContextPolicies are the valid values of mu4e-context-policy and
+// mu4e-compose-context-policy.
+var ContextPolicies = []string{"pick-first", "ask", "ask-if-none", "always-ask"}
+
+// Browsers are the valid values of Mu4e.Browser, besides empty.
+var Browsers = []string{"chrome", "firefox", "eww"}
+
+// DefaultBookmarks returns the bookmarks of the contexts when the
+// configuration does not set any.
+func DefaultBookmarks() []*Bookmark {
+	return []*Bookmark{
+		{Name: "Last 7 days messages", Query: "date:1w..now AND NOT flag:trashed", Key: "w"},
+		{Name: "Yesterday and today messages", Query: "date:1d..now AND NOT flag:trashed", Key: "b"},
+		{Name: "Unread messages", Query: "flag:unread AND NOT flag:trashed", Key: "u"},
+		{Name: "Today's messages", Query: "date:today..now AND NOT flag:trashed", Key: "t"},
+	}
+}
+
+// Mu4eOptions returns the mu4e preferences, which are all defaults if
+// the configuration has no mu4e section.
+func (c *Config) Mu4eOptions() *Mu4e {
+	if c.Mu4e == nil {
+		return &Mu4e{}
+	}
+	return c.Mu4e
+}
+
+// Policy returns mu4e-context-policy.
+func (m *Mu4e) Policy() string {
+	if m.ContextPolicy == "" {
+		return "pick-first"
+	}
+	return m.ContextPolicy
+}
+
+// BookmarkList returns the bookmarks, or DefaultBookmarks if none is
+// set.
+func (m *Mu4e) BookmarkList() []*Bookmark {
+	if len(m.Bookmarks) == 0 {
+		return DefaultBookmarks()
+	}
+	return m.Bookmarks
+}
+
+// ContextKey returns the key switching to the context of the profile
+// called name, or an empty string.
+func (m *Mu4e) ContextKey(name string) string {
+	if m == nil {
+		return ""
+	}
+	return m.ContextKeys[name]
+}
+
+// SetContextKey binds key to the context of the profile called name,
+// or removes its binding if key is empty.
+func (m *Mu4e) SetContextKey(name, key string) {
+	if m == nil {
+		return
+	}
+	if key == "" {
+		delete(m.ContextKeys, name)
+		if len(m.ContextKeys) == 0 {
+			m.ContextKeys = nil
+		}
+		return
+	}
+	if m.ContextKeys == nil {
+		m.ContextKeys = make(map[string]string)
+	}
+	m.ContextKeys[name] = key
+}
+
+// RenameContext moves the context key of the profile called oldname to
+// newname.
+func (m *Mu4e) RenameContext(oldname, newname string) {
+	key := m.ContextKey(oldname)
+	if key == "" {
+		return
+	}
+	m.SetContextKey(oldname, "")
+	m.SetContextKey(newname, key)
+}
+
+// Validate checks the mu4e preferences, returning a ValidationError
+// listing every problem found. Context keys are only checked against
+// the profiles by Config.Validate.
+func (m *Mu4e) Validate() error {
+	errs := m.validate()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (m *Mu4e) validate() ValidationError {
+	var errs ValidationError
+	add := func(field, msg string) {
+		errs = append(errs, &FieldError{Field: "mu4e." + field, Msg: msg})
+	}
+
+	if m.Browser != "" && !oneOf(m.Browser, Browsers) {
+		add("browser", fmt.Sprintf("%q is none of %v", m.Browser, Browsers))
+	}
+	if m.ContextPolicy != "" && !oneOf(m.ContextPolicy, ContextPolicies) {
+		add("context_policy", fmt.Sprintf("%q is none of %v", m.ContextPolicy, ContextPolicies))
+	}
+	if m.ComposeContextPolicy != "" && !oneOf(m.ComposeContextPolicy, ContextPolicies) {
+		add("compose_context_policy", fmt.Sprintf("%q is none of %v", m.ComposeContextPolicy, ContextPolicies))
+	}
+	keys := make(map[string]bool)
+	for _, b := range m.Bookmarks {
+		switch {
+		case b.Name == "" || b.Query == "":
+			add("bookmarks", fmt.Sprintf("bookmark %q must set both name and query", b.Name))
+		case !bookmarkKey(b.Key):
+			add("bookmarks", fmt.Sprintf("bookmark %q: key %q is not a single letter or digit", b.Name, b.Key))
+		case keys[b.Key]:
+			add("bookmarks", fmt.Sprintf("bookmark %q: key %q is used twice", b.Name, b.Key))
+		}
+		keys[b.Key] = true
+	}
+	return errs
+}
+
+func bookmarkKey(key string) bool {
+	if len(key) != 1 {
+		return false
+	}
+	r := key[0]
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+func oneOf(s string, list []string) bool {
+	for _, v := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// MuAtLeast reports whether the version of mu is known and is at least
+// version, such as "1.8".
+func (c *Config) MuAtLeast(version string) bool {
+	return AtLeast(c.MuVersion, version)
+}
+
+// AtLeast reports whether version, such as "1.8.13", is known and is
+// at least min.
+func AtLeast(version, min string) bool {
+	have, want := parseVersion(version), parseVersion(min)
+	if have == nil || want == nil {
+		return false
+	}
+	for i := range want {
+		if i >= len(have) {
+			return false
+		}
+		if have[i] != want[i] {
+			return have[i] > want[i]
+		}
+	}
+	return true
+}
+
+// parseVersion returns the numbers of a version such as "1.8.13", or
+// nil if version does not start with one.
+func parseVersion(version string) []int {
+	var nums []int
+	for _, field := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+	}
+	return nums
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestMuAtLeast(t *testing.T) {
+	tt := []struct {
+		have string
+		want string
+		ok   bool
+	}{
+		{"1.8.13", "1.8", true},
+		{"1.8", "1.8", true},
+		{"1.10.8", "1.8", true},
+		{"1.6.10", "1.8", false},
+		{"1", "1.8", false},
+		{"", "1.8", false},
+		{"1.12.0-rc1", "1.12", true},
+	}
+	for _, tc := range tt {
+		cfg := &Config{MuVersion: tc.have}
+		if got := cfg.MuAtLeast(tc.want); got != tc.ok {
+			t.Fatalf("%q at least %q: got %v, want: %v", tc.have, tc.want, got, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rule is an imapfilter rule of a profile: the messages in Mailbox
+// matching every criterion of Match get Actions, in order.
+type Rule struct {
+	// Name describes the rule in the generated config and in
+	// "mailconf filter test".
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// Mailbox is the remote mailbox filtered; empty means INBOX.
+	Mailbox string    `json:"mailbox,omitempty" yaml:"mailbox,omitempty" toml:"mailbox,omitempty"`
+	Match   *Match    `json:"match" yaml:"match" toml:"match"`
+	Actions []*Action `json:"actions" yaml:"actions" toml:"actions"`
+}
+
+func (r *Rule) copy() *Rule {
+	c := *r
+	if r.Match != nil {
+		match := *r.Match
+		match.Header = nil
+		for name, text := range r.Match.Header {
+			if match.Header == nil {
+				match.Header = make(map[string]string)
+			}
+			match.Header[name] = text
+		}
+		c.Match = &match
+	}
+	c.Actions = nil
+	for _, a := range r.Actions {
+		action := *a
+		c.Actions = append(c.Actions, &action)
+	}
+	return &c
+}
+
+// Match holds the criteria of a rule, as in IMAP SEARCH: the text ones
+// match the messages whose header contains them, ignoring case.
+type Match struct {
+	From    string `json:"from,omitempty" yaml:"from,omitempty" toml:"from,omitempty"`
+	To      string `json:"to,omitempty" yaml:"to,omitempty" toml:"to,omitempty"`
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty" toml:"subject,omitempty"`
+	// Header maps header names, such as "List-Id", to the text they
+	// must contain.
+	Header map[string]string `json:"header,omitempty" yaml:"header,omitempty" toml:"header,omitempty"`
+	// LargerThan and SmallerThan are sizes in bytes.
+	LargerThan  int `json:"larger_than,omitempty" yaml:"larger_than,omitempty" toml:"larger_than,omitempty"`
+	SmallerThan int `json:"smaller_than,omitempty" yaml:"smaller_than,omitempty" toml:"smaller_than,omitempty"`
+	// OlderThan and NewerThan are ages in days.
+	OlderThan int `json:"older_than,omitempty" yaml:"older_than,omitempty" toml:"older_than,omitempty"`
+	NewerThan int `json:"newer_than,omitempty" yaml:"newer_than,omitempty" toml:"newer_than,omitempty"`
+}
+
+// Action is done on the messages matching a rule.
+type Action struct {
+	// Action is one of Actions.
+	Action string `json:"action" yaml:"action" toml:"action"`
+	// Mailbox is where move and copy put the messages.
+	Mailbox string `json:"mailbox,omitempty" yaml:"mailbox,omitempty" toml:"mailbox,omitempty"`
+	// Profile names the profile owning Mailbox, for moving or
+	// copying to another account; empty means the one of the rule.
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty" toml:"profile,omitempty"`
+}
+
+// Actions are the valid values of Action.Action.
+var Actions = []string{"move", "copy", "delete", "flag", "mark_seen"}
+
+// RuleMailbox returns the mailbox filtered by r.
+func (r *Rule) RuleMailbox() string {
+	if r.Mailbox == "" {
+		return "INBOX"
+	}
+	return r.Mailbox
+}
+
+// Headers returns the names in Header, sorted.
+func (m *Match) Headers() []string {
+	names := make([]string, 0, len(m.Header))
+	for name := range m.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Match) empty() bool {
+	return m.From == "" && m.To == "" && m.Subject == "" && len(m.Header) == 0 &&
+		m.LargerThan == 0 && m.SmallerThan == 0 && m.OlderThan == 0 && m.NewerThan == 0
+}
+
+// String returns the action as written by "mailconf filter test",
+// such as "move Archive" or "copy Home:Archive".
+func (a *Action) String() string {
+	switch {
+	case a.Profile != "":
+		return fmt.Sprintf("%s %s:%s", a.Action, a.Profile, a.Mailbox)
+	case a.Mailbox != "":
+		return a.Action + " " + a.Mailbox
+	}
+	return a.Action
+}
+
+// Removes reports whether the action takes the messages out of the
+// mailbox of the rule.
+func (a *Action) Removes() bool {
+	return a.Action == "move" || a.Action == "delete"
+}
+
+// Targets returns the names of the profiles, besides the one of the
+// rules, that the actions of rules move or copy messages to.
+func Targets(rules []*Rule) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		for _, a := range r.Actions {
+			if a.Profile != "" && !seen[a.Profile] {
+				seen[a.Profile] = true
+				names = append(names, a.Profile)
+			}
+		}
+	}
+	return names
+}
+
+func (r *Rule) validate() []string {
+	var msgs []string
+	if strings.TrimSpace(r.Name) == "" || strings.ContainsAny(r.Name, "\r\n") {
+		msgs = append(msgs, fmt.Sprintf("rule name %q is not a single line", r.Name))
+	}
+	if r.Match == nil || r.Match.empty() {
+		msgs = append(msgs, fmt.Sprintf("rule %q matches every message: set a criterion", r.Name))
+	} else {
+		m := r.Match
+		for _, header := range m.Headers() {
+			if header == "" || strings.ContainsAny(header, ": \t\r\n") {
+				msgs = append(msgs, fmt.Sprintf("rule %q: %q is not a header name", r.Name, header))
+			}
+		}
+		if m.LargerThan < 0 || m.SmallerThan < 0 || m.OlderThan < 0 || m.NewerThan < 0 {
+			msgs = append(msgs, fmt.Sprintf("rule %q: sizes and ages cannot be negative", r.Name))
+		}
+	}
+	if len(r.Actions) == 0 {
+		msgs = append(msgs, fmt.Sprintf("rule %q has no actions", r.Name))
+	}
+	for i, a := range r.Actions {
+		switch {
+		case !oneOf(a.Action, Actions):
+			msgs = append(msgs, fmt.Sprintf("rule %q: action %q is none of %v", r.Name, a.Action, Actions))
+		case (a.Action == "move" || a.Action == "copy") && a.Mailbox == "":
+			msgs = append(msgs, fmt.Sprintf("rule %q: %s needs a mailbox", r.Name, a.Action))
+		case a.Action != "move" && a.Action != "copy" && (a.Mailbox != "" || a.Profile != ""):
+			msgs = append(msgs, fmt.Sprintf("rule %q: %s takes no mailbox", r.Name, a.Action))
+		case a.Removes() && i != len(r.Actions)-1:
+			msgs = append(msgs, fmt.Sprintf("rule %q: %s must be the last action", r.Name, a.Action))
+		}
+	}
+	return msgs
+}
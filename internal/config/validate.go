@@ -0,0 +1,321 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FieldError reports an invalid field of the configuration. Profile
+// is empty for the fields shared by all the profiles.
+type FieldError struct {
+	Profile string
+	Field   string
+	Msg     string
+}
+
+func (e *FieldError) Error() string {
+	if e.Profile != "" {
+		return fmt.Sprintf("profile %q: %s: %s", e.Profile, e.Field, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Msg)
+}
+
+// ValidationError lists all the invalid fields found by Validate.
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid configuration:\n\t" + strings.Join(msgs, "\n\t")
+}
+
+// Validate checks the whole configuration, returning a
+// ValidationError listing every problem found.
+func (c *Config) Validate() error {
+	var errs ValidationError
+
+	if c.EmacsCfgDir != "" && !filepath.IsAbs(c.EmacsCfgDir) {
+		errs = append(errs, &FieldError{Field: "emacs_cfg_dir", Msg: "must be an absolute path"})
+	}
+	if c.BinDir != "" && !filepath.IsAbs(c.BinDir) {
+		errs = append(errs, &FieldError{Field: "bindir", Msg: "must be an absolute path"})
+	}
+	if c.Mu4eDir != "" && !filepath.IsAbs(c.Mu4eDir) {
+		errs = append(errs, &FieldError{Field: "mu4e_dir", Msg: "must be an absolute path"})
+	}
+	if c.MuVersion != "" && parseVersion(c.MuVersion) == nil {
+		errs = append(errs, &FieldError{Field: "mu_version", Msg: fmt.Sprintf("%q is not a version number", c.MuVersion)})
+	}
+	if c.MbsyncVersion != "" && parseVersion(c.MbsyncVersion) == nil {
+		errs = append(errs, &FieldError{Field: "mbsync_version", Msg: fmt.Sprintf("%q is not a version number", c.MbsyncVersion)})
+	}
+	if c.SyncInterval != 0 && c.SyncInterval < MinSyncInterval {
+		errs = append(errs, &FieldError{Field: "sync_interval", Msg: fmt.Sprintf("must be at least %d seconds", MinSyncInterval)})
+	}
+	switch c.Frontend {
+	case "", "mu4e", "notmuch":
+	default:
+		errs = append(errs, &FieldError{Field: "frontend", Msg: fmt.Sprintf("%q is neither mu4e nor notmuch", c.Frontend)})
+	}
+	clients := make(map[string]bool)
+	for _, client := range c.Clients {
+		switch {
+		case client != "aerc" && client != "neomutt":
+			errs = append(errs, &FieldError{Field: "clients", Msg: fmt.Sprintf("%q is neither aerc nor neomutt", client)})
+		case clients[client]:
+			errs = append(errs, &FieldError{Field: "clients", Msg: fmt.Sprintf("%s is listed twice", client)})
+		}
+		clients[client] = true
+	}
+	if c.Mu4e != nil {
+		errs = append(errs, c.Mu4e.validate()...)
+	}
+	errs = append(errs, c.validateMaildirs()...)
+
+	names := make(map[string]bool)
+	emails := make(map[string]string)
+	for _, p := range c.Profiles {
+		errs = append(errs, p.validate()...)
+		if names[p.Name] {
+			errs = append(errs, &FieldError{p.Name, "profile_name", "is used by more than one profile"})
+		}
+		names[p.Name] = true
+		email := strings.ToLower(p.Email)
+		if other, ok := emails[email]; ok && email != "" {
+			errs = append(errs, &FieldError{p.Name, "email", fmt.Sprintf("%s is already used by profile %q", p.Email, other)})
+		} else {
+			emails[email] = p.Name
+		}
+		for _, id := range p.Identities {
+			email := strings.ToLower(id.Email)
+			if other, ok := emails[email]; ok && email != "" {
+				errs = append(errs, &FieldError{p.Name, "identities", fmt.Sprintf("%s is already used by profile %q", id.Email, other)})
+			} else {
+				emails[email] = p.Name
+			}
+		}
+	}
+	for _, p := range c.Profiles {
+		for _, target := range Targets(p.Rules) {
+			if !names[target] {
+				errs = append(errs, &FieldError{p.Name, "rules", fmt.Sprintf("there is no profile %q", target)})
+			}
+		}
+	}
+	if c.Mu4e != nil {
+		profiles := make([]string, 0, len(c.Mu4e.ContextKeys))
+		for name := range c.Mu4e.ContextKeys {
+			profiles = append(profiles, name)
+		}
+		sort.Strings(profiles)
+		bound := make(map[string]string)
+		for _, name := range profiles {
+			key := c.Mu4e.ContextKeys[name]
+			switch {
+			case !names[name]:
+				errs = append(errs, &FieldError{Field: "mu4e.context_keys", Msg: fmt.Sprintf("there is no profile %q", name)})
+			case strings.TrimSpace(key) == "":
+				errs = append(errs, &FieldError{Field: "mu4e.context_keys", Msg: fmt.Sprintf("the key of %q is empty", name)})
+			case bound[key] != "":
+				errs = append(errs, &FieldError{Field: "mu4e.context_keys", Msg: fmt.Sprintf("%s switches to both %q and %q", key, bound[key], name)})
+			}
+			bound[key] = name
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks the fields of the profile, name included, returning
+// a ValidationError listing every problem found.
+func (p *Profile) Validate() error {
+	errs := p.validate()
+	if p.Name != "" {
+		errs = append(p.nameErrors(), errs...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *Profile) validate() ValidationError {
+	var errs ValidationError
+	add := func(field, msg string) {
+		errs = append(errs, &FieldError{p.Name, field, msg})
+	}
+
+	if p.Name == "" {
+		add("profile_name", "is empty")
+	}
+	if _, err := mail.ParseAddress(p.Email); err != nil {
+		add("email", fmt.Sprintf("%q is not a valid address", p.Email))
+	}
+	if strings.TrimSpace(p.ImapHost) == "" {
+		add("imaphost", "is empty")
+	} else if strings.ContainsAny(p.ImapHost, " \t/") {
+		add("imaphost", fmt.Sprintf("%q is not a valid host name", p.ImapHost))
+	}
+	if p.ImapPort == 0 {
+		add("imapport", "must be between 1 and 65535")
+	}
+	if strings.TrimSpace(p.ImapUser) == "" {
+		add("imapuser", "is empty")
+	}
+	if strings.TrimSpace(p.SmtpHost) == "" {
+		add("smtphost", "is empty")
+	} else if strings.ContainsAny(p.SmtpHost, " \t/") {
+		add("smtphost", fmt.Sprintf("%q is not a valid host name", p.SmtpHost))
+	}
+	if p.SmtpPort == 0 {
+		add("smtpport", "must be between 1 and 65535")
+	}
+	if strings.TrimSpace(p.SmtpUser) == "" {
+		add("smtpuser", "is empty")
+	}
+	if p.SyncInterval != 0 && p.SyncInterval < MinSyncInterval {
+		add("sync_interval", fmt.Sprintf("must be at least %d seconds", MinSyncInterval))
+	}
+	if p.SignatureFile != "" && !filepath.IsAbs(p.SignatureFile) {
+		add("signature_file", "must be an absolute path")
+	}
+	if p.Mbsync != nil {
+		for _, msg := range p.Mbsync.validate() {
+			add("mbsync", msg)
+		}
+	}
+	remotes := make(map[string]string)
+	for _, f := range p.FolderMap() {
+		remotes[f.Remote] = f.Name
+	}
+	for _, pattern := range p.Include {
+		if name, ok := remotes[pattern]; ok {
+			add("include", fmt.Sprintf("%q is already synced by folder %q", pattern, name))
+		}
+	}
+	for _, field := range []struct {
+		name     string
+		patterns []string
+	}{{"include", p.Include}, {"exclude", p.Exclude}} {
+		for _, pattern := range field.patterns {
+			if strings.TrimSpace(pattern) == "" || strings.ContainsAny(pattern, "\"\n") || strings.HasPrefix(pattern, "!") {
+				add(field.name, fmt.Sprintf("%q is not a valid pattern", pattern))
+			}
+		}
+	}
+	for _, r := range p.Rules {
+		for _, msg := range r.validate() {
+			add("rules", msg)
+		}
+	}
+	for _, id := range p.Identities {
+		if _, err := mail.ParseAddress(id.Email); err != nil {
+			add("identities", fmt.Sprintf("%q is not a valid address", id.Email))
+		}
+		if id.SignatureFile != "" && !filepath.IsAbs(id.SignatureFile) {
+			add("identities", fmt.Sprintf("%s: signature_file must be an absolute path", id.Email))
+		}
+	}
+	names := make(map[string]bool)
+	for _, f := range p.Folders {
+		switch {
+		case f.Name == "":
+			add("folders", "folder name is empty")
+		case strings.ContainsAny(f.Name, " \t/:"):
+			add("folders", fmt.Sprintf("folder name %q cannot be used in a channel name", f.Name))
+		case names[f.Name]:
+			add("folders", fmt.Sprintf("folder %q is defined twice", f.Name))
+		case f.Name == PatternsChannel && len(p.Include) > 0:
+			add("folders", fmt.Sprintf("folder %q has the name of the channel syncing include", f.Name))
+		}
+		names[f.Name] = true
+		if (f.Remote == "" || f.Local == "") && len(f.Patterns) == 0 {
+			add("folders", fmt.Sprintf("folder %q must set both remote and local", f.Name))
+		}
+		for _, pattern := range f.Patterns {
+			if strings.TrimSpace(pattern) == "" || strings.Contains(pattern, "\"") {
+				add("folders", fmt.Sprintf("folder %q: %q is not a valid pattern", f.Name, pattern))
+			}
+		}
+		for _, msg := range channelProblems(f.Create, f.Sync, f.MaxMessages) {
+			add("folders", fmt.Sprintf("folder %q: %s", f.Name, msg))
+		}
+		switch f.Expunge {
+		case "", "None", "Both", "Slave", "Master", "Near", "Far":
+		default:
+			add("folders", fmt.Sprintf("folder %q: %q is not a valid expunge mode", f.Name, f.Expunge))
+		}
+	}
+	return errs
+}
+
+// ValidateName checks that name can be used as a profile name.
+func ValidateName(name string) error {
+	p := &Profile{Name: name}
+	errs := p.nameErrors()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Warnings lists the profiles whose name is not safe to use, which
+// Validate does not report: names are checked by ValidateName when
+// profiles are added or renamed, and a profile named before a check
+// was introduced must still be readable to be renamed or removed.
+func (c *Config) Warnings() ValidationError {
+	var errs ValidationError
+	for _, p := range c.Profiles {
+		if p.Name != "" {
+			errs = append(errs, p.nameErrors()...)
+		}
+	}
+	return errs
+}
+
+func (p *Profile) nameErrors() ValidationError {
+	var errs ValidationError
+	for _, msg := range nameProblems(p.Name) {
+		errs = append(errs, &FieldError{p.Name, "profile_name", msg})
+	}
+	return errs
+}
+
+// nameProblems explains why name cannot be used as a profile name.
+// Profile names end up in maildir paths (<maildir root>/<name>), in the
+// mbsync channel names, in the instance of the systemd units
+// imapnotify@<name>.service and mbsync@<name>.timer, escaped as
+// systemd-escape does, and in the launchd label local.imapnotify.<name>.
+func nameProblems(name string) []string {
+	var msgs []string
+	if name == "" {
+		return []string{"is empty"}
+	}
+	if strings.ContainsAny(name, "/\\") {
+		msgs = append(msgs, "contains a slash, which would split the maildir path")
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		msgs = append(msgs, "contains white space, which breaks mbsync channels and systemd unit names")
+	}
+	if strings.HasPrefix(name, ".") {
+		msgs = append(msgs, "starts with a dot, which hides the maildir")
+	}
+	if strings.Contains(name, ":") {
+		msgs = append(msgs, "contains ':', which mbsync uses to separate store and mailbox")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_.-/\\ \t\n:", r)) {
+			msgs = append(msgs, fmt.Sprintf("contains %q; use only letters, digits, '_', '-' and '.', which are valid in launchd labels", r))
+			break
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func profile(name, email string) *Profile {
+	return &Profile{
+		Name:     name,
+		FullName: "John Doe",
+		Email:    email,
+		ImapHost: "imap.gmail.com",
+		ImapPort: 993,
+		ImapUser: email,
+		SmtpHost: "smtp.gmail.com",
+		SmtpPort: 587,
+		SmtpUser: email,
+	}
+}
+
+func withFolders(p *Profile, folders ...*Folder) *Profile {
+	p.Folders = folders
+	return p
+}
+
+func withInclude(p *Profile, include, exclude []string) *Profile {
+	p.Include, p.Exclude = include, exclude
+	return p
+}
+
+func withRules(p *Profile, rules ...*Rule) *Profile {
+	p.Rules = rules
+	return p
+}
+
+func withInterval(p *Profile, seconds int) *Profile {
+	p.SyncInterval = seconds
+	return p
+}
+
+func withIdentities(p *Profile, identities ...*Identity) *Profile {
+	p.Identities = identities
+	return p
+}
+
+func withMbsync(p *Profile, m *Mbsync) *Profile {
+	p.Mbsync = m
+	return p
+}
+
+func withMaildir(p *Profile, dir string) *Profile {
+	p.Maildir = dir
+	return p
+}
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{
+			"valid",
+			&Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				Profiles: []*Profile{
+					profile("Work", "jdoe@work.com"),
+					profile("personal.gmail", "jdoe@gmail.com"),
+				},
+			},
+			nil,
+		},
+		{
+			"relative dirs",
+			&Config{
+				EmacsCfgDir: "~/.emacs.d",
+				BinDir:      "bin",
+				MaildirRoot: "Mail",
+			},
+			[]string{"emacs_cfg_dir", "bindir", "maildir_root"},
+		},
+		{
+			"duplicates",
+			&Config{
+				Profiles: []*Profile{
+					profile("Work", "jdoe@gmail.com"),
+					profile("Work", "other@gmail.com"),
+					profile("Home", "JDoe@gmail.com"),
+				},
+			},
+			[]string{"profile_name", "email"},
+		},
+		{
+			"unsafe names",
+			&Config{
+				Profiles: []*Profile{
+					profile("my work", "a@gmail.com"),
+					profile("work-gmail", "c@gmail.com"),
+					profile("", "f@gmail.com"),
+				},
+			},
+			[]string{"profile_name"},
+		},
+		{
+			"missing fields",
+			&Config{
+				Profiles: []*Profile{
+					{
+						Name:  "Work",
+						Email: "not an address",
+					},
+				},
+			},
+			[]string{"email", "imaphost", "imapport", "imapuser", "smtphost", "smtpport", "smtpuser"},
+		},
+		{
+			"folders",
+			&Config{
+				Profiles: []*Profile{
+					withFolders(profile("Work", "jdoe@gmail.com"),
+						&Folder{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+						&Folder{Name: "inbox", Remote: "Archive", Local: "archive"},
+						&Folder{Name: "sent mail", Remote: "Sent", Local: ""},
+						&Folder{Name: "trash", Remote: "Trash", Local: "trash", Expunge: "Always"},
+					),
+				},
+			},
+			[]string{"folders", "folders", "folders", "folders"},
+		},
+		{
+			"sync interval",
+			&Config{
+				SyncInterval: 30,
+				Profiles: []*Profile{
+					withInterval(profile("Work", "jdoe@gmail.com"), 59),
+					withInterval(profile("Home", "jdoe@home.org"), 60),
+				},
+			},
+			[]string{"sync_interval", "sync_interval"},
+		},
+		{
+			"identities",
+			&Config{
+				Profiles: []*Profile{
+					withIdentities(profile("Work", "jdoe@gmail.com"),
+						&Identity{Email: "john.doe@gmail.com", SignatureFile: "/home/user/.signature"},
+						&Identity{Email: "not an address"},
+						&Identity{Email: "support@example.com", SignatureFile: "signature"},
+					),
+					withIdentities(profile("Home", "jdoe@home.org"),
+						&Identity{Email: "JDoe@gmail.com"},
+					),
+				},
+			},
+			[]string{"identities", "identities", "identities"},
+		},
+		{
+			"mbsync",
+			&Config{
+				MbsyncVersion: "isync",
+				Profiles: []*Profile{
+					withMbsync(profile("Work", "jdoe@work.com"), &Mbsync{
+						SSLType:         "TLS",
+						CertificateFile: "certs.pem",
+						PipelineDepth:   -1,
+						MaxSize:         "10 MB",
+						Create:          "Remote",
+						Sync:            "Pull Fetch",
+					}),
+					withFolders(profile("Home", "jdoe@home.org"),
+						&Folder{Name: "projects", Patterns: []string{"Projects/*"}, MaxMessages: -1},
+						&Folder{Name: "archive", Remote: "Archive", Local: "archive", Patterns: []string{" "}},
+					),
+				},
+			},
+			[]string{"mbsync_version", "mbsync", "mbsync", "mbsync", "mbsync", "mbsync", "mbsync", "folders", "folders"},
+		},
+		{
+			"include",
+			&Config{
+				Profiles: []*Profile{
+					withInclude(withFolders(profile("Work", "jdoe@work.com"),
+						&Folder{Name: "inbox", Remote: "INBOX", Local: "INBOX"},
+						&Folder{Name: "patterns", Remote: "Projects", Local: "projects"},
+					), []string{"INBOX", "Projects/*", "!Old", " "}, []string{"Lists/Old", `"Spam"`}),
+					withInclude(profile("Home", "jdoe@home.org"), []string{"Clients", "Lists/*"}, []string{"Lists/Old"}),
+				},
+			},
+			[]string{"include", "include", "include", "exclude", "folders"},
+		},
+		{
+			"rules",
+			&Config{
+				Profiles: []*Profile{
+					withRules(profile("Work", "jdoe@work.com"),
+						&Rule{
+							Name:    "boss",
+							Match:   &Match{From: "boss@work.com", Header: map[string]string{"X-Spam": "yes", "List Id": "x"}},
+							Actions: []*Action{{Action: "move", Mailbox: "Boss"}, {Action: "flag", Mailbox: "Boss"}},
+						},
+						&Rule{Name: "all", Match: &Match{}, Actions: []*Action{{Action: "archive"}}},
+						&Rule{
+							Name:    "big",
+							Match:   &Match{LargerThan: -1},
+							Actions: []*Action{{Action: "delete"}, {Action: "copy", Mailbox: "Big", Profile: "Home"}},
+						},
+						&Rule{Name: "", Match: &Match{OlderThan: 30}},
+						&Rule{
+							Name:    "lists",
+							Match:   &Match{Header: map[string]string{"List-Id": "golang-nuts"}},
+							Actions: []*Action{{Action: "copy", Mailbox: "Lists", Profile: "Old"}},
+						},
+					),
+				},
+			},
+			[]string{"rules", "rules", "rules", "rules", "rules", "rules", "rules", "rules", "rules", "rules", "rules"},
+		},
+		{
+			"maildirs",
+			&Config{
+				MaildirRoot: "/home/user/Mail",
+				Profiles: []*Profile{
+					withMaildir(profile("Work", "jdoe@work.com"), "/home/user/Mail/work"),
+					withMaildir(profile("Home", "jdoe@home.org"), "/home/user/Maildir/Home"),
+					withMaildir(profile("Old", "jdoe@old.org"), "/home/user/Mail/work/old"),
+					withMaildir(profile("Other", "jdoe@other.org"), "Mail/other"),
+					profile("work", "jdoe@gmail.com"),
+				},
+			},
+			[]string{"maildir", "maildir", "maildir", "maildir", "maildir"},
+		},
+		{
+			"mu4e",
+			&Config{
+				Profiles: []*Profile{
+					profile("Work", "jdoe@gmail.com"),
+					profile("Home", "jdoe@home.org"),
+				},
+				Mu4e: &Mu4e{
+					Browser:       "opera",
+					ContextPolicy: "first",
+					Bookmarks: []*Bookmark{
+						{Name: "Unread", Query: "flag:unread", Key: "u"},
+						{Name: "Urgent", Query: "flag:flagged", Key: "u"},
+						{Name: "Inbox", Query: "maildir:/INBOX", Key: "?i"},
+					},
+					ContextKeys: map[string]string{
+						"Home": "C-c w",
+						"Old":  "C-c o",
+						"Work": "C-c w",
+					},
+				},
+			},
+			[]string{"mu4e.browser", "mu4e.context_policy", "mu4e.bookmarks", "mu4e.bookmarks", "mu4e.context_keys", "mu4e.context_keys"},
+		},
+	}
+	for _, tc := range tt {
+		err := tc.config.Validate()
+		if tc.want == nil {
+			if err != nil {
+				t.Fatalf("%s: got error %v, want: <nil>", tc.name, err)
+			}
+			continue
+		}
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("%s: got error %v, want: ValidationError", tc.name, err)
+		}
+		if len(verr) != len(tc.want) {
+			t.Fatalf("%s: got %d errors, want: %d\n%v", tc.name, len(verr), len(tc.want), err)
+		}
+		for i, field := range tc.want {
+			if verr[i].Field != field {
+				t.Fatalf("%s: got error on %s, want: %s", tc.name, verr[i].Field, field)
+			}
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	cfg := &Config{
+		Profiles: []*Profile{
+			profile("my work", "a@gmail.com"),
+			profile("work/gmail", "b@gmail.com"),
+			profile("work-gmail", "c@gmail.com"),
+			profile(".hidden", "d@gmail.com"),
+			profile("lavoro€", "e@gmail.com"),
+			profile("work:gmail", "f@gmail.com"),
+			profile("", "g@gmail.com"),
+		},
+	}
+	var got []string
+	for _, w := range cfg.Warnings() {
+		got = append(got, w.Profile)
+	}
+	want := []string{"my work", "work/gmail", ".hidden", "lavoro€", "work:gmail"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got warnings for %q, want: %q", got, want)
+	}
+	if err := ValidateName("work-gmail"); err != nil {
+		t.Fatalf("got error %v for work-gmail, want: <nil>", err)
+	}
+	if err := ValidateName("my work"); err == nil {
+		t.Fatalf("got no error for \"my work\"")
+	}
+}
@@ -0,0 +1,90 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/client"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdClients = &base.Command{
+	UsageLine: "clients [-dry-run -v] [none | client...]",
+	Short:     "clients chooses the mail clients to configure",
+	Long: `
+
+Clients selects the mail clients, besides the frontend, that get an
+account for every profile:
+
+	aerc     ~/.config/aerc/accounts.conf
+	neomutt  ~/.config/neomutt/mailconf.muttrc, to be sourced by the
+	         neomuttrc, and an account file for every profile in
+	         ~/.config/neomutt/mailconf, which folder hooks switch to
+
+Both clients read the mail in the maildir root and send it with msmtp. The
+configuration of the clients left out is removed; "none" removes all
+of them. Without arguments, the selected clients are printed.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdClients.Run = runClients
+	CmdClients.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdClients.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runClients(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	var names []string
+	for _, arg := range args {
+		if arg == "none" && len(args) == 1 {
+			continue
+		}
+		if client.New(arg, nil) == nil {
+			fmt.Fprintf(os.Stderr, "unknown client %q: use one of %s\n", arg, strings.Join(client.Names, ", "))
+			return ErrUsage
+		}
+		names = append(names, arg)
+	}
+
+	if len(args) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		for _, name := range cfg.Clients {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.SetClients(names, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set clients: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,79 @@
+package configcmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/configcmd/clients"
+	"github.com/gianz74/mailconf/internal/configcmd/convert"
+	"github.com/gianz74/mailconf/internal/configcmd/frontend"
+	"github.com/gianz74/mailconf/internal/configcmd/interval"
+	"github.com/gianz74/mailconf/internal/configcmd/sendwith"
+	"github.com/gianz74/mailconf/internal/configcmd/sets"
+	"github.com/gianz74/mailconf/internal/configcmd/syncmode"
+	"github.com/gianz74/mailconf/internal/configcmd/validate"
+)
+
+var CmdConfig = &base.Command{
+	UsageLine: "config command",
+	Short:     "config inspects and changes the mailconf configuration",
+}
+
+func init() {
+	CmdConfig.Run = runConfig
+	CmdConfig.Commands = []*base.Command{
+		validate.CmdValidate,
+		convert.CmdConvert,
+		sets.CmdSets,
+		interval.CmdInterval,
+		syncmode.CmdSyncMode,
+		sendwith.CmdSendWith,
+		frontend.CmdFrontend,
+		clients.CmdClients,
+	}
+	CmdConfig.Long = tmpl(usageTemplate, CmdConfig.Commands)
+}
+
+func runConfig(cmd *base.Command, args []string) error {
+	for _, cmd := range cmd.Commands {
+		cmd.Flag.Usage = cmd.Usage
+		if len(args) > 0 && cmd.Name() == args[0] {
+			cmd.Flag.Parse(args[1:])
+			args = cmd.Flag.Args()
+			return cmd.Run(cmd, args)
+		}
+	}
+	fmt.Println(tmpl(usageTemplate, cmd.Commands))
+	return nil
+}
+
+func tmpl(text string, data interface{}) string {
+	t := template.New("top")
+	t.Funcs(template.FuncMap{"trim": strings.TrimSpace})
+	template.Must(t.Parse(text))
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, data); err != nil {
+		panic(err)
+	}
+	return string(out.Bytes())
+}
+
+const usageTemplate = `config is a subcommand to inspect and change the configuration saved
+by mailconf.
+
+The configuration is read from config.yaml, config.toml or data.json,
+whichever is found in [user config dir]/mailconf, and saved back in
+the same format. The global -config option selects another directory,
+which cannot generate files, or a named config set.
+
+Usage:
+	mailconf config command [arguments]
+
+The commands are:
+{{range .}}
+	{{.Name | printf "%-11s"}} {{.Short}}{{end}}
+
+Use "mailconf help config [command]" for more information about a command.`
@@ -0,0 +1,81 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdConvert = &base.Command{
+	UsageLine: "convert -to format [-dry-run]",
+	Short:     "convert saves the configuration in another format",
+	Long: `
+
+Convert saves the configuration in format, which is one of yaml, toml
+or json, as config.yaml, config.toml or data.json respectively.
+
+The file previously in use is renamed adding the .bak suffix, so that
+only one configuration file is found by mailconf.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.`,
+}
+
+var (
+	to          string
+	dryrun      bool
+	ErrNoConfig = config.ErrNoConfig
+)
+
+func init() {
+	CmdConvert.Run = runConvert
+	CmdConvert.Flag.StringVar(&to, "to", "", "Format to convert to: yaml, toml or json.")
+	CmdConvert.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+}
+
+func runConvert(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun))
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if cfg.Format() == to {
+		return nil
+	}
+
+	old, err := cfg.File()
+	if err != nil {
+		return err
+	}
+	err = cfg.SetFormat(to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot convert config: %v\n", err)
+		return err
+	}
+	file, err := cfg.File()
+	if err != nil {
+		return err
+	}
+	if options.Dryrun() {
+		fmt.Fprintf(os.Stdout, "converting %s to %s\n", old, file)
+		return nil
+	}
+
+	err = cfg.Save()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(old)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(old+".bak", data, 0640)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(old)
+}
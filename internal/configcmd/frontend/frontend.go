@@ -0,0 +1,90 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/frontend"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdFrontend = &base.Command{
+	UsageLine: "frontend [-dry-run -v] [mu4e|notmuch]",
+	Short:     "frontend chooses the program indexing the mail",
+	Long: `
+
+Frontend selects the program that indexes the synced mail, which
+onnewmail.sh runs whenever goimapnotify reports new mail.
+
+With mu4e, the default, the mail is indexed by mu and read with mu4e,
+configured in mu4e.el in the emacs config directory.
+
+With notmuch, the mail is indexed by notmuch new. mailconf generates
+~/.notmuch-config, with the address of the first profile as the
+primary email and the others as other emails, and the post-new hook
+.notmuch/hooks/post-new in the maildir root, which tags the new mail with the
+name of its profile and of its folder.
+
+The configuration of the previous frontend is removed. Without an
+argument, the current frontend is printed.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdFrontend.Run = runFrontend
+	CmdFrontend.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdFrontend.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runFrontend(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && (args[0] == frontend.Mu4e || args[0] == frontend.Notmuch):
+	default:
+		fmt.Fprintf(os.Stderr, "usage: mailconf config %s\n", CmdFrontend.UsageLine)
+		return ErrUsage
+	}
+
+	if len(args) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.Frontend == "" {
+			fmt.Println(frontend.Mu4e)
+		} else {
+			fmt.Println(cfg.Frontend)
+		}
+		return nil
+	}
+
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.SetFrontend(args[0], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set frontend: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
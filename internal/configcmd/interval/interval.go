@@ -0,0 +1,107 @@
+package interval
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdInterval = &base.Command{
+	UsageLine: "interval [-p profile] [-dry-run -v] [interval|default]",
+	Short:     "interval shows or sets how often mail is synced",
+	Long: `
+
+Interval sets the sync interval, which is used by the mbsync timer
+(the launchd job on macOS) and as mu4e-update-interval. The interval is
+a number of seconds or a duration such as 10m or 1h, and cannot be
+shorter than a minute; "default" restores the default of 5 minutes.
+
+The -p option sets the interval of a single profile instead. A profile
+whose interval differs from the shared one is synced by its own timer,
+mbsync@<profile>.timer (local.mbsync.<profile> on macOS), and is left
+out of the shared one.
+
+Without an interval, the sync interval of every profile is printed.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	profile     string
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdInterval.Run = runInterval
+	CmdInterval.Flag.StringVar(&profile, "p", "", "Set the interval of this profile.")
+	CmdInterval.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdInterval.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runInterval(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf config %s\n", CmdInterval.UsageLine)
+		return ErrUsage
+	}
+	if len(args) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("shared: %ds\n", cfg.Interval())
+		for _, p := range cfg.Profiles {
+			fmt.Printf("%s: %ds\n", p.Name, cfg.ProfileInterval(p))
+		}
+		return nil
+	}
+
+	seconds, err := parse(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid interval %q\n", args[0])
+		return ErrUsage
+	}
+
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.SetSyncInterval(profile, seconds, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set interval: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
+
+// parse reads an interval given in seconds or as a duration; default
+// is zero.
+func parse(s string) (int, error) {
+	if s == "default" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid interval %q", s)
+	}
+	return int(d / time.Second), nil
+}
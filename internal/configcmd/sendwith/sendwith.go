@@ -0,0 +1,90 @@
+package sendwith
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdSendWith = &base.Command{
+	UsageLine: "send-with [-dry-run -v] [smtpmail|msmtp]",
+	Short:     "send-with chooses how mu4e sends mail",
+	Long: `
+
+Send-with selects the program mu4e sends mail with.
+
+mailconf always generates the msmtp config, ~/.config/msmtp/config,
+with an account for every profile that is not read-only, so that
+programs such as git send-email can send mail through the profiles.
+The passwords are read from the credentials store with passwordeval.
+
+With smtpmail, the default, mu4e sends mail by itself. With msmtp, mu4e
+runs msmtp --read-envelope-from, which picks the account from the From
+address of the message.
+
+Without an argument, the current choice is printed.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdSendWith.Run = runSendWith
+	CmdSendWith.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdSendWith.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runSendWith(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	msmtp := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "smtpmail":
+	case len(args) == 1 && args[0] == "msmtp":
+		msmtp = true
+	default:
+		fmt.Fprintf(os.Stderr, "usage: mailconf config %s\n", CmdSendWith.UsageLine)
+		return ErrUsage
+	}
+
+	if len(args) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.UseMsmtp {
+			fmt.Println("msmtp")
+		} else {
+			fmt.Println("smtpmail")
+		}
+		return nil
+	}
+
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.SetUseMsmtp(msmtp, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set mail sender: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
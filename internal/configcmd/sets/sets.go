@@ -0,0 +1,85 @@
+package sets
+
+import (
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdSets = &base.Command{
+	UsageLine: "sets [-activate [-dry-run] [-v]]",
+	Short:     "sets lists the named config sets",
+	Long: `
+
+Sets prints the name of every config set, marking the one selected
+with -config or MAILCONF_CONFIG with an asterisk.
+
+A config set is a separate configuration, kept in
+[user config dir]/mailconf/sets/<name>, which is created by running
+"mailconf -config <name> setup".
+
+Only the configuration of mailconf is kept in the set: the generated
+files, such as ~/.mbsyncrc, mu4e.el and the services, are shared by all
+the sets. They belong to the active set, the first one saved, and the
+commands generating them refuse to work on the other sets.
+
+The -activate flag makes the selected set the active one: the services
+of the profiles missing from it are removed, and the files of its
+profiles generated again.
+
+The -dry-run option shows what -activate would do without doing it.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	activate bool
+	dryrun   bool
+	verbose  bool
+)
+
+func init() {
+	CmdSets.Run = runSets
+	CmdSets.Flag.BoolVar(&activate, "activate", false, "Make the selected set the active one.")
+	CmdSets.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdSets.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runSets(cmd *base.Command, args []string) error {
+	if activate {
+		return runActivate()
+	}
+	sets, err := config.Sets()
+	if err != nil {
+		return err
+	}
+	for _, name := range sets {
+		mark := " "
+		if name == config.Location() {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, name)
+	}
+	return nil
+}
+
+func runActivate() error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.ActivateSet(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot activate the config: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package syncmode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdSyncMode = &base.Command{
+	UsageLine: "sync-mode [-dry-run -v] [shared|per-profile]",
+	Short:     "sync-mode chooses whether profiles are synced together",
+	Long: `
+
+Sync-mode selects how the profiles are synced.
+
+In the shared mode, the default, one mbsync service syncs all the
+profiles and then runs imapfilter on all of them, so that a broken
+account can fail the sync of the others.
+
+In the per-profile mode, every profile has its own service,
+mbsync@<profile>.service with its timer (local.mbsync.<profile> on
+macOS), which syncs only the profile and runs imapfilter only on its
+account, with the config in ~/.imapfilter/profiles/<profile>.lua.
+Failures are isolated, and shown per profile by systemctl.
+
+Without an argument, the current mode is printed.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdSyncMode.Run = runSyncMode
+	CmdSyncMode.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdSyncMode.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runSyncMode(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	perProfile := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "shared":
+	case len(args) == 1 && args[0] == "per-profile":
+		perProfile = true
+	default:
+		fmt.Fprintf(os.Stderr, "usage: mailconf config %s\n", CmdSyncMode.UsageLine)
+		return ErrUsage
+	}
+
+	if len(args) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.PerProfileSync {
+			fmt.Println("per-profile")
+		} else {
+			fmt.Println("shared")
+		}
+		return nil
+	}
+
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.SetPerProfileSync(perProfile, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set sync mode: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,46 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdValidate = &base.Command{
+	UsageLine: "validate",
+	Short:     "validate checks the configuration",
+	Long: `
+
+Validate reads the configuration and reports every invalid field.
+
+Besides checking that hosts, ports and user names are set and that
+email addresses are unique, it verifies that profile names can be
+safely used in maildir paths, in the instance names of the systemd
+units and in launchd labels. Other commands only warn about unsafe
+names, so that the profiles using them can be renamed.`,
+}
+
+var (
+	ErrNoConfig = config.ErrNoConfig
+)
+
+func init() {
+	CmdValidate.Run = runValidate
+}
+
+func runValidate(cmd *base.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	// Read only warns about unsafe profile names, for the profiles to
+	// be renamed.
+	if errs := cfg.Warnings(); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "configuration is not valid.\n")
+		return errs
+	}
+	fmt.Printf("configuration is valid.\n")
+	return nil
+}
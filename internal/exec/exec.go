@@ -0,0 +1,78 @@
+// Package exec runs the external programs mailconf relies on, such as
+// mu. The runner can be replaced with Set, so that tests do not depend
+// on the programs installed.
+package exec
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var ErrNotFound = exec.ErrNotFound
+
+type Runner interface {
+	// Output runs name with args, returning its standard output.
+	Output(name string, args ...string) ([]byte, error)
+	// Run runs name with args, showing its output to the user.
+	Run(name string, args ...string) error
+	// LookPath searches for the executable name in PATH.
+	LookPath(name string) (string, error)
+}
+
+var runner Runner = osRunner{}
+
+// Set replaces the runner, returning the previous one.
+func Set(r Runner) Runner {
+	ret := runner
+	runner = r
+	return ret
+}
+
+// Output runs name with args, returning its standard output. It runs
+// even with the dry-run option, so it must not change anything.
+func Output(name string, args ...string) ([]byte, error) {
+	return runner.Output(name, args...)
+}
+
+// Run runs name with args, showing its output to the user. With the
+// dry-run option it only prints the command.
+func Run(name string, args ...string) error {
+	if options.Dryrun() || options.Verbose() {
+		fmt.Printf("running %s\n", CommandLine(name, args...))
+	}
+	if options.Dryrun() {
+		return nil
+	}
+	return runner.Run(name, args...)
+}
+
+// LookPath searches for the executable name in PATH.
+func LookPath(name string) (string, error) {
+	return runner.LookPath(name)
+}
+
+// CommandLine returns name and args separated by spaces.
+func CommandLine(name string, args ...string) string {
+	return strings.Join(append([]string{name}, args...), " ")
+}
+
+type osRunner struct{}
+
+func (osRunner) Output(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+func (osRunner) Run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (osRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
@@ -0,0 +1,75 @@
+package memexec
+
+import (
+	"github.com/gianz74/mailconf/internal/exec"
+)
+
+type result struct {
+	out []byte
+	err error
+}
+
+// Runner runs no program: it returns the output set for each command
+// line and records the command lines it is asked to run. Output fails
+// for the command lines without an output, while Run succeeds.
+type Runner struct {
+	results map[string]result
+	paths   map[string]string
+	ran     []string
+}
+
+func New() *Runner {
+	return &Runner{
+		results: make(map[string]result),
+		paths:   make(map[string]string),
+	}
+}
+
+// SetOutput sets the output and the error of the command line cmd,
+// such as "mu --version".
+func (r *Runner) SetOutput(cmd, out string, err error) {
+	r.results[cmd] = result{[]byte(out), err}
+}
+
+// SetPath makes LookPath find name at path.
+func (r *Runner) SetPath(name, path string) {
+	r.paths[name] = path
+}
+
+// Ran returns the command lines run so far.
+func (r *Runner) Ran() []string {
+	return r.ran
+}
+
+// Reset forgets the outputs, the paths and the command lines run.
+func (r *Runner) Reset() {
+	r.results = make(map[string]result)
+	r.paths = make(map[string]string)
+	r.ran = nil
+}
+
+func (r *Runner) Output(name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandLine(name, args...)
+	r.ran = append(r.ran, cmd)
+	res, ok := r.results[cmd]
+	if !ok {
+		return nil, exec.ErrNotFound
+	}
+	return res.out, res.err
+}
+
+// Run records the command line, returning the error set for it, if
+// any.
+func (r *Runner) Run(name string, args ...string) error {
+	cmd := exec.CommandLine(name, args...)
+	r.ran = append(r.ran, cmd)
+	return r.results[cmd].err
+}
+
+func (r *Runner) LookPath(name string) (string, error) {
+	path, ok := r.paths[name]
+	if !ok {
+		return "", exec.ErrNotFound
+	}
+	return path, nil
+}
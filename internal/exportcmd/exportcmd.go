@@ -0,0 +1,152 @@
+package exportcmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/bundle"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/cred"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/myterm"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdExport = &base.Command{
+	UsageLine: "export [-o file] [-creds] [-no-folders] [-dry-run] [profiles...]",
+	Short:     "export writes profiles to a portable bundle",
+	Long: `
+
+Export writes the named profiles, or all of them if none is named, to
+a bundle that "mailconf import" reads on another machine. The bundle
+holds the profile definitions, including their folder maps.
+
+The -o flag sets the file to write, the default is the standard output.
+
+The -creds flag adds the imap and smtp passwords of the profiles to
+the bundle, encrypted with a passphrase that is asked for twice.
+
+The -no-folders flag leaves the folder maps out of the bundle, so that
+the imported profiles use the default folder map.
+
+The -dry-run flag shows what would be written without writing it.`,
+}
+
+var (
+	out          string
+	withCreds    bool
+	noFolders    bool
+	dryrun       bool
+	ErrNoConfig  = config.ErrNoConfig
+	ErrMismatch  = errors.New("Passphrases do not match.")
+	ErrEmptyPass = errors.New("Empty passphrase.")
+)
+
+func init() {
+	CmdExport.Run = runExport
+	CmdExport.Flag.StringVar(&out, "o", "", "File to write the bundle to.")
+	CmdExport.Flag.BoolVar(&withCreds, "creds", false, "Include credentials, encrypted with a passphrase.")
+	CmdExport.Flag.BoolVar(&noFolders, "no-folders", false, "Leave the folder maps out of the bundle.")
+	CmdExport.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+}
+
+func runExport(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun))
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profiles, err := selectProfiles(cfg, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+	b := bundle.New(profiles)
+	if noFolders {
+		b.DropFolders()
+	}
+
+	if withCreds {
+		t := myterm.New()
+		pass, err := t.ReadPass("bundle passphrase: ")
+		if err != nil {
+			return err
+		}
+		again, err := t.ReadPass("repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if pass == "" {
+			return ErrEmptyPass
+		}
+		if pass != again {
+			return ErrMismatch
+		}
+		err = b.Seal(credentials(profiles), pass)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := b.Marshal()
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		if options.Dryrun() {
+			return nil
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return io.Write(out, data, 0600)
+}
+
+// selectProfiles returns the profiles of cfg named in names, or all of
+// them if names is empty.
+func selectProfiles(cfg *config.Config, names []string) ([]*config.Profile, error) {
+	if len(names) == 0 {
+		return cfg.Profiles, nil
+	}
+	var profiles []*config.Profile
+	for _, name := range names {
+		var found *config.Profile
+		for _, p := range cfg.Profiles {
+			if p.Name == name {
+				found = p
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("%s: %w", name, mailconf.ErrProfileNotFound)
+		}
+		profiles = append(profiles, found)
+	}
+	return profiles, nil
+}
+
+// credentials looks up the passwords of profiles, warning about the
+// missing ones.
+func credentials(profiles []*config.Profile) []*bundle.Credential {
+	c := cred.New()
+	var creds []*bundle.Credential
+	for _, p := range profiles {
+		for _, cr := range []*bundle.Credential{
+			{Service: "imap", User: p.ImapUser, Host: p.ImapHost, Port: p.ImapPort},
+			{Service: "smtp", User: p.SmtpUser, Host: p.SmtpHost, Port: p.SmtpPort},
+		} {
+			pwd, err := c.Get(cr.User, cr.Service, cr.Host, cr.Port)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "no credentials for %s://%s@%s:%d, skipping.\n", cr.Service, cr.User, cr.Host, cr.Port)
+				continue
+			}
+			cr.Password = pwd
+			creds = append(creds, cr)
+		}
+	}
+	return creds
+}
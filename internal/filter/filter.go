@@ -0,0 +1,215 @@
+// Package filter runs the imapfilter rules of a profile on a sample
+// mailbox, the way imapfilter runs them on the server, so that rules
+// can be tried before they touch any mail.
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var ErrEmpty = errors.New("no messages in the sample")
+
+// Message is a message of the sample mailbox.
+type Message struct {
+	// Name is the file of the message in a maildir, or its position
+	// in an mbox.
+	Name   string
+	Header mail.Header
+	Size   int
+	// Date is the date of the message, or when its file was last
+	// modified if it has no valid Date header.
+	Date time.Time
+}
+
+// Result lists the rules matching a message, in the order they run.
+type Result struct {
+	Message *Message
+	Rules   []*config.Rule
+}
+
+// Read reads the messages of sample, which is either a maildir, with
+// the messages in its cur and new directories, or an mbox file.
+func Read(sample string) ([]*Message, error) {
+	var msgs []*Message
+	var err error
+	if _, derr := os.ReadDir(sample); derr == nil {
+		msgs, err = readMaildir(sample)
+	} else {
+		msgs, err = readMbox(sample)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, ErrEmpty
+	}
+	return msgs, nil
+}
+
+func readMaildir(dir string) ([]*Message, error) {
+	var msgs []*Message
+	for _, sub := range []string{"cur", "new"} {
+		files, err := os.ReadDir(path.Join(dir, sub))
+		if err != nil {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+		for _, fi := range files {
+			if fi.IsDir() {
+				continue
+			}
+			name := path.Join(sub, fi.Name())
+			data, err := os.ReadFile(path.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			msg, err := parse(name, data, fi.ModTime())
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+func readMbox(file string) ([]*Message, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*Message
+	for _, raw := range splitMbox(data) {
+		msg, err := parse(fmt.Sprintf("%d", len(msgs)+1), raw, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// splitMbox returns the messages of an mbox, without their "From "
+// separator lines.
+func splitMbox(data []byte) [][]byte {
+	var raws [][]byte
+	var cur []byte
+	started := false
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if started {
+				raws = append(raws, cur)
+			}
+			cur, started = nil, true
+			continue
+		}
+		if started {
+			// lines starting with From are quoted as >From.
+			if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>' {
+				line = line[1:]
+			}
+			cur = append(cur, line...)
+		}
+	}
+	if started {
+		raws = append(raws, cur)
+	}
+	return raws
+}
+
+func parse(name string, data []byte, modTime time.Time) (*Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("message %s: %w", name, err)
+	}
+	date, err := m.Header.Date()
+	if err != nil {
+		date = modTime
+	}
+	return &Message{Name: name, Header: m.Header, Size: len(data), Date: date}, nil
+}
+
+// Run runs rules on the messages of mailbox, in order, as imapfilter
+// does: the rules of other mailboxes are skipped, and a message moved
+// or deleted by a rule is not seen by the following ones. Ages are
+// counted from now.
+func Run(rules []*config.Rule, mailbox string, msgs []*Message, now time.Time) []*Result {
+	results := make([]*Result, len(msgs))
+	removed := make([]bool, len(msgs))
+	for i, msg := range msgs {
+		results[i] = &Result{Message: msg}
+	}
+	for _, r := range rules {
+		if r.RuleMailbox() != mailbox {
+			continue
+		}
+		removes := false
+		for _, a := range r.Actions {
+			removes = removes || a.Removes()
+		}
+		for i, msg := range msgs {
+			if removed[i] || !Matches(r.Match, msg, now) {
+				continue
+			}
+			results[i].Rules = append(results[i].Rules, r)
+			removed[i] = removes
+		}
+	}
+	return results
+}
+
+// Matches reports whether msg matches every criterion of m, as IMAP
+// SEARCH would.
+func Matches(m *config.Match, msg *Message, now time.Time) bool {
+	if m == nil {
+		return false
+	}
+	contains := func(field, s string) bool {
+		if s == "" {
+			return true
+		}
+		value := msg.Header.Get(field)
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		return strings.Contains(strings.ToLower(value), strings.ToLower(s))
+	}
+	if !contains("From", m.From) || !contains("To", m.To) || !contains("Subject", m.Subject) {
+		return false
+	}
+	for _, name := range m.Headers() {
+		// an empty text matches the messages having the header.
+		if len(msg.Header[textproto.CanonicalMIMEHeaderKey(name)]) == 0 {
+			return false
+		}
+		if !contains(name, m.Header[name]) {
+			return false
+		}
+	}
+	if m.LargerThan != 0 && msg.Size <= m.LargerThan {
+		return false
+	}
+	if m.SmallerThan != 0 && msg.Size >= m.SmallerThan {
+		return false
+	}
+	day := 24 * time.Hour
+	if m.OlderThan != 0 && !msg.Date.Before(now.Add(-time.Duration(m.OlderThan)*day)) {
+		return false
+	}
+	if m.NewerThan != 0 && msg.Date.Before(now.Add(-time.Duration(m.NewerThan)*day)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+const (
+	report = "From: Boss <boss@example.com>\nTo: jdoe@example.com\nSubject: Weekly report\nDate: Mon, 02 Jan 2023 10:00:00 +0000\n\nNumbers.\n"
+	list   = "From: someone@example.org\nTo: golang-nuts@googlegroups.com\nSubject: =?UTF-8?Q?Caf=C3=A9?=\nList-Id: <golang-nuts.googlegroups.com>\nDate: Mon, 09 Jan 2023 10:00:00 +0000\n\n" +
+		"A long message.\n"
+	lunch = "From: Jane <jane@example.com>\nTo: jdoe@example.com\nSubject: Lunch?\nDate: Tue, 10 Jan 2023 10:00:00 +0000\n\nAt noon.\n"
+)
+
+func TestRead(t *testing.T) {
+	oldFs := os.Set(&afero.Afero{Fs: afero.NewMemMapFs()})
+	defer os.Set(oldFs)
+	os.MkdirAll("/sample/maildir/tmp", 0755)
+	os.WriteFile("/sample/maildir/cur/2:2,S", []byte(list), 0644)
+	os.WriteFile("/sample/maildir/cur/1:2,S", []byte(report), 0644)
+	os.WriteFile("/sample/maildir/new/3", []byte(lunch), 0644)
+	os.WriteFile("/sample/mbox", []byte("From boss@example.com Mon Jan  2 10:00:00 2023\n"+report+
+		"\nFrom jane@example.com Tue Jan 10 10:00:00 2023\n"+strings.Replace(lunch, "At noon.", ">From noon.", 1)), 0644)
+
+	msgs, err := Read("/sample/maildir")
+	if err != nil {
+		t.Fatalf("cannot read the maildir: %v", err)
+	}
+	var names []string
+	for _, msg := range msgs {
+		names = append(names, msg.Name)
+	}
+	if want := []string{"cur/1:2,S", "cur/2:2,S", "new/3"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got messages %q, want: %q", names, want)
+	}
+	if msgs[0].Size != len(report) || msgs[0].Header.Get("Subject") != "Weekly report" {
+		t.Fatalf("got message %+v", msgs[0])
+	}
+
+	msgs, err = Read("/sample/mbox")
+	if err != nil {
+		t.Fatalf("cannot read the mbox: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Name != "1" || msgs[1].Header.Get("From") != "Jane <jane@example.com>" {
+		t.Fatalf("got messages %+v", msgs)
+	}
+
+	if _, err := Read("/sample/maildir/tmp"); err != ErrEmpty {
+		t.Fatalf("got error %v, want: %v", err, ErrEmpty)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var msgs []*Message
+	for i, data := range []string{report, list, lunch} {
+		msg, err := parse(string(rune('1'+i)), []byte(data), time.Time{})
+		if err != nil {
+			t.Fatalf("cannot parse: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	now := time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC)
+	flag := &config.Rule{
+		Name:    "flag jdoe",
+		Match:   &config.Match{To: "JDOE@"},
+		Actions: []*config.Action{{Action: "flag"}},
+	}
+	boss := &config.Rule{
+		Name:    "boss",
+		Match:   &config.Match{From: "boss@", OlderThan: 7},
+		Actions: []*config.Action{{Action: "move", Mailbox: "Reports"}},
+	}
+	reports := &config.Rule{
+		Name:    "reports",
+		Match:   &config.Match{Subject: "report"},
+		Actions: []*config.Action{{Action: "mark_seen"}},
+	}
+	lists := &config.Rule{
+		Name:    "lists",
+		Match:   &config.Match{Subject: "café", Header: map[string]string{"list-id": "golang-nuts"}, LargerThan: 100},
+		Actions: []*config.Action{{Action: "copy", Mailbox: "Lists", Profile: "Home"}},
+	}
+	recent := &config.Rule{
+		Name:    "recent",
+		Match:   &config.Match{NewerThan: 5, SmallerThan: 1000},
+		Actions: []*config.Action{{Action: "mark_seen"}},
+	}
+	archive := &config.Rule{
+		Name:    "archive",
+		Mailbox: "Archive",
+		Match:   &config.Match{From: "jane"},
+		Actions: []*config.Action{{Action: "delete"}},
+	}
+	results := Run([]*config.Rule{flag, boss, reports, lists, recent, archive}, "INBOX", msgs, now)
+	want := [][]*config.Rule{
+		{flag, boss},
+		{lists, recent},
+		{flag, recent},
+	}
+	for i, res := range results {
+		if !reflect.DeepEqual(res.Rules, want[i]) {
+			var got []string
+			for _, r := range res.Rules {
+				got = append(got, r.Name)
+			}
+			t.Fatalf("message %s: got rules %q", res.Message.Name, got)
+		}
+	}
+}
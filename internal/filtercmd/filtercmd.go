@@ -0,0 +1,72 @@
+package filtercmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/filtercmd/testcmd"
+)
+
+var CmdFilter = &base.Command{
+	UsageLine: "filter command",
+	Short:     "filter works with the imapfilter rules of the profiles",
+}
+
+func init() {
+	CmdFilter.Run = runFilter
+	CmdFilter.Commands = []*base.Command{
+		testcmd.CmdTest,
+	}
+	CmdFilter.Long = tmpl(usageTemplate, CmdFilter.Commands)
+}
+
+func runFilter(cmd *base.Command, args []string) error {
+	for _, cmd := range cmd.Commands {
+		cmd.Flag.Usage = cmd.Usage
+		if len(args) > 0 && cmd.Name() == args[0] {
+			cmd.Flag.Parse(args[1:])
+			args = cmd.Flag.Args()
+			return cmd.Run(cmd, args)
+		}
+	}
+	fmt.Println(tmpl(usageTemplate, cmd.Commands))
+	return nil
+}
+
+func tmpl(text string, data interface{}) string {
+	t := template.New("top")
+	t.Funcs(template.FuncMap{"trim": strings.TrimSpace})
+	template.Must(t.Parse(text))
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, data); err != nil {
+		panic(err)
+	}
+	return string(out.Bytes())
+}
+
+const usageTemplate = `filter is a subcommand to work with the imapfilter rules declared in
+the "rules" of the profiles, which imapfilter runs after every sync.
+
+A rule filters a mailbox of the profile, INBOX by default, and matches
+the messages whose from, to, subject or other headers contain a text,
+or that are larger, smaller, older or newer than a size in bytes or an
+age in days. Its actions, done in order, are move, copy, delete, flag
+and mark_seen; move and copy take a mailbox, of another profile too:
+
+	"rules": [{
+		"name": "Reports",
+		"match": {"from": "boss@example.com", "older_than": 7},
+		"actions": [{"action": "flag"}, {"action": "move", "mailbox": "Reports"}]
+	}]
+
+Usage:
+	mailconf filter command [arguments]
+
+The commands are:
+{{range .}}
+	{{.Name | printf "%-11s"}} {{.Short}}{{end}}
+
+Use "mailconf help filter [command]" for more information about a command.`
@@ -0,0 +1,57 @@
+package testcmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdTest = &base.Command{
+	UsageLine: "test [-mailbox name] profile sample",
+	Short:     "test runs the rules of a profile on a sample mailbox",
+	Long: `
+
+Test runs the imapfilter rules of a profile on the messages of sample,
+a maildir or an mbox file, as imapfilter would run them on the server,
+and prints the rules every message matches with their actions. Rules
+run in order, and a message moved or deleted by a rule is not seen by
+the following ones. No mail is touched, neither in the sample nor on
+the server.
+
+The -mailbox option sets the mailbox the sample stands for, INBOX by
+default: only the rules filtering that mailbox are run.`,
+}
+
+var (
+	mailbox     string
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdTest.Run = runTest
+	CmdTest.Flag.StringVar(&mailbox, "mailbox", "INBOX", "Mailbox the sample stands for.")
+}
+
+func runTest(cmd *base.Command, args []string) error {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf filter %s\n", CmdTest.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.TryFilters(args[0], mailbox, os.ExpandUser(args[1]), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot test the rules: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package frontend generates the configuration of the program that
+// indexes the mail synced by mbsync and shows it to the user: mu with
+// mu4e, or notmuch.
+package frontend
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/templates"
+)
+
+const (
+	Mu4e    = "mu4e"
+	Notmuch = "notmuch"
+)
+
+var (
+	ErrModified = errors.New("Config modified externally")
+
+	//go:embed templates
+	embedded embed.FS
+)
+
+func init() {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		panic(err)
+	}
+	templates.Register(sub)
+}
+
+// Frontend is the configuration of a mail index frontend.
+type Frontend interface {
+	// GenConf writes the configuration of the frontend. Unless
+	// force is set, it returns ErrModified if a file differs from
+	// the one it would write.
+	GenConf(force bool) error
+	// Remove removes the configuration written by GenConf, except
+	// onnewmail.sh, which every frontend writes.
+	Remove() error
+	// Init creates the index database again, so that it knows the
+	// addresses and the maildirs of all the profiles, and indexes
+	// the mail.
+	Init() error
+	// Index adds the mail synced since the last run to the index.
+	Index() error
+}
+
+// New returns the frontend selected by cfg.
+func New(cfg *config.Config) Frontend {
+	switch cfg.Frontend {
+	case Notmuch:
+		return notmuch{cfg: cfg}
+	default:
+		return mu4e{cfg: cfg}
+	}
+}
+
+// GenerateOnNewMail writes onnewmail.sh, the script goimapnotify runs
+// after new mail is synced, which indexes it with the frontend of
+// cfg.
+func GenerateOnNewMail(cfg *config.Config, force bool) error {
+	name := "mu/onnewmail.sh.tmpl"
+	if cfg.Frontend == Notmuch {
+		name = "notmuch/onnewmail.sh.tmpl"
+	}
+	return generate(cfg, name, path.Join(cfg.BinDir, "onnewmail.sh"), 0750, force)
+}
+
+// generate executes the template name for cfg into file.
+func generate(cfg *config.Config, name, file string, perm fs.FileMode, force bool) error {
+	ctx, err := templates.NewContext(cfg, nil)
+	if err != nil {
+		return err
+	}
+	data, err := templates.Execute(name, ctx)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.ReadFile(file)
+	if err == nil && !(reflect.DeepEqual(tmp, data) || force) {
+		return ErrModified
+	}
+	return io.Write(file, data, perm)
+}
@@ -0,0 +1,307 @@
+package frontend
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/exec/memexec"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+var oldFs os.FsAccess
+
+func setup() {
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	oldFs = os.Set(&afero.Afero{
+		Fs: afero.NewMemMapFs(),
+	})
+}
+
+func restore() {
+	os.Set(oldFs)
+}
+
+func fixture(path string) []byte {
+	b, err := ioutil.ReadFile("testdata/fixtures" + path)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func work() *config.Profile {
+	return &config.Profile{
+		Name:     "Work",
+		FullName: "John Doe",
+		Email:    "jdoe@gmail.com",
+		ImapHost: "imap.gmail.com",
+		ImapPort: 993,
+		ImapUser: "user@gmail.com",
+		SmtpHost: "smtp.gmail.com",
+		SmtpPort: 587,
+		SmtpUser: "user@gmail.com",
+	}
+}
+
+func withIdentities(p *config.Profile) *config.Profile {
+	p.SignatureFile = "/home/user/.signatures/work"
+	p.Identities = []*config.Identity{
+		{Email: "john.doe@gmail.com"},
+		{Email: "support@example.com", FullName: "Example Support", SignatureFile: "/home/user/.signatures/support"},
+	}
+	return p
+}
+
+func withInclude(p *config.Profile) *config.Profile {
+	p.Include = []string{"Projects/*", "Clients", "Lists/golang-nuts"}
+	return p
+}
+
+func withFolders(p *config.Profile) *config.Profile {
+	p.Folders = []*config.Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "Inbox"},
+		{Name: "sent", Remote: "Sent Items", Local: "Sent Items"},
+		{Name: "drafts", Remote: "Drafts", Local: "Drafts"},
+		{Name: "trash", Remote: "Deleted Items", Local: "Deleted Items"},
+		{Name: "allmail", Remote: "Archive", Local: "Archive"},
+	}
+	return p
+}
+
+func TestMu4e(t *testing.T) {
+	tt := []struct {
+		name   string
+		config *config.Config
+		files  []string
+	}{
+		{
+			"single",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				Profiles:    []*config.Profile{work()},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el", "/home/user/.local/bin/onnewmail.sh"},
+		},
+		{
+			"preferences",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				Profiles:    []*config.Profile{work()},
+				Mu4e: &config.Mu4e{
+					Browser:              "firefox",
+					Keybinding:           "C-c m",
+					ContextPolicy:        "ask-if-none",
+					ComposeContextPolicy: "ask",
+					ComposeInNewFrame:    true,
+					Signature:            "John\n-- \nsent from mailconf",
+					Bookmarks: []*config.Bookmark{
+						{Name: "Flagged", Query: "flag:flagged", Key: "f"},
+						{Name: "Unread", Query: "flag:unread", Key: "u"},
+					},
+					ContextKeys: map[string]string{"Work": "C-c w"},
+				},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el"},
+		},
+		{
+			"mu16",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				MuVersion:   "1.6.10",
+				Mu4eDir:     "/usr/local/share/emacs/site-lisp/mu/mu4e",
+				Profiles:    []*config.Profile{work()},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el"},
+		},
+		{
+			"identities",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				Profiles:    []*config.Profile{withIdentities(work())},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el"},
+		},
+		{
+			"include",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				MaildirRoot: "/home/user/Mail",
+				Profiles:    []*config.Profile{withInclude(work())},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el"},
+		},
+		{
+			"folders",
+			&config.Config{
+				EmacsCfgDir: "/home/user/.emacs.d",
+				BinDir:      "/home/user/.local/bin",
+				Profiles:    []*config.Profile{withFolders(work())},
+			},
+			[]string{"/home/user/.emacs.d/mu4e.el"},
+		},
+	}
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		err := New(tc.config).GenConf(false)
+		if err != nil {
+			t.Fatalf("%s: cannot generate: %v", tc.name, err)
+		}
+		for _, file := range tc.files {
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("%s: %s not saved: %v", tc.name, file, err)
+			}
+			want := fixture("/mu4e/" + tc.name + "/" + path.Base(file))
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("%s: got: %s, want: %s", tc.name, got, want)
+			}
+		}
+	}
+}
+
+func TestNotmuch(t *testing.T) {
+	setup()
+	defer restore()
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	home.Folders = []*config.Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "INBOX"},
+		{Name: "sent", Remote: "Sent Items", Local: "Sent Items"},
+	}
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Frontend:    Notmuch,
+		Profiles:    []*config.Profile{withIdentities(work()), home},
+	}
+	err := New(cfg).GenConf(false)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	for _, file := range []string{
+		"/home/user/.notmuch-config",
+		"/home/user/Maildir/.notmuch/hooks/post-new",
+		"/home/user/.local/bin/onnewmail.sh",
+	} {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("%s not saved: %v", file, err)
+		}
+		want := fixture("/notmuch/two_profiles/" + path.Base(file))
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s: got: %s, want: %s", file, got, want)
+		}
+	}
+	if _, err := os.ReadFile("/home/user/.emacs.d/mu4e.el"); err == nil {
+		t.Fatalf("mu4e.el written for notmuch")
+	}
+
+	os.WriteFile("/home/user/.notmuch-config", []byte("edited"), 0644)
+	err = New(cfg).GenConf(false)
+	if err != ErrModified {
+		t.Fatalf("got error %v, want: %v", err, ErrModified)
+	}
+
+	err = New(cfg).Remove()
+	if err != nil {
+		t.Fatalf("cannot remove: %v", err)
+	}
+	if _, err := os.ReadFile("/home/user/.notmuch-config"); err == nil {
+		t.Fatalf(".notmuch-config not removed")
+	}
+}
+
+func TestDetectMu(t *testing.T) {
+	tt := []struct {
+		name    string
+		output  string
+		mu      string
+		dirs    []string
+		version string
+		mu4edir string
+	}{
+		{
+			"debian",
+			"mu (mail indexer/searcher) version 1.8.13\nCopyright (C) 2008-2022 Dirk-Jan C. Binnema\n",
+			"/usr/bin/mu",
+			[]string{
+				"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.8.11",
+				"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.8.13",
+			},
+			"1.8.13",
+			"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.8.13",
+		},
+		// no directory matches the version: the newest is preferred.
+		{
+			"upgraded",
+			"mu (mail indexer/searcher) version 1.10.8\n",
+			"/usr/bin/mu",
+			[]string{
+				"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.9.2",
+				"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.10.1",
+				"/usr/share/emacs/site-lisp/elpa-src/mu4e-git",
+			},
+			"1.10.8",
+			"/usr/share/emacs/site-lisp/elpa-src/mu4e-1.10.1",
+		},
+		{
+			"homebrew",
+			"mu (mail indexer/searcher) version 1.10.8\n",
+			"/opt/homebrew/bin/mu",
+			[]string{"/opt/homebrew/share/emacs/site-lisp/mu/mu4e"},
+			"1.10.8",
+			"/opt/homebrew/share/emacs/site-lisp/mu/mu4e",
+		},
+		{
+			"missing mu4e",
+			"mu (mail indexer/searcher) version 1.6.10\n",
+			"/usr/local/bin/mu",
+			nil,
+			"1.6.10",
+			"",
+		},
+	}
+	runner := memexec.New()
+	old := exec.Set(runner)
+	defer exec.Set(old)
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		runner.Reset()
+		runner.SetOutput("mu --version", tc.output, nil)
+		runner.SetPath("mu", tc.mu)
+		for _, dir := range tc.dirs {
+			os.MkdirAll(dir, 0755)
+			os.WriteFile(path.Join(dir, "mu4e.el"), []byte(";;; mu4e.el"), 0644)
+		}
+		version, err := MuVersion()
+		if err != nil {
+			t.Fatalf("%s: cannot detect the version: %v", tc.name, err)
+		}
+		if version != tc.version {
+			t.Fatalf("%s: got version %s, want: %s", tc.name, version, tc.version)
+		}
+		if got := Mu4eDir(version); got != tc.mu4edir {
+			t.Fatalf("%s: got mu4e dir %q, want: %q", tc.name, got, tc.mu4edir)
+		}
+	}
+
+	runner.Reset()
+	runner.SetOutput("mu --version", "mu: unknown option\n", nil)
+	if _, err := MuVersion(); err != ErrMuVersion {
+		t.Fatalf("got error %v, want: %v", err, ErrMuVersion)
+	}
+}
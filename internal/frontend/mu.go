@@ -0,0 +1,89 @@
+package frontend
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var ErrMuVersion = errors.New("cannot find the version in the output of mu --version")
+
+var muVersionRe = regexp.MustCompile(`version ([0-9]+(\.[0-9]+)*)`)
+
+// MuVersion returns the version of the installed mu, such as "1.8.13",
+// as printed by "mu --version".
+func MuVersion() (string, error) {
+	out, err := exec.Output("mu", "--version")
+	if err != nil {
+		return "", err
+	}
+	m := muVersionRe.FindSubmatch(out)
+	if m == nil {
+		return "", ErrMuVersion
+	}
+	return string(m[1]), nil
+}
+
+// Mu4eDir returns the directory holding mu4e.el, looking in the
+// site-lisp directories of the prefix mu is installed in, or an empty
+// string if it is not found. Distributions packaging mu4e as an elpa
+// package, such as Debian, install it in a directory named after the
+// version, which is preferred when more than one is found.
+func Mu4eDir(version string) string {
+	mu, err := exec.LookPath("mu")
+	if err != nil {
+		return ""
+	}
+	sitelisp := path.Join(path.Dir(path.Dir(mu)), "share", "emacs", "site-lisp")
+	candidates := []string{
+		path.Join(sitelisp, "mu4e"),
+		path.Join(sitelisp, "mu", "mu4e"),
+	}
+	for _, dir := range []string{"elpa-src", "elpa"} {
+		candidates = append(candidates, versionDirs(path.Join(sitelisp, dir), "mu4e-", version)...)
+	}
+	for _, dir := range candidates {
+		if _, err := os.ReadFile(path.Join(dir, "mu4e.el")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// versionDirs returns the directories in dir starting with prefix, the
+// one ending with version first and the others newest first.
+func versionDirs(dir, prefix, version string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), prefix) && e.Name() != prefix+version {
+			names = append(names, e.Name())
+		}
+	}
+	// versions sort as numbers, so that 1.10 is newer than 1.9; names
+	// without one go last.
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := strings.TrimPrefix(names[i], prefix), strings.TrimPrefix(names[j], prefix)
+		if !config.AtLeast(a, "0") || !config.AtLeast(b, "0") {
+			return config.AtLeast(a, "0")
+		}
+		return !config.AtLeast(b, a)
+	})
+	if version != "" {
+		names = append([]string{prefix + version}, names...)
+	}
+	dirs := make([]string, 0, len(names))
+	for _, name := range names {
+		dirs = append(dirs, path.Join(dir, name))
+	}
+	return dirs
+}
@@ -0,0 +1,48 @@
+package frontend
+
+import (
+	"path"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/io"
+)
+
+// mu4e indexes the mail with mu, and reads it with mu4e, configured
+// in mu4e.el in the emacs config directory.
+type mu4e struct {
+	cfg *config.Config
+}
+
+func (m mu4e) GenConf(force bool) error {
+	err := generate(m.cfg, "mu4e.tpl", path.Join(m.cfg.EmacsCfgDir, "mu4e.el"), 0644, force)
+	if err != nil {
+		return err
+	}
+	return GenerateOnNewMail(m.cfg, force)
+}
+
+func (m mu4e) Remove() error {
+	return io.Remove(path.Join(m.cfg.EmacsCfgDir, "mu4e.el"))
+}
+
+// Init runs mu init, recording the addresses of the profiles and of
+// their identities as the user's own, which mu init only accepts when
+// creating the database; it then indexes the mail from scratch.
+func (m mu4e) Init() error {
+	args := []string{"init", "--maildir", m.cfg.MaildirRootPath()}
+	for _, p := range m.cfg.Profiles {
+		for _, addr := range p.Addresses() {
+			args = append(args, "--my-address", addr)
+		}
+	}
+	err := exec.Run("mu", args...)
+	if err != nil {
+		return err
+	}
+	return m.Index()
+}
+
+func (m mu4e) Index() error {
+	return exec.Run("mu", "index")
+}
@@ -0,0 +1,64 @@
+package frontend
+
+import (
+	"path"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// notmuch indexes the mail with notmuch, configured in
+// ~/.notmuch-config, whose post-new hook, in the maildir root, tags
+// the new mail with its profile and folder.
+type notmuch struct {
+	cfg *config.Config
+}
+
+func (n notmuch) files() (string, string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	return path.Join(home, ".notmuch-config"), path.Join(n.cfg.MaildirRootPath(), ".notmuch", "hooks", "post-new"), nil
+}
+
+func (n notmuch) GenConf(force bool) error {
+	cfgfile, hook, err := n.files()
+	if err != nil {
+		return err
+	}
+	err = generate(n.cfg, "notmuch/notmuch-config.tmpl", cfgfile, 0644, force)
+	if err != nil {
+		return err
+	}
+	err = generate(n.cfg, "notmuch/post-new.tmpl", hook, 0755, force)
+	if err != nil {
+		return err
+	}
+	return GenerateOnNewMail(n.cfg, force)
+}
+
+func (n notmuch) Remove() error {
+	cfgfile, hook, err := n.files()
+	if err != nil {
+		return err
+	}
+	err = io.Remove(cfgfile)
+	if err != nil {
+		return err
+	}
+	return io.Remove(hook)
+}
+
+// Init runs notmuch new, which creates the database if it is missing:
+// notmuch reads the addresses of the user from ~/.notmuch-config, so
+// the database does not need to be created again when they change.
+func (n notmuch) Init() error {
+	return n.Index()
+}
+
+func (n notmuch) Index() error {
+	return exec.Run("notmuch", "new")
+}
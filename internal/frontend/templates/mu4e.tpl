@@ -0,0 +1,215 @@
+{{ $mu4e := .Cfg.Mu4eOptions -}}
+{{ $server := "mu4e--server" }}{{ if not (.Cfg.MuAtLeast "1.8") }}{{ $server = "mu4e~proc" }}{{ end -}}
+(if (not (eq system-type 'windows-nt))
+    (progn
+{{ with .Cfg.Mu4eDir }}      (add-to-list 'load-path {{ elisp . }})
+{{ else }}      (if (eq system-type 'darwin)
+	  (add-to-list 'load-path "/usr/local/share/emacs/site-lisp/mu/mu4e")
+	  )
+      (if (eq system-type 'gnu/linux)
+	  (add-to-list 'load-path "/usr/share/emacs/site-lisp/mu4e")
+	  )
+{{ end }}      (require 'mu4e)
+      (require 'smtpmail)
+      (require 'subr-x)
+
+      (defun mailconf-read-signature (file)
+	"Return the content of FILE, or nil if it cannot be read."
+	(when (file-readable-p file)
+	  (with-temp-buffer
+	    (insert-file-contents file)
+	    (string-trim-right (buffer-string)))))
+
+      (defun mailconf-addresses-regexp (addresses)
+	"Return a regexp matching exactly one of ADDRESSES."
+	(concat "\\`" (regexp-opt addresses) "\\'"))
+
+      (setq mu4e-contexts
+	    `( {{ range $Profile := .Profiles }},(make-mu4e-context
+		 :name "{{ $Profile.Name }}"
+		 :enter-func (lambda () (progn
+					  (mu4e-message "Entering {{ $Profile.Name }} context")
+					  (setq message-send-mail-function {{ if $Profile.ReadOnly }}(lambda () (user-error {{ elisp (printf "%s is read-only: mail cannot be sent" $Profile.Name) }})){{ else if $.Cfg.UseMsmtp }}'message-send-mail-with-sendmail{{ else }}'smtpmail-send-it{{ end }}
+						starttls-use-gnutls t
+						smtpmail-starttls-credentials
+						'(("{{ $Profile.SmtpHost }}" {{ $Profile.SmtpPort }} nil nil))
+						smtpmail-default-smtp-server "{{ $Profile.SmtpHost }}"
+						smtpmail-smtp-server "{{ $Profile.SmtpHost }}"
+						smtpmail-smtp-service {{ $Profile.SmtpPort }}
+						smtpmail-debug-info t)))
+		 :leave-func (lambda () (mu4e-message "Leaving {{ $Profile.Name }} context"))
+		 ;; we match based on the maildir and on the contact-fields of the message
+		 :match-func (lambda (msg)
+			       (when msg
+				 (or (string-match-p {{ elisp (printf "^/%s/" ($.MaildirFolder $Profile)) }} (mu4e-message-field msg :maildir))
+				     (mu4e-message-contact-field-matches
+				      msg '(:to :cc :bcc)
+				      (mailconf-addresses-regexp '({{ range $i, $a := $Profile.Addresses }}{{ if $i }} {{ end }}{{ elisp $a }}{{ end }}))))))
+		 :vars `( ( user-mail-address      . {{ elisp $Profile.Email }}  )
+			 ( user-full-name         . {{ elisp $Profile.FullName }} )
+			 ( mu4e-compose-signature . {{ with $Profile.SignatureFile }},(mailconf-read-signature {{ elisp . }}){{ else }}{{ elisp (default $Profile.FullName $mu4e.Signature) }}{{ end }})
+{{ with $Profile.Identities }}			 ( message-alternative-emails . ,(mailconf-addresses-regexp '({{ range $i, $id := . }}{{ if $i }} {{ end }}{{ elisp $id.Email }}{{ end }})))
+{{ end }}			 ( mu4e-drafts-folder     . {{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "drafts" "drafts")) }})
+			 ( mu4e-sent-folder       . {{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "sent" "sent")) }})
+			 ( mu4e-refile-folder     . {{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "allmail" "email-archive")) }})
+			 ( mu4e-trash-folder      . {{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "trash" "trash")) }})
+			 ( smtpmail-smtp-user     . {{ elisp $Profile.SmtpUser }})
+			 ( mu4e-get-mail-command  . "true")
+			 ( mu4e-maildir-shortcuts . (({{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "inbox" "INBOX")) }} . ?i)
+						     ({{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "sent" "sent")) }} . ?s)
+						     ({{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "allmail" "email-archive")) }} . ?a)
+						     ({{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "trash" "trash")) }} . ?t){{ range $Profile.IncludedShortcuts }}
+						     ({{ elisp (printf "/%s/%s" ($.MaildirFolder $Profile) .Folder) }} . ?{{ .Key }}){{ end }}))
+			 (mu4e-bookmarks          . ({{ range $i, $b := $mu4e.BookmarkList }}{{ if $i }}
+						     {{ end }}({{ elisp (printf "%s AND (maildir:%s OR maildir:%s)" $b.Query (mu (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "inbox" "INBOX"))) (mu (printf "/%s/%s" ($.MaildirFolder $Profile) ($Profile.LocalFolder "sent" "sent")))) }} {{ elisp $b.Name }} ?{{ $b.Key }}){{ end }}))
+			 ))
+		{{ end }}
+		))
+
+{{ if .Identities }}      ;; alias addresses, with the name and the signature file they are
+      ;; sent with.
+      (setq mailconf-identities
+	    '({{ range $i, $id := .Identities }}{{ if $i }}
+	      {{ end }}({{ elisp $id.Email }} {{ elisp $id.FullName }} {{ with $id.SignatureFile }}{{ elisp . }}{{ else }}nil{{ end }}){{ end }}))
+
+      (defun mailconf-apply-identity ()
+	"Use the name and the signature of the alias the message is sent from."
+	(let* ((from (message-field-value "From"))
+	       (address (and from (cadr (mail-extract-address-components from))))
+	       (identity (and address (assoc-string address mailconf-identities t))))
+	  (when identity
+	    (save-excursion
+	      (message-replace-header "From" (message-make-from (nth 1 identity) address)))
+	    (let ((signature (and (nth 2 identity) (mailconf-read-signature (nth 2 identity)))))
+	      (when signature
+		(save-excursion
+		  (goto-char (point-max))
+		  (when (re-search-backward message-signature-separator nil t)
+		    (delete-region (line-beginning-position) (point-max)))
+		  (goto-char (point-max))
+		  (insert "\n-- \n" signature)))))))
+      (add-hook 'mu4e-compose-mode-hook #'mailconf-apply-identity)
+
+{{ end }}      (setq mu4e-context-policy '{{ $mu4e.Policy }})
+
+      (setq mu4e-compose-context-policy {{ with $mu4e.ComposeContextPolicy }}'{{ . }}{{ else }}nil{{ end }})
+{{ range $Profile := .Profiles }}{{ with $mu4e.ContextKey $Profile.Name }}      (global-set-key (kbd {{ elisp . }}) (lambda () (interactive) (mu4e-context-switch t {{ elisp $Profile.Name }})))
+{{ end }}{{ end }}
+
+
+      (setq {{ if and .Cfg.MuVersion (not (.Cfg.MuAtLeast "1.4")) }}mu4e-maildir{{ else }}mu4e-root-maildir{{ end }} (expand-file-name {{ elisp (.Tilde .MaildirRoot) }})
+	    mu4e-sent-message-behavior 'delete
+	    mu4e-change-filenames-when-moving t
+	    mu4e-headers-skip-duplicates t
+	    mu4e-update-interval {{ .Interval }}
+	    mu4e-headers-leave-behavior 'apply
+	    mu4e-view-show-addresses t
+	    mu4e-compose-in-new-frame {{ if $mu4e.ComposeInNewFrame }}t{{ else }}nil{{ end }}
+	    mu4e-user-agent-string nil
+	    message-kill-buffer-on-exit t)
+{{ if .Cfg.UseMsmtp }}      (setq sendmail-program (executable-find "msmtp")
+	    message-sendmail-f-is-evil t
+	    message-sendmail-extra-arguments '("--read-envelope-from")
+	    message-sendmail-envelope-from 'header)
+{{ end }}{{ if eq $mu4e.Browser "chrome" }}      (setq browse-url-browser-function 'browse-url-chrome)
+      (if (eq system-type 'darwin)
+	  (setq browse-url-chrome-program "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome")
+	  )
+{{ with $mu4e.ChromeProfile }}      (setq browse-url-chrome-arguments '({{ elisp (printf "--profile-directory=%s" .) }}))
+{{ end }}{{ else if eq $mu4e.Browser "firefox" }}      (setq browse-url-browser-function 'browse-url-firefox)
+      (if (eq system-type 'darwin)
+	  (setq browse-url-firefox-program "/Applications/Firefox.app/Contents/MacOS/firefox")
+	  )
+{{ else if eq $mu4e.Browser "eww" }}      (setq browse-url-browser-function 'eww-browse-url)
+{{ end }}      (add-to-list 'mu4e-view-actions
+		   '("ViewInBrowser" . mu4e-action-view-in-browser) t)
+      ;; enable inline images
+      (setq mu4e-view-show-images t)
+      ;; use imagemagick, if available
+      (when (fboundp 'imagemagick-register-types)
+	(imagemagick-register-types))
+
+      (require 'mu4e-contrib)
+      (setq mu4e-html2text-command 'mu4e-shr2text)
+      (add-hook 'mu4e-view-mode-hook
+		(lambda()
+		  (local-set-key (kbd "<tab>") 'shr-next-link)
+		  (local-set-key (kbd "<backtab>") 'shr-previous-link)))
+      (setq shr-color-visible-luminance-min 60)
+      (setq shr-color-visible-distance-min 5)
+      (setq shr-use-colors nil)
+      (advice-add #'shr-colorize-region :around (defun shr-no-colourise-region (&rest ignore)))
+
+
+      ;; org links to messages.
+{{ if not .Cfg.MuVersion }}      (unless (require 'mu4e-org nil t)
+	(require 'org-mu4e nil t))
+{{ else if .Cfg.MuAtLeast "1.6" }}      (require 'mu4e-org)
+{{ else }}      (require 'org-mu4e)
+{{ end }}{{ with $mu4e.Keybinding }}      (global-set-key (kbd {{ elisp . }}) 'mu4e)
+{{ end }}      )
+    (defvar mu4e-reindex-request-file "/tmp/mail/mu_reindex_now"
+      "Location of the reindex request, signaled by existance")
+    (defvar mu4e-reindex-request-min-seperation 5.0
+      "Don't refresh again until this many second have elapsed.
+Prevents a series of redisplays from being called (when set to an appropriate value)")
+
+    (defvar mu4e-reindex-request--file-watcher nil)
+    (defvar mu4e-reindex-request--file-just-deleted nil)
+    (defvar mu4e-reindex-request--last-time 0)
+
+    (defun mu4e-reindex-request--add-watcher ()
+      (setq mu4e-reindex-request--file-just-deleted nil)
+      (setq mu4e-reindex-request--file-watcher
+	    (file-notify-add-watch (file-name-directory mu4e-reindex-request-file)
+				   '(change)
+				   #'mu4e-file-reindex-request)))
+
+    (defun mu4e-stop-watching-for-reindex-request ()
+      (if mu4e-reindex-request--file-watcher
+	  (file-notify-rm-watch mu4e-reindex-request--file-watcher)))
+
+{{ if .Cfg.MuVersion }}    (advice-add '{{ $server }}-kill :after 'mu4e-stop-watching-for-reindex-request)
+{{ else }}    (if (fboundp 'mu4e~proc-kill)
+	(advice-add 'mu4e~proc-kill :after 'mu4e-stop-watching-for-reindex-request)
+	(advice-add 'mu4e--server-kill :after 'mu4e-stop-watching-for-reindex-request))
+{{ end }}
+    (defun mu4e-watch-for-reindex-request ()
+      (let (directory) (setq directory (file-name-directory mu4e-reindex-request-file))
+	   (if (not( file-directory-p directory))
+	       (make-directory directory)))
+      (mu4e-stop-watching-for-reindex-request)
+      (when (file-exists-p mu4e-reindex-request-file)
+	(delete-file mu4e-reindex-request-file))
+      (mu4e-reindex-request--add-watcher))
+{{ if .Cfg.MuVersion }}    (advice-add '{{ $server }}-start :after 'mu4e-watch-for-reindex-request)
+{{ else }}    (if (fboundp 'mu4e~proc-start)
+	(advice-add 'mu4e~proc-start :after 'mu4e-watch-for-reindex-request)
+	(advice-add 'mu4e--server-start :after 'mu4e-watch-for-reindex-request))
+{{ end }}
+    (defun mu4e-file-reindex-request (event)
+      "Act based on the existance of `mu4e-reindex-request-file'"
+      (message "notification received")
+      (if mu4e-reindex-request--file-just-deleted
+	  (mu4e-reindex-request--add-watcher)
+	  (when (equal (nth 1 event) 'created)
+	    (delete-file mu4e-reindex-request-file)
+	    (setq mu4e-reindex-request--file-just-deleted t)
+	    (mu4e-reindex-maybe t))))
+
+    (defun mu4e-reindex-maybe (&optional new-request)
+      "Run `{{ $server }}-index' if it's been more than
+`mu4e-reindex-request-min-seperation'seconds since the last request,"
+      (let ((time-since-last-request (- (float-time)
+					mu4e-reindex-request--last-time)))
+	(when new-request
+	  (setq mu4e-reindex-request--last-time (float-time)))
+	(if (> time-since-last-request mu4e-reindex-request-min-seperation)
+{{ if .Cfg.MuVersion }}	    ({{ $server }}-index nil t)
+{{ else }}	    (if (fboundp 'mu4e~proc-index)
+		(mu4e~proc-index nil t)
+		(mu4e--server-index nil t))
+{{ end }}	    (when new-request
+	      (run-at-time (* 1.1 mu4e-reindex-request-min-seperation) nil
+			   #'mu4e-reindex-maybe)))))
+    )
@@ -1,4 +1,3 @@
-(diminish 'overwrite-mode)
 (if (not (eq system-type 'windows-nt))
     (progn
       (if (eq system-type 'darwin)
@@ -9,46 +8,59 @@
 	  )
       (require 'mu4e)
       (require 'smtpmail)
+      (require 'subr-x)
+
+      (defun mailconf-read-signature (file)
+	"Return the content of FILE, or nil if it cannot be read."
+	(when (file-readable-p file)
+	  (with-temp-buffer
+	    (insert-file-contents file)
+	    (string-trim-right (buffer-string)))))
+
+      (defun mailconf-addresses-regexp (addresses)
+	"Return a regexp matching exactly one of ADDRESSES."
+	(concat "\\`" (regexp-opt addresses) "\\'"))
+
       (setq mu4e-contexts
-	    `( {{ range $Profile := . }},(make-mu4e-context
-		 :name "{{ $Profile.Name }}"
+	    `( ,(make-mu4e-context
+		 :name "Work"
 		 :enter-func (lambda () (progn
-					  (mu4e-message "Entering {{ $Profile.Name }} context")
+					  (mu4e-message "Entering Work context")
 					  (setq message-send-mail-function 'smtpmail-send-it
 						starttls-use-gnutls t
 						smtpmail-starttls-credentials
-						'(("{{ $Profile.SmtpHost }}" {{ $Profile.SmtpPort }} nil nil))
-						smtpmail-default-smtp-server "{{ $Profile.SmtpHost }}"
-						smtpmail-smtp-server "{{ $Profile.SmtpHost }}"
-						smtpmail-smtp-service {{ $Profile.SmtpPort }}
-						smtpmail-debug-info t)
-					  (if (eq system-type 'darwin)
-					      (setq browse-url-chrome-arguments '("--profile-directory=Profile 1"))
-					      )))
-		 :leave-func (lambda () (mu4e-message "Leaving {{ $Profile.Name }} context"))
-		 ;; we match based on the contact-fields of the message
+						'(("smtp.gmail.com" 587 nil nil))
+						smtpmail-default-smtp-server "smtp.gmail.com"
+						smtpmail-smtp-server "smtp.gmail.com"
+						smtpmail-smtp-service 587
+						smtpmail-debug-info t)))
+		 :leave-func (lambda () (mu4e-message "Leaving Work context"))
+		 ;; we match based on the maildir and on the contact-fields of the message
 		 :match-func (lambda (msg)
 			       (when msg
-				 (string-match-p "^/{{ $Profile.Name }}" (mu4e-message-field msg :maildir))))
-		 :vars '( ( user-mail-address      . "{{ $Profile.Email }}"  )
-			 ( user-full-name         . "{{ $Profile.FullName }}" )
-			 ( mu4e-compose-signature . "{{ $Profile.FullName }}")
-			 ( mu4e-drafts-folder     . "/{{ $Profile.Name }}/drafts")
-			 ( mu4e-sent-folder       . "/{{ $Profile.Name }}/sent")
-			 ( mu4e-refile-folder     . "/{{ $Profile.Name }}/email-archive")
-			 ( mu4e-trash-folder      . "/{{ $Profile.Name }}/trash")
-			 ( smtpmail-smtp-user     . "{{ $Profile.SmtpUser }}")
+				 (or (string-match-p "^/Work/" (mu4e-message-field msg :maildir))
+				     (mu4e-message-contact-field-matches
+				      msg '(:to :cc :bcc)
+				      (mailconf-addresses-regexp '("jdoe@gmail.com"))))))
+		 :vars `( ( user-mail-address      . "jdoe@gmail.com"  )
+			 ( user-full-name         . "John Doe" )
+			 ( mu4e-compose-signature . "John Doe")
+			 ( mu4e-drafts-folder     . "/Work/Drafts")
+			 ( mu4e-sent-folder       . "/Work/Sent Items")
+			 ( mu4e-refile-folder     . "/Work/Archive")
+			 ( mu4e-trash-folder      . "/Work/Deleted Items")
+			 ( smtpmail-smtp-user     . "user@gmail.com")
 			 ( mu4e-get-mail-command  . "true")
-			 ( mu4e-maildir-shortcuts . (("/{{ $Profile.Name }}/INBOX" . ?i)
-						     ("/{{ $Profile.Name }}/sent" . ?s)
-						     ("/{{ $Profile.Name }}/email-archive" . ?a)
-						     ("/{{ $Profile.Name }}/trash" . ?t)))
-			 (mu4e-bookmarks          . (("date:1w..now AND NOT flag:trashed AND (maildir:/{{ $Profile.Name }}/INBOX OR maildir:/{{ $Profile.Name }}/sent)" "Last 7 days messages" ?w)
-						     ("date:1d..now AND NOT flag:trashed AND (maildir:/{{ $Profile.Name }}/INBOX OR maildir:/{{ $Profile.Name }}/sent)" "Yesterday and today messages" ?b)
-						     ("flag:unread AND NOT flag:trashed AND (maildir:/{{ $Profile.Name }}/INBOX OR maildir:/{{ $Profile.Name }}/sent)" "Unread messages" ?u)
-						     ("date:today..now AND NOT flag:trashed AND (maildir:/{{ $Profile.Name }}/INBOX OR maildir:/{{ $Profile.Name }}/sent)" "Today's messages" ?t)))
+			 ( mu4e-maildir-shortcuts . (("/Work/Inbox" . ?i)
+						     ("/Work/Sent Items" . ?s)
+						     ("/Work/Archive" . ?a)
+						     ("/Work/Deleted Items" . ?t)))
+			 (mu4e-bookmarks          . (("date:1w..now AND NOT flag:trashed AND (maildir:/Work/Inbox OR maildir:\"/Work/Sent Items\")" "Last 7 days messages" ?w)
+						     ("date:1d..now AND NOT flag:trashed AND (maildir:/Work/Inbox OR maildir:\"/Work/Sent Items\")" "Yesterday and today messages" ?b)
+						     ("flag:unread AND NOT flag:trashed AND (maildir:/Work/Inbox OR maildir:\"/Work/Sent Items\")" "Unread messages" ?u)
+						     ("date:today..now AND NOT flag:trashed AND (maildir:/Work/Inbox OR maildir:\"/Work/Sent Items\")" "Today's messages" ?t)))
 			 ))
-		{{ end }}
+		
 		))
 
       (setq mu4e-context-policy 'pick-first)
@@ -64,13 +76,9 @@
 	    mu4e-update-interval 300
 	    mu4e-headers-leave-behavior 'apply
 	    mu4e-view-show-addresses t
-	    mu4e-compose-in-new-frame t
+	    mu4e-compose-in-new-frame nil
 	    mu4e-user-agent-string nil
 	    message-kill-buffer-on-exit t)
-      (setq browse-url-browser-function 'browse-url-chrome)
-      (if (eq system-type 'darwin)
-	  (setq browse-url-chrome-program "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome")
-	  )
       (add-to-list 'mu4e-view-actions
 		   '("ViewInBrowser" . mu4e-action-view-in-browser) t)
       ;; enable inline images
@@ -91,8 +99,9 @@
       (advice-add #'shr-colorize-region :around (defun shr-no-colourise-region (&rest ignore)))
 
 
-      (require 'org-mu4e)
-      (global-set-key "\C-cm" 'mu4e)
+      ;; org links to messages.
+      (unless (require 'mu4e-org nil t)
+	(require 'org-mu4e nil t))
       )
     (defvar mu4e-reindex-request-file "/tmp/mail/mu_reindex_now"
       "Location of the reindex request, signaled by existance")
@@ -0,0 +1,5 @@
+#!/bin/sh
+
+notmuch new --quiet
+
+exit 0
@@ -61,7 +61,14 @@ func tmpl(text string, data interface{}) {
 
 const usageTemplate = `mailconf is tool to configure accounts for mbsync, imapfilter and mu4e
 Usage:
-	mailconf command [arguments]
+	mailconf [-config name|dir] command [arguments]
+The -config option, or the MAILCONF_CONFIG environment variable, selects
+the configuration to work on: either a directory, or the name of a config
+set kept in [user config dir]/mailconf/sets. The generated files are
+shared, so only the active configuration can generate them; see
+"mailconf help config sets". A directory outside [user config
+dir]/mailconf, such as a test sandbox, never generates files nor
+rebuilds the mail index: its commands only change its own config.
 The commands are:
 {{range .}}
 	{{.Name | printf "%-11s"}} {{.Short}}{{end}}
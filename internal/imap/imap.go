@@ -0,0 +1,337 @@
+// Package imap lists the mailboxes of an IMAP account and fetches the
+// certificate of its server, speaking just enough of the protocol to
+// log in, send LIST and STARTTLS.
+package imap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrProtocol = errors.New("unexpected IMAP response")
+	ErrRefused  = errors.New("IMAP command refused")
+	ErrNoTLS    = errors.New("IMAP server without TLS")
+)
+
+// Timeout bounds the whole conversation with the server.
+var Timeout = 30 * time.Second
+
+// Account is an IMAP account. SSL is "IMAPS", "STARTTLS" or "None", as
+// in the mbsync options of a profile.
+type Account struct {
+	Host     string
+	Port     uint16
+	SSL      string
+	User     string
+	Password string
+}
+
+// Client lists the mailboxes of an account and fetches the certificate
+// of its server.
+type Client interface {
+	List(a *Account) ([]string, error)
+	Certificate(a *Account) (*x509.Certificate, error)
+}
+
+var client Client = netClient{}
+
+// Set replaces the client used by List, returning the previous one.
+func Set(c Client) Client {
+	old := client
+	client = c
+	return old
+}
+
+// List returns the names of the mailboxes of a that can be selected,
+// sorted, with "/" separating the levels of the hierarchy as in mbsync.
+func List(a *Account) ([]string, error) {
+	return client.List(a)
+}
+
+// Certificate returns the certificate of the IMAP server of a, which
+// is not verified: it is up to the caller to decide whether to trust
+// it.
+func Certificate(a *Account) (*x509.Certificate, error) {
+	return client.Certificate(a)
+}
+
+type netClient struct{}
+
+func (netClient) List(a *Account) ([]string, error) {
+	conn, err := dial(a)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return list(conn, a, &tls.Config{ServerName: a.Host})
+}
+
+func (netClient) Certificate(a *Account) (*x509.Certificate, error) {
+	if a.SSL == "None" {
+		return nil, ErrNoTLS
+	}
+	conn, err := dial(a)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return certificate(conn, a)
+}
+
+// dial connects to the server of a, without starting TLS.
+func dial(a *Account) (net.Conn, error) {
+	addr := net.JoinHostPort(a.Host, strconv.Itoa(int(a.Port)))
+	conn, err := net.DialTimeout("tcp", addr, Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(Timeout))
+	return conn, nil
+}
+
+// start reads the greeting of the server on conn, starting TLS with
+// config first for IMAPS and after it for STARTTLS. It returns the
+// session and whether the server authenticated the client already.
+func start(conn net.Conn, a *Account, config *tls.Config) (*session, *tls.Conn, bool, error) {
+	var tconn *tls.Conn
+	s := &session{r: bufio.NewReader(conn), w: conn}
+	if a.SSL == "" || a.SSL == "IMAPS" {
+		tconn = tls.Client(conn, config)
+		s = &session{r: bufio.NewReader(tconn), w: tconn}
+	}
+	line, err := s.readLine()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+		return nil, nil, false, fmt.Errorf("%w: %s", ErrProtocol, line)
+	}
+	if a.SSL == "STARTTLS" {
+		if _, err := s.command("STARTTLS"); err != nil {
+			return nil, nil, false, err
+		}
+		tconn = tls.Client(conn, config)
+		s = &session{r: bufio.NewReader(tconn), w: tconn, tag: s.tag}
+	}
+	return s, tconn, strings.HasPrefix(line, "* PREAUTH"), nil
+}
+
+// certificate returns the certificate the server presents on conn.
+func certificate(conn net.Conn, a *Account) (*x509.Certificate, error) {
+	// the certificate is shown to the user, who decides whether
+	// to trust it.
+	s, tconn, _, err := start(conn, a, &tls.Config{ServerName: a.Host, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	if tconn == nil {
+		return nil, ErrNoTLS
+	}
+	if err := tconn.Handshake(); err != nil {
+		return nil, err
+	}
+	certs := tconn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificate", ErrProtocol)
+	}
+	s.command("LOGOUT")
+	return certs[0], nil
+}
+
+// list runs the conversation with the server on conn, checking its
+// certificate with config.
+func list(conn net.Conn, a *Account, config *tls.Config) ([]string, error) {
+	s, _, preauth, err := start(conn, a, config)
+	if err != nil {
+		return nil, err
+	}
+	if !preauth {
+		user, err := quote(a.User)
+		if err != nil {
+			return nil, err
+		}
+		pass, err := quote(a.Password)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.command("LOGIN " + user + " " + pass); err != nil {
+			return nil, err
+		}
+	}
+	untagged, err := s.command(`LIST "" "*"`)
+	if err != nil {
+		return nil, err
+	}
+	var boxes []string
+	for _, line := range untagged {
+		name, ok, err := parseList(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			boxes = append(boxes, name)
+		}
+	}
+	s.command("LOGOUT")
+	sort.Strings(boxes)
+	return boxes, nil
+}
+
+type session struct {
+	r   *bufio.Reader
+	w   io.Writer
+	tag int
+}
+
+// command sends cmd and returns the untagged responses received before
+// the tagged one, which must be OK.
+func (s *session) command(cmd string) ([]string, error) {
+	s.tag++
+	tag := fmt.Sprintf("a%d", s.tag)
+	if _, err := fmt.Fprintf(s.w, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+	var untagged []string
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+		status := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(status, "OK") {
+			verb := strings.Fields(cmd)[0]
+			return nil, fmt.Errorf("%w: %s: %s", ErrRefused, verb, status)
+		}
+		return untagged, nil
+	}
+}
+
+// readLine reads a response line, replacing the literals it contains
+// with quoted strings.
+func (s *session) readLine() (string, error) {
+	var b strings.Builder
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		n, prefix, ok := literal(line)
+		if !ok {
+			b.WriteString(line)
+			return b.String(), nil
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(s.r, data); err != nil {
+			return "", err
+		}
+		b.WriteString(prefix)
+		b.WriteString(`"`)
+		b.WriteString(escape(string(data)))
+		b.WriteString(`"`)
+	}
+}
+
+// literal reports whether line ends with the size of a literal,
+// {n}, returning the size and what precedes it.
+func literal(line string) (int, string, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, "", false
+	}
+	i := strings.LastIndex(line, "{")
+	if i < 0 {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+	if err != nil {
+		return 0, "", false
+	}
+	return n, line[:i], true
+}
+
+// quote returns s as an IMAP quoted string.
+func quote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("%w: line break in %q", ErrProtocol, s)
+	}
+	return `"` + escape(s) + `"`, nil
+}
+
+// escape escapes the backslashes and double quotes in s.
+func escape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// parseList parses an untagged LIST response, such as `* LIST
+// (\HasNoChildren) "." "Projects.Alpha"`, returning the name of the
+// mailbox with "/" separating its levels, and whether it can be
+// selected. Other responses are skipped.
+func parseList(line string) (string, bool, error) {
+	rest := strings.TrimPrefix(line, "* LIST ")
+	if rest == line {
+		return "", false, nil
+	}
+	end := strings.Index(rest, ")")
+	if !strings.HasPrefix(rest, "(") || end < 0 {
+		return "", false, fmt.Errorf("%w: %s", ErrProtocol, line)
+	}
+	flags := strings.ToLower(rest[1:end])
+	rest = strings.TrimSpace(rest[end+1:])
+	delim, rest, err := token(rest)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %s", ErrProtocol, line)
+	}
+	name, _, err := token(strings.TrimSpace(rest))
+	if err != nil || name == "" {
+		return "", false, fmt.Errorf("%w: %s", ErrProtocol, line)
+	}
+	if strings.Contains(flags, `\noselect`) || strings.Contains(flags, `\nonexistent`) {
+		return "", false, nil
+	}
+	if delim != "" && delim != "NIL" && delim != "/" {
+		name = strings.ReplaceAll(name, delim, "/")
+	}
+	return name, true, nil
+}
+
+// token returns the quoted string or atom s starts with, and what
+// follows it.
+func token(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexByte(s, ' ')
+		if i < 0 {
+			return s, "", nil
+		}
+		return s[:i], s[i+1:], nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", ErrProtocol
+}
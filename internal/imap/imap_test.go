@@ -0,0 +1,141 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers the commands read from conn with the responses in
+// script, keyed by the command without its tag.
+func serve(conn net.Conn, greeting string, script map[string]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	conn.Write([]byte(greeting + "\r\n"))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		resp, ok := script[cmd]
+		if !ok {
+			resp = "%s BAD unknown command"
+		}
+		conn.Write([]byte(strings.ReplaceAll(resp, "%s", tag) + "\r\n"))
+	}
+}
+
+func TestList(t *testing.T) {
+	tt := []struct {
+		name     string
+		greeting string
+		script   map[string]string
+		want     []string
+		err      error
+	}{
+		{
+			"list",
+			"* OK IMAP4rev1 ready",
+			map[string]string{
+				`LOGIN "jdoe" "p\"w"`: "%s OK logged in",
+				`LIST "" "*"`: strings.Join([]string{
+					`* LIST (\HasNoChildren) "." "INBOX"`,
+					`* LIST (\Noselect \HasChildren) "." "Projects"`,
+					`* LIST (\HasNoChildren) "." "Projects.Alpha"`,
+					`* LIST (\HasNoChildren) "." {9}`,
+					`Old Stuff`,
+					`* LIST (\HasNoChildren) "." Clients`,
+					`%s OK done`,
+				}, "\r\n"),
+				"LOGOUT": "* BYE\r\n%s OK bye",
+			},
+			[]string{"Clients", "INBOX", "Old Stuff", "Projects/Alpha"},
+			nil,
+		},
+		{
+			"refused",
+			"* OK IMAP4rev1 ready",
+			map[string]string{
+				`LOGIN "jdoe" "p\"w"`: "%s NO authentication failed",
+			},
+			nil,
+			ErrRefused,
+		},
+		{
+			"greeting",
+			"* BYE too many connections",
+			nil,
+			nil,
+			ErrProtocol,
+		},
+	}
+	for _, tc := range tt {
+		client, server := net.Pipe()
+		go serve(server, tc.greeting, tc.script)
+		got, err := list(client, &Account{SSL: "None", User: "jdoe", Password: `p"w`}, nil)
+		client.Close()
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %q, want: %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "imap.example.com"},
+		DNSNames:     []string{"imap.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		server.Write([]byte("* OK ready\r\n"))
+		line, _ := r.ReadString('\n')
+		if line != "a1 STARTTLS\r\n" {
+			server.Write([]byte("a1 BAD expected STARTTLS\r\n"))
+			return
+		}
+		server.Write([]byte("a1 OK begin TLS\r\n"))
+		tconn := tls.Server(server, config)
+		r = bufio.NewReader(tconn)
+		r.ReadString('\n')
+		tconn.Write([]byte("* BYE\r\na2 OK bye\r\n"))
+	}()
+	cert, err := certificate(client, &Account{Host: "imap.example.com", Port: 143, SSL: "STARTTLS"})
+	client.Close()
+	if err != nil {
+		t.Fatalf("cannot fetch the certificate: %v", err)
+	}
+	if !bytes.Equal(cert.Raw, der) {
+		t.Fatalf("got certificate of %s, want the server one", cert.Subject)
+	}
+}
@@ -0,0 +1,74 @@
+package memimap
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/imap"
+)
+
+// Client lists the mailboxes set for the accounts instead of asking
+// their servers.
+type Client struct {
+	boxes  map[string][]string
+	errs   map[string]error
+	certs  map[string]*x509.Certificate
+	listed []*imap.Account
+}
+
+func New() *Client {
+	return &Client{
+		boxes: make(map[string][]string),
+		errs:  make(map[string]error),
+		certs: make(map[string]*x509.Certificate),
+	}
+}
+
+// Reset forgets the mailboxes, the certificates and the accounts
+// listed.
+func (c *Client) Reset() {
+	*c = *New()
+}
+
+func key(user, host string, port uint16) string {
+	return fmt.Sprintf("%s@%s:%d", user, host, port)
+}
+
+// SetMailboxes makes List return boxes, or err, for the account of user
+// on host:port.
+func (c *Client) SetMailboxes(user, host string, port uint16, boxes []string, err error) {
+	c.boxes[key(user, host, port)] = boxes
+	c.errs[key(user, host, port)] = err
+}
+
+func (c *Client) List(a *imap.Account) ([]string, error) {
+	c.listed = append(c.listed, a)
+	k := key(a.User, a.Host, a.Port)
+	if err := c.errs[k]; err != nil {
+		return nil, err
+	}
+	boxes, ok := c.boxes[k]
+	if !ok {
+		return nil, fmt.Errorf("no mailboxes for %s", k)
+	}
+	return boxes, nil
+}
+
+// SetCertificate makes Certificate return cert for the server
+// host:port.
+func (c *Client) SetCertificate(host string, port uint16, cert *x509.Certificate) {
+	c.certs[fmt.Sprintf("%s:%d", host, port)] = cert
+}
+
+func (c *Client) Certificate(a *imap.Account) (*x509.Certificate, error) {
+	cert, ok := c.certs[fmt.Sprintf("%s:%d", a.Host, a.Port)]
+	if !ok {
+		return nil, fmt.Errorf("no certificate for %s:%d", a.Host, a.Port)
+	}
+	return cert, nil
+}
+
+// Listed returns the accounts List was called with.
+func (c *Client) Listed() []*imap.Account {
+	return c.listed
+}
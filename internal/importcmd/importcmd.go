@@ -0,0 +1,232 @@
+package importcmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/adopt"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/bundle"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/cred"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/myterm"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdImport = &base.Command{
+	UsageLine: "import [-f] [-dry-run] [-v] [-from-mbsyncrc file] [-from-msmtprc file] [-from-goimapnotify file] [-from-mu4e file] [bundle]",
+	Short:     "import adds the profiles of a bundle or of existing configs",
+	Long: `
+
+Import adds the profiles in a bundle written by "mailconf export" to
+the configuration, and generates the configuration of the mail
+programs for them.
+
+If the bundle holds credentials, the passphrase used to export them is
+asked for and the passwords of the imported profiles are stored in the
+credentials store, replacing the existing ones. Nothing is stored if
+any profile of the bundle cannot be imported.
+
+Instead of a bundle, import can read hand-written configurations:
+
+	-from-mbsyncrc file      IMAPAccount, IMAPStore and Channel sections
+	                         become profiles and their folder maps
+	-from-goimapnotify file  imap settings; may be repeated
+	-from-msmtprc file       smtp settings of the accounts
+	-from-mu4e file          full name, address and smtp settings of the
+	                         make-mu4e-context forms
+
+Settings that cannot be mapped to a profile, such as passwords, are
+reported. Before the configuration is generated, every file read is
+backed up to <file>.pre-mailconf.
+
+A profile with the same name as an existing one is an error, unless
+the -f flag is given, which replaces it. The profiles are all checked
+before any is added, and none is added if one cannot be.
+
+The -dry-run flag shows changes without making any, the -v flag shows
+the content of the files to be written.`,
+}
+
+// files is a flag that can be given more than once.
+type files []string
+
+func (f *files) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *files) Set(file string) error {
+	*f = append(*f, file)
+	return nil
+}
+
+var (
+	fromMbsyncrc     files
+	fromMsmtprc      files
+	fromGoimapnotify files
+	fromMu4e         files
+	force            bool
+	dryrun           bool
+	verbose          bool
+	ErrNoConfig      = config.ErrNoConfig
+	ErrNoBundle      = errors.New("Missing bundle.")
+	ErrNoProfiles    = errors.New("No profiles found.")
+)
+
+func init() {
+	CmdImport.Run = runImport
+	CmdImport.Flag.BoolVar(&force, "f", false, "Replace existing profiles.")
+	CmdImport.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdImport.Flag.BoolVar(&verbose, "v", false, "Show content of files to be written.")
+	CmdImport.Flag.Var(&fromMbsyncrc, "from-mbsyncrc", "Import the accounts of an mbsync config.")
+	CmdImport.Flag.Var(&fromMsmtprc, "from-msmtprc", "Import the accounts of an msmtp config.")
+	CmdImport.Flag.Var(&fromGoimapnotify, "from-goimapnotify", "Import a goimapnotify config.")
+	CmdImport.Flag.Var(&fromMu4e, "from-mu4e", "Import the mu4e contexts of an emacs config.")
+}
+
+func runImport(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	adopting := len(fromMbsyncrc)+len(fromMsmtprc)+len(fromGoimapnotify)+len(fromMu4e) > 0
+	if adopting && len(args) != 0 || !adopting && len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf %s\n", CmdImport.UsageLine)
+		return ErrNoBundle
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if adopting {
+		return runAdopt(cfg)
+	}
+
+	b, err := bundle.Read(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read bundle: %v\n", err)
+		return err
+	}
+
+	// nothing, credentials included, is stored for a bundle that
+	// cannot be imported.
+	err = mailconf.CheckImport(b.Profiles, cfg, force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot import the bundle: %v\n", err)
+		return err
+	}
+
+	if b.Credentials != nil {
+		t := myterm.New()
+		pass, err := t.ReadPass("bundle passphrase: ")
+		if err != nil {
+			return err
+		}
+		creds, err := b.Open(pass)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read credentials: %v\n", err)
+			return err
+		}
+		err = storeCreds(forProfiles(creds, b.Profiles))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot store credentials: %v\n", err)
+			return err
+		}
+	}
+
+	return importProfiles(cfg, b.Profiles)
+}
+
+func importProfiles(cfg *config.Config, profiles []*config.Profile) error {
+	err := mailconf.ImportProfiles(profiles, cfg, force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot import the profiles: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
+
+// runAdopt imports the profiles found in the files given with the
+// -from flags, after backing the files up.
+func runAdopt(cfg *config.Config) error {
+	r := &adopt.Result{MaildirRoot: cfg.MaildirRoot}
+	var read []string
+	for _, src := range []struct {
+		files files
+		read  func(string) error
+	}{
+		{fromMbsyncrc, r.Mbsyncrc},
+		{fromGoimapnotify, r.Goimapnotify},
+		{fromMsmtprc, r.Msmtprc},
+		{fromMu4e, r.Mu4e},
+	} {
+		for _, file := range src.files {
+			err := src.read(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot import %s: %v\n", file, err)
+				return err
+			}
+			read = append(read, file)
+		}
+	}
+	r.Finish()
+	if len(r.Unmapped) > 0 {
+		fmt.Fprintf(os.Stderr, "not imported:\n")
+		for _, msg := range r.Unmapped {
+			fmt.Fprintf(os.Stderr, "\t%s\n", msg)
+		}
+	}
+	if len(r.Profiles) == 0 {
+		fmt.Fprintf(os.Stderr, "no profiles found.\n")
+		return ErrNoProfiles
+	}
+
+	for _, file := range read {
+		err := io.Backup(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot back up %s: %v\n", file, err)
+			return err
+		}
+	}
+	return importProfiles(cfg, r.Profiles)
+}
+
+// forProfiles returns the credentials of the imap and smtp accounts of
+// profiles.
+func forProfiles(creds []*bundle.Credential, profiles []*config.Profile) []*bundle.Credential {
+	var kept []*bundle.Credential
+	for _, cr := range creds {
+		for _, p := range profiles {
+			imap := cr.Service == "imap" && cr.User == p.ImapUser && cr.Host == p.ImapHost && cr.Port == p.ImapPort
+			smtp := cr.Service == "smtp" && cr.User == p.SmtpUser && cr.Host == p.SmtpHost && cr.Port == p.SmtpPort
+			if imap || smtp {
+				kept = append(kept, cr)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+func storeCreds(creds []*bundle.Credential) error {
+	c := cred.New()
+	for _, cr := range creds {
+		if options.Dryrun() || options.Verbose() {
+			fmt.Printf("storing the password of %s://%s@%s:%d\n", cr.Service, cr.User, cr.Host, cr.Port)
+		}
+		if options.Dryrun() {
+			continue
+		}
+		err := c.Add(cr.User, cr.Service, cr.Host, cr.Port, cr.Password)
+		if errors.Is(err, cred.ErrExistingCreds) {
+			err = c.Update(cr.User, cr.Service, cr.Host, cr.Port, cr.Password)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package importcmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/bundle"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/cred"
+	"github.com/gianz74/mailconf/internal/cred/memcred"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/exec/memexec"
+	"github.com/gianz74/mailconf/internal/myterm"
+	"github.com/gianz74/mailconf/internal/myterm/memterm"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/testutil"
+	"github.com/spf13/afero"
+)
+
+var (
+	mockTerm      = memterm.New()
+	mockCredStore = memcred.New()
+	mockExec      = memexec.New()
+)
+
+const data = `{"version": 1, "profiles": [{"profile_name": "Work", "email": "jdoe@gmail.com", "full_name": "John Doe", "imaphost": "imap.gmail.com", "imapport": 993, "imapuser": "user@gmail.com", "smtphost": "smtp.gmail.com", "smtpport": 587, "smtpuser": "user@gmail.com"}]}`
+
+var work = &config.Profile{
+	Name:     "Work",
+	FullName: "John Doe",
+	Email:    "jdoe@gmail.com",
+	ImapHost: "imap.gmail.com",
+	ImapPort: 993,
+	ImapUser: "user@gmail.com",
+	SmtpHost: "smtp.gmail.com",
+	SmtpPort: 587,
+	SmtpUser: "user@gmail.com",
+}
+
+var home = &config.Profile{
+	Name:     "Home",
+	FullName: "John Doe",
+	Email:    "jdoe@home.org",
+	ImapHost: "imap.home.org",
+	ImapPort: 993,
+	ImapUser: "jdoe@home.org",
+	SmtpHost: "smtp.home.org",
+	SmtpPort: 587,
+	SmtpUser: "jdoe@home.org",
+}
+
+func setup(b *bundle.Bundle) {
+	myterm.SetTerm(mockTerm)
+	cred.SetStore(mockCredStore)
+	exec.Set(mockExec)
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	os.Set(&afero.Afero{Fs: afero.NewMemMapFs()})
+	os.WriteFile("/home/user/.config/mailconf/data.json", []byte(data), 0640)
+	out, _ := b.Marshal()
+	os.WriteFile("/home/user/work.bundle", out, 0600)
+}
+
+func seal(t *testing.T, profiles ...*config.Profile) *bundle.Bundle {
+	b := bundle.New(profiles)
+	var creds []*bundle.Credential
+	for _, p := range profiles {
+		creds = append(creds, &bundle.Credential{Service: "imap", User: p.ImapUser, Host: p.ImapHost, Port: p.ImapPort, Password: "secret"})
+	}
+	err := b.Seal(creds, "passphrase")
+	if err != nil {
+		t.Fatalf("cannot seal credentials: %v", err)
+	}
+	return b
+}
+
+func TestImport(t *testing.T) {
+	tt := []struct {
+		name   string
+		bundle *bundle.Bundle
+		chat   []string
+		err    error
+	}{
+		{
+			"ProfileExists",
+			bundle.New([]*config.Profile{work}),
+			[]string{},
+			mailconf.ErrProfileExists,
+		},
+		{
+			"WrongPassphrase",
+			seal(t, home),
+			[]string{"guess"},
+			bundle.ErrPassphrase,
+		},
+		// the passwords of a bundle that cannot be imported are not
+		// stored, not even the ones of the new profiles.
+		{
+			"ProfileExistsWithCredentials",
+			seal(t, home, work),
+			[]string{"passphrase"},
+			mailconf.ErrProfileExists,
+		},
+	}
+	for _, tc := range tt {
+		setup(tc.bundle)
+		mockCredStore.AddBulk(nil)
+		mockTerm.SetLines(tc.chat)
+		err := runImport(nil, []string{"/home/user/work.bundle"})
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		got, err := os.ReadFile("/home/user/.config/mailconf/data.json")
+		if err != nil || string(got) != data {
+			t.Fatalf("%s: config modified: %s", tc.name, got)
+		}
+		if len(mockCredStore) != 0 {
+			t.Fatalf("%s: credentials stored: %v", tc.name, mockCredStore)
+		}
+		if _, err := os.ReadDir("/home/user/.config/imapnotify/Home"); err == nil {
+			t.Fatalf("%s: configuration of Home generated", tc.name)
+		}
+	}
+}
+
+func TestForProfiles(t *testing.T) {
+	creds := []*bundle.Credential{
+		{Service: "imap", User: "user@gmail.com", Host: "imap.gmail.com", Port: 993, Password: "imap"},
+		{Service: "smtp", User: "user@gmail.com", Host: "smtp.gmail.com", Port: 587, Password: "smtp"},
+		{Service: "imap", User: "jdoe@home.org", Host: "imap.home.org", Port: 993, Password: "home"},
+	}
+	got := forProfiles(creds, []*config.Profile{work})
+	if len(got) != 2 || got[0] != creds[0] || got[1] != creds[1] {
+		t.Fatalf("got credentials %+v, want the ones of Work", got)
+	}
+}
+
+func TestStoreCreds(t *testing.T) {
+	cred.SetStore(mockCredStore)
+	mockCredStore.AddBulk([]string{"imap://user@gmail.com:old@imap.gmail.com:993"})
+	err := storeCreds([]*bundle.Credential{
+		{Service: "imap", User: "user@gmail.com", Host: "imap.gmail.com", Port: 993, Password: "new"},
+		{Service: "smtp", User: "user@gmail.com", Host: "smtp.gmail.com", Port: 587, Password: "smtp"},
+	})
+	if err != nil {
+		t.Fatalf("cannot store credentials: %v", err)
+	}
+	for _, c := range []string{
+		"imap://user@gmail.com:new@imap.gmail.com:993",
+		"smtp://user@gmail.com:smtp@smtp.gmail.com:587",
+	} {
+		got, want := testutil.CheckCreds(c)
+		if got != want {
+			t.Fatalf("got: %s, want: %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package indexcmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdIndex = &base.Command{
+	UsageLine: "index [-rebuild -dry-run -v]",
+	Short:     "index adds the synced mail to the mail index",
+	Long: `
+
+Index runs "mu index", or "notmuch new" with the notmuch frontend, to
+add the mail synced since the last run to the index.
+
+The -rebuild option creates the index again from scratch: with mu4e it
+runs "mu init" with the addresses of all the profiles and of their
+identities, and then "mu index". mailconf does it on its own when the
+addresses or the maildir root change, but mu cannot open the index
+while mu4e is running; after other changes, such as renaming a
+profile, it leaves the rebuild to this command.
+
+The -dry-run option allows the user to preview the commands without
+actually running them.
+
+The -v option increases verbosity, printing the commands that are
+about to be run.`,
+}
+
+var (
+	rebuild     bool
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdIndex.Run = runIndex
+	CmdIndex.Flag.BoolVar(&rebuild, "rebuild", false, "Create the index again from scratch.")
+	CmdIndex.Flag.BoolVar(&dryrun, "dry-run", false, "Show commands without running them.")
+	CmdIndex.Flag.BoolVar(&verbose, "v", false, "Print commands about to be run.")
+}
+
+func runIndex(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf %s\n", CmdIndex.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.Index(rebuild, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot index the mail: %v\n", err)
+		return err
+	}
+	return nil
+}
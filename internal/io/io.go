@@ -1,9 +1,12 @@
 package io
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
 	"path"
+	"syscall"
 
 	"github.com/gianz74/mailconf/internal/options"
 	"github.com/gianz74/mailconf/internal/os"
@@ -27,9 +30,153 @@ func Write(out string, in []byte, perm fs.FileMode) error {
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(out, in, perm)
+	err = os.WriteFileAtomic(out, in, perm)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// Move renames from to to, creating the parent of to. It does nothing
+// if from does not exist, and fails if to does. Across file systems,
+// where rename fails with EXDEV, from is copied to to, the copy checked
+// against from and from removed; if the copy fails, what was copied is
+// removed, leaving from as it was.
+func Move(from, to string) error {
+	if !exists(from) {
+		return nil
+	}
+	if exists(to) {
+		return fmt.Errorf("cannot move %s to %s: %w", from, to, fs.ErrExist)
+	}
+	if options.Dryrun() || options.Verbose() {
+		fmt.Printf("moving %s to %s\n", from, to)
+	}
+	if options.Dryrun() {
+		return nil
+	}
+	err := os.MkdirAll(path.Dir(to), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(from, to)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if options.Verbose() {
+		fmt.Printf("%s is on another file system: copying %s\n", path.Dir(to), from)
+	}
+	err = copyAll(from, to)
+	if err == nil {
+		err = compare(from, to)
+	}
+	if err != nil {
+		if rerr := os.RemoveAll(to); rerr != nil {
+			fmt.Fprintf(os.Stderr, "Cannot remove the partial copy %s: %v\n", to, rerr)
+		}
+		return fmt.Errorf("cannot copy %s to %s: %w", from, to, err)
+	}
+	return os.RemoveAll(from)
+}
+
+// copyAll copies the directory or regular file from to to.
+func copyAll(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(to, data, 0600)
+	}
+	err = os.MkdirAll(to, 0700)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		src, dst := path.Join(from, e.Name()), path.Join(to, e.Name())
+		switch {
+		case e.IsDir():
+			err = copyAll(src, dst)
+		case e.Mode().IsRegular():
+			var data []byte
+			data, err = os.ReadFile(src)
+			if err == nil {
+				err = os.WriteFile(dst, data, e.Mode().Perm())
+			}
+		default:
+			err = fmt.Errorf("%s is not a regular file", src)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compare checks that the files in to, copied from from, hold the
+// same data.
+func compare(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		src, dst := path.Join(from, e.Name()), path.Join(to, e.Name())
+		if e.IsDir() {
+			err = compare(src, dst)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		want, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s differs from %s", dst, src)
+		}
+	}
+	return nil
+}
+
+// Backup copies file to file.pre-mailconf, before mailconf replaces a
+// file it did not write, unless an earlier backup exists.
+func Backup(file string) error {
+	bak := file + ".pre-mailconf"
+	if exists(bak) {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return Write(bak, data, 0600)
+}
+
+// Remove removes file. It does nothing if file does not exist.
+func Remove(file string) error {
+	if !exists(file) {
+		return nil
+	}
+	if options.Dryrun() || options.Verbose() {
+		fmt.Printf("removing %s\n", file)
+	}
+	if options.Dryrun() {
+		return nil
+	}
+	return os.RemoveAll(file)
+}
+
+func exists(file string) bool {
+	if _, err := os.ReadDir(file); err == nil {
+		return true
+	}
+	_, err := os.ReadFile(file)
+	return err == nil
+}
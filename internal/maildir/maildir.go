@@ -0,0 +1,60 @@
+package maildir
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/maildir/move"
+)
+
+var CmdMaildir = &base.Command{
+	UsageLine: "maildir command",
+	Short:     "maildir manages the directory holding the mail",
+}
+
+func init() {
+	CmdMaildir.Run = runMaildir
+	CmdMaildir.Commands = []*base.Command{
+		move.CmdMove,
+	}
+	CmdMaildir.Long = tmpl(usageTemplate, CmdMaildir.Commands)
+}
+
+func runMaildir(cmd *base.Command, args []string) error {
+	for _, cmd := range cmd.Commands {
+		cmd.Flag.Usage = cmd.Usage
+		if len(args) > 0 && cmd.Name() == args[0] {
+			cmd.Flag.Parse(args[1:])
+			args = cmd.Flag.Args()
+			return cmd.Run(cmd, args)
+		}
+	}
+	fmt.Println(tmpl(usageTemplate, cmd.Commands))
+	return nil
+}
+
+func tmpl(text string, data interface{}) string {
+	t := template.New("top")
+	t.Funcs(template.FuncMap{"trim": strings.TrimSpace})
+	template.Must(t.Parse(text))
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, data); err != nil {
+		panic(err)
+	}
+	return string(out.Bytes())
+}
+
+const usageTemplate = `maildir is a subcommand to manage the maildir root, the directory
+holding the maildirs of all the profiles, ~/Maildir by default.
+
+Usage:
+	mailconf maildir command [arguments]
+
+The commands are:
+{{range .}}
+	{{.Name | printf "%-11s"}} {{.Short}}{{end}}
+
+Use "mailconf help maildir [command]" for more information about a command.`
@@ -0,0 +1,75 @@
+package move
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdMove = &base.Command{
+	UsageLine: "move [-dry-run -v] newroot",
+	Short:     "move moves the mail to another maildir root",
+	Long: `
+
+Move moves the mail of all the profiles to the maildir root newroot,
+which must not exist yet. The mbsync and imapnotify services are
+stopped first, so that no mail is written during the move, and the
+whole maildir root is renamed at once: the mail is either all in the
+old root or all in the new one, and newroot must be on the same file
+system. The maildirs set in the profiles are moved along with the
+root.
+
+The configuration of mbsync, of the frontend and of the clients is
+then generated again, with the services started, and the mail is
+indexed from scratch.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdMove.Run = runMove
+	CmdMove.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdMove.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runMove(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf maildir %s\n", CmdMove.UsageLine)
+		return ErrUsage
+	}
+	newroot, err := filepath.Abs(os.ExpandUser(args[0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.MoveMaildir(newroot, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot move the maildir: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,42 @@
+package os
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrLocked is returned by Lock when the lock is held by someone else.
+var ErrLocked = errors.New("lock held by another process")
+
+var (
+	mu     sync.Mutex
+	locked = map[string]bool{}
+)
+
+// Lock takes an advisory, non blocking lock on the file at path,
+// creating it if needed, and returns the function releasing it. On the
+// real file system the lock is an flock(2), so it excludes other
+// processes; on the file systems used by tests it only excludes other
+// holders in the same process.
+func Lock(path string) (func(), error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if locked[path] {
+		return nil, ErrLocked
+	}
+	release := func() {}
+	if _, ok := fs.(osFs); ok {
+		var err error
+		release, err = flock(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	locked[path] = true
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		release()
+		delete(locked, path)
+	}, nil
+}
@@ -1,17 +1,26 @@
 package os
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 type File = os.File
 
+type FileInfo = os.FileInfo
+
 type FsAccess interface {
 	MkdirAll(string, os.FileMode) error
 	WriteFile(string, []byte, os.FileMode) error
 	ReadFile(string) ([]byte, error)
+	ReadDir(string) ([]os.FileInfo, error)
 	RemoveAll(string) error
+	Rename(string, string) error
 }
 
 var (
@@ -22,6 +31,8 @@ var (
 	ModePerm               = os.ModePerm
 	UserConfigDir          = os.UserConfigDir
 	UserHomeDir            = os.UserHomeDir
+	Getenv                 = os.Getenv
+	Getpid                 = os.Getpid
 	System                 = runtime.GOOS
 )
 
@@ -49,6 +60,14 @@ func (osFs) ReadFile(filename string) ([]byte, error) {
 	return os.ReadFile(filename)
 }
 
+func (osFs) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dirname)
+}
+
 func MkdirAll(path string, perm os.FileMode) error {
 	return fs.MkdirAll(path, perm)
 }
@@ -65,6 +84,60 @@ func ReadFile(filename string) ([]byte, error) {
 	return fs.ReadFile(filename)
 }
 
+func Rename(oldpath, newpath string) error {
+	return fs.Rename(oldpath, newpath)
+}
+
+// WriteFileAtomic writes data to a temporary file next to filename and
+// renames it over filename, so readers never see a partial file.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp := path.Join(path.Dir(filename), fmt.Sprintf(".%s.%d.tmp", path.Base(filename), Getpid()))
+	err := fs.WriteFile(tmp, data, perm)
+	if err != nil {
+		return err
+	}
+	err = fs.Rename(tmp, filename)
+	if err != nil {
+		fs.RemoveAll(tmp)
+		return err
+	}
+	return nil
+}
+
+func ReadDir(dirname string) ([]os.FileInfo, error) {
+	return fs.ReadDir(dirname)
+}
+
 func Exit(code int) {
 	os.Exit(code)
 }
+
+// XDGConfigDir returns the config directory of the programs following
+// the XDG base directory spec, such as msmtp, aerc and neomutt, which
+// use ~/.config on macOS too.
+func XDGConfigDir() (string, error) {
+	if System != "darwin" {
+		return UserConfigDir()
+	}
+	if dir := Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".config"), nil
+}
+
+// ExpandUser replaces a leading "~" in path with the user's home
+// directory.
+func ExpandUser(path string) string {
+	home, _ := UserHomeDir()
+
+	if path == "~" {
+		return home
+	} else if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
@@ -1,7 +1,6 @@
 package add
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/gianz74/mailconf"
@@ -18,7 +17,10 @@ var CmdAdd = &base.Command{
 	Long: `
 
 Add creates a new profile, asking the user to provide the required
-information.
+information. The certificate of the IMAP server is then fetched and
+shown with its fingerprint: the profile is added only if the user
+trusts it, and imapfilter accepts the server from then on. See
+"mailconf help check".
 
 The -dry-run option allows the user to preview the changes without
 actually making any to the system.
@@ -30,7 +32,7 @@ that are to be written.`,
 var (
 	dryrun      bool
 	verbose     bool
-	ErrNoConfig = errors.New("Missing config file.")
+	ErrNoConfig = config.ErrNoConfig
 )
 
 func init() {
@@ -41,11 +43,11 @@ func init() {
 
 func runAdd(cmd *base.Command, args []string) error {
 	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
-	cfg := config.Read()
-	if cfg == nil {
-		fmt.Fprintf(os.Stderr, "missing config: run \"mailconf setup\" first.")
-		return ErrNoConfig
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	t := myterm.New()
 	profile, err := t.ReadLine("Profile name: ")
@@ -6,6 +6,10 @@ import (
 	"github.com/gianz74/mailconf"
 	"github.com/gianz74/mailconf/internal/cred"
 	"github.com/gianz74/mailconf/internal/cred/memcred"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/exec/memexec"
+	"github.com/gianz74/mailconf/internal/imap"
+	"github.com/gianz74/mailconf/internal/imap/memimap"
 	"github.com/gianz74/mailconf/internal/myterm"
 	"github.com/gianz74/mailconf/internal/myterm/memterm"
 	"github.com/gianz74/mailconf/internal/os"
@@ -17,6 +21,8 @@ import (
 var (
 	mockTerm      = memterm.New()
 	mockCredStore = memcred.New()
+	mockExec      = memexec.New()
+	mockImap      = memimap.New()
 )
 
 type creds struct {
@@ -29,7 +35,9 @@ func setup() error {
 	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
 	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
 	cred.SetStore(mockCredStore)
+	imap.Set(mockImap)
 	mockservice.SetupMockServices()
+	exec.Set(mockExec)
 	return nil
 }
 
@@ -1,4 +1,3 @@
-(diminish 'overwrite-mode)
 (if (not (eq system-type 'windows-nt))
     (progn
       (if (eq system-type 'darwin)
@@ -9,6 +8,19 @@
 	  )
       (require 'mu4e)
       (require 'smtpmail)
+      (require 'subr-x)
+
+      (defun mailconf-read-signature (file)
+	"Return the content of FILE, or nil if it cannot be read."
+	(when (file-readable-p file)
+	  (with-temp-buffer
+	    (insert-file-contents file)
+	    (string-trim-right (buffer-string)))))
+
+      (defun mailconf-addresses-regexp (addresses)
+	"Return a regexp matching exactly one of ADDRESSES."
+	(concat "\\`" (regexp-opt addresses) "\\'"))
+
       (setq mu4e-contexts
 	    `( ,(make-mu4e-context
 		 :name "OldProfile"
@@ -21,16 +33,16 @@
 						smtpmail-default-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-service 456
-						smtpmail-debug-info t)
-					  (if (eq system-type 'darwin)
-					      (setq browse-url-chrome-arguments '("--profile-directory=Profile 1"))
-					      )))
+						smtpmail-debug-info t)))
 		 :leave-func (lambda () (mu4e-message "Leaving OldProfile context"))
-		 ;; we match based on the contact-fields of the message
+		 ;; we match based on the maildir and on the contact-fields of the message
 		 :match-func (lambda (msg)
 			       (when msg
-				 (string-match-p "^/OldProfile" (mu4e-message-field msg :maildir))))
-		 :vars '( ( user-mail-address      . "jdoe_old@gmail.com"  )
+				 (or (string-match-p "^/OldProfile/" (mu4e-message-field msg :maildir))
+				     (mu4e-message-contact-field-matches
+				      msg '(:to :cc :bcc)
+				      (mailconf-addresses-regexp '("jdoe_old@gmail.com"))))))
+		 :vars `( ( user-mail-address      . "jdoe_old@gmail.com"  )
 			 ( user-full-name         . "John Doe the elder" )
 			 ( mu4e-compose-signature . "John Doe the elder")
 			 ( mu4e-drafts-folder     . "/OldProfile/drafts")
@@ -59,16 +71,16 @@
 						smtpmail-default-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-service 456
-						smtpmail-debug-info t)
-					  (if (eq system-type 'darwin)
-					      (setq browse-url-chrome-arguments '("--profile-directory=Profile 1"))
-					      )))
+						smtpmail-debug-info t)))
 		 :leave-func (lambda () (mu4e-message "Leaving Test context"))
-		 ;; we match based on the contact-fields of the message
+		 ;; we match based on the maildir and on the contact-fields of the message
 		 :match-func (lambda (msg)
 			       (when msg
-				 (string-match-p "^/Test" (mu4e-message-field msg :maildir))))
-		 :vars '( ( user-mail-address      . "jdoe@gmail.com"  )
+				 (or (string-match-p "^/Test/" (mu4e-message-field msg :maildir))
+				     (mu4e-message-contact-field-matches
+				      msg '(:to :cc :bcc)
+				      (mailconf-addresses-regexp '("jdoe@gmail.com"))))))
+		 :vars `( ( user-mail-address      . "jdoe@gmail.com"  )
 			 ( user-full-name         . "John Doe" )
 			 ( mu4e-compose-signature . "John Doe")
 			 ( mu4e-drafts-folder     . "/Test/drafts")
@@ -102,13 +114,9 @@
 	    mu4e-update-interval 300
 	    mu4e-headers-leave-behavior 'apply
 	    mu4e-view-show-addresses t
-	    mu4e-compose-in-new-frame t
+	    mu4e-compose-in-new-frame nil
 	    mu4e-user-agent-string nil
 	    message-kill-buffer-on-exit t)
-      (setq browse-url-browser-function 'browse-url-chrome)
-      (if (eq system-type 'darwin)
-	  (setq browse-url-chrome-program "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome")
-	  )
       (add-to-list 'mu4e-view-actions
 		   '("ViewInBrowser" . mu4e-action-view-in-browser) t)
       ;; enable inline images
@@ -129,8 +137,9 @@
       (advice-add #'shr-colorize-region :around (defun shr-no-colourise-region (&rest ignore)))
 
 
-      (require 'org-mu4e)
-      (global-set-key "\C-cm" 'mu4e)
+      ;; org links to messages.
+      (unless (require 'mu4e-org nil t)
+	(require 'org-mu4e nil t))
       )
     (defvar mu4e-reindex-request-file "/tmp/mail/mu_reindex_now"
       "Location of the reindex request, signaled by existance")
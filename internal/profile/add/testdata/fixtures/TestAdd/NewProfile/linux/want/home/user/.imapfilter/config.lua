@@ -16,9 +16,6 @@ jdoe_old_gmail_com = IMAP {
 	password = get_pass("imap.gmail.com", "jdoe_old@gmail.com", "997"),
 }
 
-results = jdoe_old_gmail_com["email-archive"]:is_unseen()
-results:mark_seen()
-
 jdoe_gmail_com = IMAP {
 	server = "imap.gmail.com",
 	port = 997,
@@ -27,5 +24,8 @@ jdoe_gmail_com = IMAP {
 	password = get_pass("imap.gmail.com", "jdoe@gmail.com", "997"),
 }
 
+results = jdoe_old_gmail_com["email-archive"]:is_unseen()
+results:mark_seen()
+
 results = jdoe_gmail_com["email-archive"]:is_unseen()
 results:mark_seen()
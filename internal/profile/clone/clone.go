@@ -0,0 +1,62 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdClone = &base.Command{
+	UsageLine: "clone [-dry-run -v] src dst",
+	Short:     "clone creates a profile from an existing one",
+	Long: `
+
+Clone creates the profile dst with the imap and smtp servers, ports
+and folders of src, as a starting point for another account on the
+same provider. The user is asked for the name, address, user names
+and passwords of the new account.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdClone.Run = runClone
+	CmdClone.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdClone.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runClone(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf profile %s\n", CmdClone.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.CloneProfile(args[0], args[1], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot clone profile: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,67 @@
+package disable
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdDisable = &base.Command{
+	UsageLine: "disable [-read-only -dry-run -v] name",
+	Short:     "disable stops syncing a profile without removing it",
+	Long: `
+
+Disable pauses a profile: its imapnotify service is stopped and
+disabled, and it is left out of the mbsync groups, of syncmail.sh and
+of the imapfilter config. Its settings, credentials, maildir and mu4e
+context are kept, and "mailconf profile enable" resumes syncing.
+
+The -read-only option also prevents sending mail from the mu4e context
+of the profile.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	readOnly    bool
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdDisable.Run = runDisable
+	CmdDisable.Flag.BoolVar(&readOnly, "read-only", false, "Do not send mail from the mu4e context.")
+	CmdDisable.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdDisable.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runDisable(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf profile %s\n", CmdDisable.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.DisableProfile(args[0], readOnly, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot disable profile: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,64 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdEdit = &base.Command{
+	UsageLine: "edit [-dry-run -v] name",
+	Short:     "edit chooses the mailboxes a profile syncs besides its folders",
+	Long: `
+
+Edit lists the mailboxes on the IMAP server of a profile, except those
+synced by its folders, marking the ones the profile already includes.
+The user picks the mailboxes to sync by number; they are synced by the
+patterns channel of the profile and get a mu4e maildir shortcut.
+Includes with wildcards, such as "Projects/*", and excludes are kept
+as they are: edit them in the config file.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdEdit.Run = runEdit
+	CmdEdit.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdEdit.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runEdit(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf profile %s\n", CmdEdit.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.EditProfile(args[0], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot edit profile: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
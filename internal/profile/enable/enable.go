@@ -0,0 +1,62 @@
+package enable
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdEnable = &base.Command{
+	UsageLine: "enable [-dry-run -v] name",
+	Short:     "enable resumes syncing a disabled profile",
+	Long: `
+
+Enable resumes syncing a profile paused with "mailconf profile
+disable": the profile is added back to the mbsync groups, to
+syncmail.sh and to the imapfilter config, its imapnotify service is
+started and its mu4e context can send mail again.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdEnable.Run = runEnable
+	CmdEnable.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdEnable.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runEnable(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf profile %s\n", CmdEnable.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.EnableProfile(args[0], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot enable profile: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
@@ -8,7 +8,12 @@ import (
 
 	"github.com/gianz74/mailconf/internal/base"
 	"github.com/gianz74/mailconf/internal/profile/add"
+	"github.com/gianz74/mailconf/internal/profile/clone"
+	"github.com/gianz74/mailconf/internal/profile/disable"
+	"github.com/gianz74/mailconf/internal/profile/edit"
+	"github.com/gianz74/mailconf/internal/profile/enable"
 	"github.com/gianz74/mailconf/internal/profile/list"
+	"github.com/gianz74/mailconf/internal/profile/rename"
 )
 
 var CmdProfile = &base.Command{
@@ -23,6 +28,11 @@ func init() {
 	CmdProfile.Commands = []*base.Command{
 		list.CmdList,
 		add.CmdAdd,
+		rename.CmdRename,
+		clone.CmdClone,
+		disable.CmdDisable,
+		enable.CmdEnable,
+		edit.CmdEdit,
 	}
 	CmdProfile.Long = tmpl(usageTemplate, CmdProfile.Commands)
 }
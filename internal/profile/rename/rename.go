@@ -0,0 +1,64 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gianz74/mailconf"
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var CmdRename = &base.Command{
+	UsageLine: "rename [-dry-run -v] old new",
+	Short:     "rename changes the name of a profile",
+	Long: `
+
+Rename changes the name of a profile. As the name is part of the
+maildir path, of the mbsync channels, of the imapnotify service and of
+the mu4e context, rename stops the imapnotify service of the profile,
+moves <maildir root>/<old>, unless the profile sets its own maildir,
+and the imapnotify config to the new name, and generates the
+configuration again, starting the service under the new name.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the actions that are about
+to be taken.`,
+}
+
+var (
+	dryrun      bool
+	verbose     bool
+	ErrNoConfig = config.ErrNoConfig
+	ErrUsage    = errors.New("Wrong arguments.")
+)
+
+func init() {
+	CmdRename.Run = runRename
+	CmdRename.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdRename.Flag.BoolVar(&verbose, "v", false, "Print actions about to be taken.")
+}
+
+func runRename(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: mailconf profile %s\n", CmdRename.UsageLine)
+		return ErrUsage
+	}
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = mailconf.RenameProfile(args[0], args[1], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot rename profile: %v\n", err)
+		return err
+	}
+	return cfg.Save()
+}
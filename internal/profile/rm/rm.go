@@ -1,7 +1,6 @@
 package rm
 
 import (
-	"errors"
 	"fmt"
 	"os"
 
@@ -30,7 +29,7 @@ to be taken.`,
 var (
 	dryrun      bool
 	verbose     bool
-	ErrNoConfig = errors.New("Missing config file.")
+	ErrNoConfig = config.ErrNoConfig
 )
 
 func init() {
@@ -41,11 +40,11 @@ func init() {
 
 func runRm(cmd *base.Command, args []string) error {
 	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
-	cfg := config.Read()
-	if cfg == nil {
-		fmt.Fprintf(os.Stderr, "missing config: run \"mailconf setup\" first.")
-		return ErrNoConfig
+	cfg, unlock, err := config.LockAndRead()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	t := myterm.New()
 	profile, err := t.ReadLine("Profile name: ")
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"reflect"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/templates"
+)
+
+func MbsyncProfileCtor(cfg *config.Config, profile *config.Profile) Service {
+	switch os.System {
+	case "linux":
+		return mbsyncProfileLinux{
+			cfg:     cfg,
+			profile: profile,
+		}
+	case "darwin":
+		return mbsyncProfileDarwin{
+			cfg:     cfg,
+			profile: profile,
+		}
+	default:
+		return nil
+	}
+}
+
+// mbsyncProfileLinux is the timer mbsync@<profile>.timer, which starts
+// the template service mbsync@.service for the profile, so that the
+// profile is synced, filtered and reported on its own.
+type mbsyncProfileLinux struct {
+	cfg     *config.Config
+	profile *config.Profile
+}
+
+func (m mbsyncProfileLinux) timer() string {
+	return fmt.Sprintf("mbsync@%s.timer", instance(m.profile.Name))
+}
+
+// installed reports whether the timer of the profile exists.
+func (m mbsyncProfileLinux) installed() bool {
+	cfgdir, err := os.UserConfigDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.ReadFile(path.Join(cfgdir, "systemd/user", m.timer()))
+	return err == nil
+}
+
+func (m mbsyncProfileLinux) systemctl(verb, msg string) {
+	if options.Dryrun() {
+		fmt.Fprintf(os.Stdout, "%s mbsync service of %s\n", msg, m.profile.Name)
+		return
+	}
+	if options.Verbose() {
+		fmt.Fprintf(os.Stdout, "%s mbsync service of %s\n", msg, m.profile.Name)
+	}
+	// wait for systemctl, as schedule stops, enables and starts the
+	// timer one after the other.
+	exec.Command("systemctl", "--user", verb, m.timer()).Run()
+}
+
+func (m mbsyncProfileLinux) Start() {
+	if !options.Dryrun() {
+		exec.Command("systemctl", "--user", "daemon-reload").Run()
+	}
+	m.systemctl("start", "starting")
+}
+
+// Stop and Disable do nothing for a profile without its own timer, so
+// that they can be called for every profile.
+func (m mbsyncProfileLinux) Stop() {
+	if m.installed() {
+		m.systemctl("stop", "stopping")
+	}
+}
+
+func (m mbsyncProfileLinux) Enable() {
+	m.systemctl("enable", "enabling")
+}
+
+func (m mbsyncProfileLinux) Disable() {
+	if m.installed() {
+		m.systemctl("disable", "disabling")
+	}
+}
+
+// Remove removes the timer of the profile, and the template service
+// once no profile has its own timer.
+func (m mbsyncProfileLinux) Remove() error {
+	cfgdir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	if m.installed() {
+		remove(path.Join(cfgdir, "systemd/user", m.timer()))
+	}
+	err = removeimapfilterprofile(m.profile)
+	if err != nil {
+		return err
+	}
+	for _, p := range m.cfg.Profiles {
+		if p.Name != m.profile.Name && m.cfg.OwnSchedule(p) {
+			return nil
+		}
+	}
+	svc := path.Join(cfgdir, "systemd/user/mbsync@.service")
+	if _, err := os.ReadFile(svc); err == nil {
+		remove(svc)
+	}
+	return nil
+}
+
+func (m mbsyncProfileLinux) GenConf(force bool) error {
+	ctx, err := templates.NewContext(m.cfg, m.profile)
+	if err != nil {
+		return err
+	}
+	svc, err := templates.Execute("linux/mbsync@.service.tmpl", ctx)
+	if err != nil {
+		return err
+	}
+	timer, err := templates.Execute("linux/mbsync@.timer.tmpl", ctx)
+	if err != nil {
+		return err
+	}
+	cfgdir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+
+	svcfile := path.Join(cfgdir, "systemd/user/mbsync@.service")
+	timerfile := path.Join(cfgdir, "systemd/user", m.timer())
+	tmp, err := os.ReadFile(svcfile)
+	if err == nil && !(reflect.DeepEqual(tmp, svc) || force) {
+		return ErrExists
+	}
+	tmp, err = os.ReadFile(timerfile)
+	if err == nil && !(reflect.DeepEqual(tmp, timer) || force) {
+		return ErrExists
+	}
+
+	err = generateimapfilterprofile(m.cfg, m.profile, force)
+	if err != nil {
+		return err
+	}
+
+	io.Write(svcfile, svc, 0644)
+	io.Write(timerfile, timer, 0644)
+
+	return nil
+}
+
+func (m mbsyncProfileLinux) Status() Status {
+	return unitStatus(m.timer())
+}
+
+// mbsyncProfileDarwin is the launchd job local.mbsync.<profile>.
+type mbsyncProfileDarwin struct {
+	cfg     *config.Config
+	profile *config.Profile
+}
+
+// installed reports whether the launchd job of the profile exists.
+func (m mbsyncProfileDarwin) installed() bool {
+	plist, err := m.plist()
+	if err != nil {
+		return false
+	}
+	_, err = os.ReadFile(plist)
+	return err == nil
+}
+
+// launchctl loads or unloads the job of the profile, waiting for
+// launchctl to return.
+func (m mbsyncProfileDarwin) launchctl(msg string, args ...string) {
+	if options.Dryrun() {
+		fmt.Fprintf(os.Stdout, "%s mbsync service of %s\n", msg, m.profile.Name)
+		return
+	}
+	if options.Verbose() {
+		fmt.Fprintf(os.Stdout, "%s mbsync service of %s\n", msg, m.profile.Name)
+	}
+	plist, err := m.plist()
+	if err != nil {
+		return
+	}
+	exec.Command("launchctl", append(args, plist)...).Run()
+}
+
+func (m mbsyncProfileDarwin) Start() {
+	m.launchctl("starting", "load")
+}
+
+// Stop and Disable do nothing for a profile without its own job, so
+// that they can be called for every profile.
+func (m mbsyncProfileDarwin) Stop() {
+	if m.installed() {
+		m.launchctl("stopping", "unload")
+	}
+}
+
+func (m mbsyncProfileDarwin) Enable() {
+	m.launchctl("enabling", "load", "-w")
+}
+
+func (m mbsyncProfileDarwin) Disable() {
+	if m.installed() {
+		m.launchctl("disabling", "unload", "-w")
+	}
+}
+
+func (m mbsyncProfileDarwin) plist() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homedir, "Library/LaunchAgents/local.mbsync."+m.profile.Name+".plist"), nil
+}
+
+func (m mbsyncProfileDarwin) Remove() error {
+	plist, err := m.plist()
+	if err != nil {
+		return err
+	}
+	if _, err := os.ReadFile(plist); err == nil {
+		remove(plist)
+	}
+	return removeimapfilterprofile(m.profile)
+}
+
+func (m mbsyncProfileDarwin) GenConf(force bool) error {
+	ctx, err := templates.NewContext(m.cfg, m.profile)
+	if err != nil {
+		return err
+	}
+	svc, err := templates.Execute("darwin/local.mbsync.profile.plist.tmpl", ctx)
+	if err != nil {
+		return err
+	}
+	plist, err := m.plist()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.ReadFile(plist)
+	if err == nil && !(reflect.DeepEqual(tmp, svc) || force) {
+		return ErrExists
+	}
+
+	err = generateimapfilterprofile(m.cfg, m.profile, force)
+	if err != nil {
+		return err
+	}
+
+	return io.Write(plist, svc, 0644)
+}
+
+func (m mbsyncProfileDarwin) Status() Status {
+	return EnabledRunning
+}
@@ -5,34 +5,82 @@ import (
 	"github.com/gianz74/mailconf/internal/service"
 )
 
+// state holds the status of the mock services by name; services not
+// in it are enabled and running.
+var state = map[string]service.Status{}
+
 func SetupMockServices() {
+	state = map[string]service.Status{}
 	service.SetMbsync(NewMockMbsync)
 	service.SetImapnotify(NewMockImapnotify)
+	service.SetMbsyncProfile(NewMockMbsyncProfile)
 }
 
 func RestoreServices() {
 	service.SetMbsync(service.MbsyncCtor)
 	service.SetImapnotify(service.ImapnotifyCtor)
+	service.SetMbsyncProfile(service.MbsyncProfileCtor)
 }
 
 func NewMockMbsync(cfg *config.Config) service.Service {
 	return &MockService{
 		Service: service.MbsyncCtor(cfg),
+		name:    "mbsync",
 	}
 }
 
 func NewMockImapnotify(cfg *config.Config, profile *config.Profile) service.Service {
 	return &MockService{
 		Service: service.ImapnotifyCtor(cfg, profile),
+		name:    "imapnotify " + profile.Name,
+	}
+}
+
+func NewMockMbsyncProfile(cfg *config.Config, profile *config.Profile) service.Service {
+	return &MockService{
+		Service: service.MbsyncProfileCtor(cfg, profile),
+		name:    "mbsync " + profile.Name,
 	}
 }
 
 type MockService struct {
 	service.Service
+	name string
 }
 
-func (MockService) Start()        {}
-func (MockService) Stop()         {}
-func (MockService) Enable()       {}
-func (MockService) Disable()      {}
+func (m MockService) Start()      { m.set(true, m.enabled()) }
+func (m MockService) Stop()       { m.set(false, m.enabled()) }
+func (m MockService) Enable()     { m.set(m.running(), true) }
+func (m MockService) Disable()    { m.set(m.running(), false) }
 func (MockService) Remove() error { return nil }
+
+func (m MockService) Status() service.Status {
+	s, ok := state[m.name]
+	if !ok {
+		return service.EnabledRunning
+	}
+	return s
+}
+
+func (m MockService) running() bool {
+	s := m.Status()
+	return s == service.EnabledRunning || s == service.DisabledRunning
+}
+
+func (m MockService) enabled() bool {
+	s := m.Status()
+	return s == service.EnabledRunning || s == service.EnabledStopped
+}
+
+func (m MockService) set(running, enabled bool) {
+	switch {
+	case running && enabled:
+		state[m.name] = service.EnabledRunning
+	case running:
+		state[m.name] = service.DisabledRunning
+	case enabled:
+		state[m.name] = service.EnabledStopped
+	default:
+		state[m.name] = service.DisabledStopped
+	}
+}
@@ -2,34 +2,47 @@ package service
 
 import (
 	"bufio"
-	"bytes"
-	_ "embed"
+	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os/exec"
 	"path"
 	"reflect"
 	"regexp"
 	"strings"
-	"text/template"
 
 	"github.com/gianz74/mailconf/internal/config"
 	"github.com/gianz74/mailconf/internal/io"
 	"github.com/gianz74/mailconf/internal/options"
 	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/templates"
 )
 
 type (
-	CtorMbsync     func(*config.Config) Service
-	CtorImapnotify func(*config.Config, *config.Profile) Service
+	CtorMbsync        func(*config.Config) Service
+	CtorImapnotify    func(*config.Config, *config.Profile) Service
+	CtorMbsyncProfile func(*config.Config, *config.Profile) Service
 )
 
 var (
-	ErrExists                     = errors.New("config already exists")
-	_newImapnotify CtorImapnotify = ImapnotifyCtor
-	_newMbsync     CtorMbsync     = MbsyncCtor
+	ErrExists                           = errors.New("config already exists")
+	_newImapnotify    CtorImapnotify    = ImapnotifyCtor
+	_newMbsync        CtorMbsync        = MbsyncCtor
+	_newMbsyncProfile CtorMbsyncProfile = MbsyncProfileCtor
+
+	//go:embed templates
+	embedded embed.FS
 )
 
+func init() {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		panic(err)
+	}
+	templates.Register(sub)
+}
+
 type Status int
 
 const (
@@ -61,10 +74,21 @@ func SetImapnotify(f CtorImapnotify) {
 	_newImapnotify = f
 }
 
+func SetMbsyncProfile(f CtorMbsyncProfile) {
+	_newMbsyncProfile = f
+}
+
 func NewMbsync(cfg *config.Config) Service {
 	return _newMbsync(cfg)
 }
 
+// NewMbsyncProfile returns the service syncing profile on its own
+// schedule, for profiles whose sync interval differs from the shared
+// one.
+func NewMbsyncProfile(cfg *config.Config, profile *config.Profile) Service {
+	return _newMbsyncProfile(cfg, profile)
+}
+
 func NewImapnotify(cfg *config.Config, profile *config.Profile) Service {
 	return _newImapnotify(cfg, profile)
 }
@@ -125,6 +149,8 @@ func (m mbsyncLinux) Start() {
 	if options.Verbose() {
 		fmt.Fprintf(os.Stdout, "starting mbsync service\n")
 	}
+	// the timer may have been generated again with a new interval.
+	exec.Command("systemctl", "--user", "daemon-reload").Run()
 	cmd := exec.Command("systemctl", "--user", "start", "mbsync.timer")
 	cmd.Start()
 }
@@ -194,23 +220,18 @@ func remove(file string) {
 	}
 }
 
-//go:embed templates/linux/mbsync.service.tmpl
-var mbsyncsvclinux string
-
-//go:embed templates/linux/mbsync.timer.tmpl
-var mbsynctimerlinux []byte
-
 func (m mbsyncLinux) GenConf(force bool) error {
-	tmpl, err := template.New("mbsync.service").Parse(mbsyncsvclinux)
+	ctx, err := templates.NewContext(m.cfg, nil)
 	if err != nil {
 		return err
 	}
-	var mbsyncsvc = &bytes.Buffer{}
-
-	err = tmpl.Execute(mbsyncsvc, m.cfg)
+	mbsyncsvc, err := templates.Execute("linux/mbsync.service.tmpl", ctx)
+	if err != nil {
+		return err
+	}
+	mbsynctimerlinux, err := templates.Execute("linux/mbsync.timer.tmpl", ctx)
 	if err != nil {
 		return err
-
 	}
 	cfgdir, err := os.UserConfigDir()
 	if err != nil {
@@ -235,23 +256,55 @@ func (m mbsyncLinux) GenConf(force bool) error {
 		return err
 	}
 
+	err = GenerateSyncmail(m.cfg, force)
+	if err != nil {
+		return err
+	}
+
 	tmp, err := os.ReadFile(path.Join(cfgdir, "systemd/user/mbsync.timer"))
 	if err == nil && !(reflect.DeepEqual(tmp, mbsynctimerlinux) || force) {
 		return ErrExists
 	}
 	tmp, err = os.ReadFile(path.Join(cfgdir, "systemd/user/mbsync.service"))
-	if err == nil && !(reflect.DeepEqual(tmp, mbsyncsvc.Bytes()) || force) {
+	if err == nil && !(reflect.DeepEqual(tmp, mbsyncsvc) || force) {
 		return ErrExists
 	}
 
 	io.Write(path.Join(cfgdir, "systemd/user/mbsync.timer"), mbsynctimerlinux, 0644)
-	io.Write(path.Join(cfgdir, "systemd/user/mbsync.service"), mbsyncsvc.Bytes(), 0644)
+	io.Write(path.Join(cfgdir, "systemd/user/mbsync.service"), mbsyncsvc, 0644)
 
 	return nil
 }
 
 func (m mbsyncLinux) Status() Status {
-	cmd := exec.Command("systemctl", "--user", "status", "mbsync.timer")
+	return unitStatus("mbsync.timer")
+}
+
+// instance escapes name for the instance of a template unit, as
+// systemd-escape does, so that the %I specifier of the unit gives name
+// back: '-' stands for '/' in instances and is escaped itself.
+func instance(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == ':', c == '_', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// unitStatus reads the status of a systemd user unit from
+// systemctl status.
+func unitStatus(unit string) Status {
+	cmd := exec.Command("systemctl", "--user", "status", unit)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return Unknown
@@ -336,9 +389,6 @@ func (m mbsyncDarwin) Remove() error {
 	return nil
 }
 
-//go:embed templates/darwin/local.mbsync.plist.tmpl
-var mbsyncsvcdarwin string
-
 func (m mbsyncDarwin) GenConf(force bool) error {
 
 	err := generatembsyncrc(m.cfg, force)
@@ -351,16 +401,18 @@ func (m mbsyncDarwin) GenConf(force bool) error {
 		return err
 	}
 
-	tmpl, err := template.New("local.mbsync.plist").Parse(mbsyncsvcdarwin)
+	err = GenerateSyncmail(m.cfg, force)
 	if err != nil {
 		return err
 	}
-	var mbsyncsvc = &bytes.Buffer{}
 
-	err = tmpl.Execute(mbsyncsvc, m.cfg)
+	ctx, err := templates.NewContext(m.cfg, nil)
+	if err != nil {
+		return err
+	}
+	mbsyncsvc, err := templates.Execute("darwin/local.mbsync.plist.tmpl", ctx)
 	if err != nil {
 		return err
-
 	}
 	homedir, err := os.UserHomeDir()
 	if err != nil {
@@ -368,11 +420,11 @@ func (m mbsyncDarwin) GenConf(force bool) error {
 	}
 
 	tmp, err := os.ReadFile(path.Join(homedir, "Library/LaunchAgents/local.mbsync.plist"))
-	if err == nil && !(reflect.DeepEqual(tmp, mbsyncsvc.Bytes()) || force) {
+	if err == nil && !(reflect.DeepEqual(tmp, mbsyncsvc) || force) {
 		return ErrExists
 	}
 
-	err = io.Write(path.Join(homedir, "Library/LaunchAgents/local.mbsync.plist"), mbsyncsvc.Bytes(), 0644)
+	err = io.Write(path.Join(homedir, "Library/LaunchAgents/local.mbsync.plist"), mbsyncsvc, 0644)
 	if err != nil {
 		return err
 	}
@@ -403,23 +455,27 @@ type imapnotifyLinux struct {
 	profile *config.Profile
 }
 
+func (m imapnotifyLinux) unit() string {
+	return fmt.Sprintf("imapnotify@%s.service", instance(m.profile.Name))
+}
+
 func (m imapnotifyLinux) Start() {
-	cmd := exec.Command("systemctl", "--user", "start", fmt.Sprintf("imapnotify@%s.service", m.profile.Name))
+	cmd := exec.Command("systemctl", "--user", "start", m.unit())
 	cmd.Start()
 }
 
 func (m imapnotifyLinux) Stop() {
-	cmd := exec.Command("systemctl", "--user", "stop", fmt.Sprintf("imapnotify@%s.service", m.profile.Name))
+	cmd := exec.Command("systemctl", "--user", "stop", m.unit())
 	cmd.Start()
 }
 
 func (m imapnotifyLinux) Enable() {
-	cmd := exec.Command("systemctl", "--user", "enable", fmt.Sprintf("imapnotify@%s.service", m.profile.Name))
+	cmd := exec.Command("systemctl", "--user", "enable", m.unit())
 	cmd.Start()
 }
 
 func (m imapnotifyLinux) Disable() {
-	cmd := exec.Command("systemctl", "--user", "disable", fmt.Sprintf("imapnotify@%s.service", m.profile.Name))
+	cmd := exec.Command("systemctl", "--user", "disable", m.unit())
 	cmd.Start()
 }
 
@@ -437,24 +493,12 @@ func (m imapnotifyLinux) Remove() error {
 	return nil
 }
 
-//go:embed templates/imapnotify/notify.conf.tmpl
-var imapnotify string
-
-func generateimapnotify(profile *config.Profile, force bool) error {
-	tmpl, err := template.New("imapnotify").Parse(imapnotify)
+func generateimapnotify(cfg *config.Config, profile *config.Profile, force bool) error {
+	ctx, err := templates.NewContext(cfg, profile)
 	if err != nil {
 		return err
 	}
-
-	param := struct {
-		OS      string
-		Profile *config.Profile
-	}{
-		OS:      os.System,
-		Profile: profile,
-	}
-	imapnotify := &bytes.Buffer{}
-	err = tmpl.Execute(imapnotify, param)
+	imapnotify, err := templates.Execute("imapnotify/notify.conf.tmpl", ctx)
 	if err != nil {
 		return err
 	}
@@ -464,32 +508,26 @@ func generateimapnotify(profile *config.Profile, force bool) error {
 	}
 
 	tmp, err := os.ReadFile(path.Join(cfgdir, "imapnotify/"+profile.Name+"/notify.conf"))
-	same := reflect.DeepEqual(tmp, imapnotify.Bytes())
+	same := reflect.DeepEqual(tmp, imapnotify)
 
 	if err == nil && !(same || force) {
 		return ErrExists
 	}
 	if !same || force {
-		io.Write(path.Join(cfgdir, "imapnotify/"+profile.Name+"/notify.conf"), imapnotify.Bytes(), 0644)
+		io.Write(path.Join(cfgdir, "imapnotify/"+profile.Name+"/notify.conf"), imapnotify, 0644)
 	}
 
 	return nil
 }
 
-//go:embed templates/linux/imapnotify.service.tmpl
-var imapnotifysvclinux string
-
 func genimapnotifysvclinux(cfg *config.Config, profile *config.Profile, force bool) error {
-	tmpl, err := template.New("imapnotify.service").Parse(imapnotifysvclinux)
+	ctx, err := templates.NewContext(cfg, profile)
 	if err != nil {
 		return err
 	}
-	var imapnotifysvc = &bytes.Buffer{}
-
-	err = tmpl.Execute(imapnotifysvc, cfg)
+	imapnotifysvc, err := templates.Execute("linux/imapnotify.service.tmpl", ctx)
 	if err != nil {
 		return err
-
 	}
 	cfgdir, err := os.UserConfigDir()
 	if err != nil {
@@ -497,12 +535,12 @@ func genimapnotifysvclinux(cfg *config.Config, profile *config.Profile, force bo
 	}
 
 	tmp, err := os.ReadFile(path.Join(cfgdir, "systemd/user/imapnotify@.service"))
-	same := reflect.DeepEqual(tmp, imapnotifysvc.Bytes())
+	same := reflect.DeepEqual(tmp, imapnotifysvc)
 	if err == nil && !(same || force) {
 		return ErrExists
 	}
 	if !same || force {
-		io.Write(path.Join(cfgdir, "systemd/user/imapnotify@.service"), imapnotifysvc.Bytes(), 0644)
+		io.Write(path.Join(cfgdir, "systemd/user/imapnotify@.service"), imapnotifysvc, 0644)
 	}
 	return nil
 }
@@ -514,7 +552,7 @@ func (m imapnotifyLinux) GenConf(force bool) error {
 		return err
 	}
 
-	err = generateimapnotify(m.profile, force)
+	err = generateimapnotify(m.cfg, m.profile, force)
 	if err != nil {
 		return err
 	}
@@ -523,60 +561,7 @@ func (m imapnotifyLinux) GenConf(force bool) error {
 }
 
 func (m imapnotifyLinux) Status() Status {
-	cmd := exec.Command("systemctl", "--user", "status", fmt.Sprintf("imapnotify@%s.service", m.profile.Name))
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return Unknown
-	}
-	if err := cmd.Start(); err != nil {
-		return Unknown
-	}
-	s := bufio.NewScanner(stdout)
-	st := regexp.MustCompile(`\s*Active:\s*(?P<status>[^\s]*)\s.*`)
-	en := regexp.MustCompile(`\s*Loaded:[^;]+;\s+(?P<enabled>[^;]*);.*`)
-	result := make(map[string]string)
-	for s.Scan() {
-		line := s.Text()
-		match := st.FindStringSubmatch(line)
-		for i, name := range st.SubexpNames() {
-			if i != 0 && name != "" {
-				if i < len(match) {
-					result[name] = match[i]
-				}
-			}
-		}
-		match = en.FindStringSubmatch(line)
-		for i, name := range en.SubexpNames() {
-			if i != 0 && name != "" {
-				if i < len(match) {
-					result[name] = match[i]
-				}
-			}
-		}
-	}
-	enabled, ok := result["enabled"]
-	if !ok {
-		return NotFound
-	}
-	status, ok := result["status"]
-	if !ok {
-		return NotFound
-	}
-	switch status {
-	case "inactive":
-		if enabled == "enabled" {
-			return EnabledStopped
-		}
-		return DisabledStopped
-	case "active":
-		if enabled == "enabled" {
-			return EnabledRunning
-		}
-		return DisabledRunning
-	default:
-		fmt.Printf("status: %s\n", status)
-		return Unknown
-	}
+	return unitStatus(m.unit())
 }
 
 type imapnotifyDarwin struct {
@@ -620,7 +605,7 @@ func (m imapnotifyDarwin) GenConf(force bool) error {
 		return err
 	}
 
-	err = generateimapnotify(m.profile, force)
+	err = generateimapnotify(m.cfg, m.profile, force)
 	if err != nil {
 		return err
 	}
@@ -632,72 +617,38 @@ func (m imapnotifyDarwin) Status() Status {
 	return EnabledRunning
 }
 
-//go:embed templates/darwin/imapnotify.plist.tmpl
-var imapnotifysvcdarwin string
-
 func genimapnotifysvcdarwin(cfg *config.Config, profile *config.Profile, force bool) error {
-	tmpl, err := template.New("imapnotify.service").Parse(imapnotifysvcdarwin)
+	ctx, err := templates.NewContext(cfg, profile)
 	if err != nil {
 		return err
 	}
-	var imapnotifysvc = &bytes.Buffer{}
-
-	cfgdir, err := os.UserConfigDir()
+	imapnotifysvc, err := templates.Execute("darwin/imapnotify.plist.tmpl", ctx)
 	if err != nil {
 		return err
 	}
-
-	param := struct {
-		Cfg     *config.Config
-		Profile *config.Profile
-		CfgDir  string
-	}{
-		Cfg:     cfg,
-		Profile: profile,
-		CfgDir:  cfgdir,
-	}
-
-	err = tmpl.Execute(imapnotifysvc, param)
-	if err != nil {
-		return err
-
-	}
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
 
 	tmp, err := os.ReadFile(path.Join(homedir, "Library/LaunchAgents/local.imapnotify."+profile.Name+".plist"))
-	if err == nil && !(reflect.DeepEqual(tmp, imapnotifysvc.Bytes()) || force) {
+	if err == nil && !(reflect.DeepEqual(tmp, imapnotifysvc) || force) {
 		return ErrExists
 	}
 
-	io.Write(path.Join(homedir, "Library/LaunchAgents/local.imapnotify."+profile.Name+".plist"), imapnotifysvc.Bytes(), 0644)
+	io.Write(path.Join(homedir, "Library/LaunchAgents/local.imapnotify."+profile.Name+".plist"), imapnotifysvc, 0644)
 
 	return nil
 }
 
-//go:embed templates/mbsyncrc.tpl
-var mbsyncrc string
-
 func generatembsyncrc(cfg *config.Config, force bool) error {
-	tmpl, err := template.New("mbsyncrc").Parse(mbsyncrc)
+	ctx, err := templates.NewContext(cfg, nil)
 	if err != nil {
 		return err
 	}
-	var mbsyncrc = &bytes.Buffer{}
-	param := struct {
-		OS       string
-		Profiles []*config.Profile
-	}{
-		OS:       os.System,
-		Profiles: cfg.Profiles,
-	}
-
-	err = tmpl.Execute(mbsyncrc, param)
+	mbsyncrc, err := templates.Execute("mbsyncrc.tpl", ctx)
 	if err != nil {
 		return err
-
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -705,48 +656,45 @@ func generatembsyncrc(cfg *config.Config, force bool) error {
 	}
 
 	tmp, err := os.ReadFile(path.Join(home, ".mbsyncrc"))
-	if err == nil && !(reflect.DeepEqual(tmp, mbsyncrc.Bytes()) || force) {
+	if err == nil && !(reflect.DeepEqual(tmp, mbsyncrc) || force) {
 		return ErrExists
 	}
 
-	io.Write(path.Join(home, ".mbsyncrc"), mbsyncrc.Bytes(), 0644)
+	io.Write(path.Join(home, ".mbsyncrc"), mbsyncrc, 0644)
 
 	return nil
 }
 
-//go:embed templates/imapfilter/config.lua.tmpl
-var configLua string
-
-//go:embed templates/imapfilter/certificates
-var certificates []byte
-
-func normalize(input string) string {
-	fields := strings.FieldsFunc(input, func(r rune) bool {
-		return r == '.' || r == '@'
-	})
-	return strings.Join(fields, "_")
-}
-
-func generateimapfilter(cfg *config.Config, force bool) error {
-	funcMap := template.FuncMap{
-		"normalize": normalize,
+// GenerateSyncmail writes syncmail.sh, the script run by the mbsync
+// services, which syncs the profiles and runs imapfilter.
+func GenerateSyncmail(cfg *config.Config, force bool) error {
+	ctx, err := templates.NewContext(cfg, nil)
+	if err != nil {
+		return err
 	}
-
-	tmpl, err := template.New("configlua").Funcs(funcMap).Parse(configLua)
+	syncmail, err := templates.Execute("syncmail.sh.tmpl", ctx)
 	if err != nil {
 		return err
 	}
 
-	var configLua = &bytes.Buffer{}
-	param := struct {
-		OS       string
-		Profiles []*config.Profile
-	}{
-		OS:       os.System,
-		Profiles: cfg.Profiles,
+	tmp, err := os.ReadFile(path.Join(cfg.BinDir, "syncmail.sh"))
+	if err == nil && !(reflect.DeepEqual(tmp, syncmail) || force) {
+		return ErrExists
 	}
 
-	err = tmpl.Execute(configLua, param)
+	io.Write(path.Join(cfg.BinDir, "syncmail.sh"), syncmail, 0750)
+
+	return nil
+}
+
+// generateimapfilterprofile writes the imapfilter config of profile
+// alone, which the mbsync service of the profile runs.
+func generateimapfilterprofile(cfg *config.Config, profile *config.Profile, force bool) error {
+	ctx, err := templates.NewContext(cfg, profile)
+	if err != nil {
+		return err
+	}
+	configLua, err := templates.Execute("imapfilter/config.lua.tmpl", ctx)
 	if err != nil {
 		return err
 	}
@@ -755,19 +703,48 @@ func generateimapfilter(cfg *config.Config, force bool) error {
 		return err
 	}
 
-	tmp, err := os.ReadFile(path.Join(home, ".imapfilter/certificates"))
-	if err == nil && !(reflect.DeepEqual(tmp, certificates) || force) {
+	file := path.Join(home, ".imapfilter/profiles", profile.Name+".lua")
+	tmp, err := os.ReadFile(file)
+	if err == nil && !(reflect.DeepEqual(tmp, configLua) || force) {
 		return ErrExists
 	}
 
-	io.Write(path.Join(home, ".imapfilter/certificates"), certificates, 0644)
+	return io.Write(file, configLua, 0644)
+}
+
+// removeimapfilterprofile removes the imapfilter config of profile.
+func removeimapfilterprofile(profile *config.Profile) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	file := path.Join(home, ".imapfilter/profiles", profile.Name+".lua")
+	if _, err := os.ReadFile(file); err == nil {
+		remove(file)
+	}
+	return nil
+}
+
+func generateimapfilter(cfg *config.Config, force bool) error {
+	ctx, err := templates.NewContext(cfg, nil)
+	if err != nil {
+		return err
+	}
+	configLua, err := templates.Execute("imapfilter/config.lua.tmpl", ctx)
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
 
-	tmp, err = os.ReadFile(path.Join(home, ".imapfilter/config.lua"))
-	if err == nil && !(reflect.DeepEqual(tmp, configLua.Bytes()) || force) {
+	tmp, err := os.ReadFile(path.Join(home, ".imapfilter/config.lua"))
+	if err == nil && !(reflect.DeepEqual(tmp, configLua) || force) {
 		return ErrExists
 	}
 
-	io.Write(path.Join(home, ".imapfilter/config.lua"), configLua.Bytes(), 0644)
+	io.Write(path.Join(home, ".imapfilter/config.lua"), configLua, 0644)
 
 	return nil
 }
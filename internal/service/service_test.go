@@ -121,10 +121,13 @@ func TestRemoveMbsync(t *testing.T) {
 			os.System = system
 			fs = testutil.NewFs(testutil.Name(t.Name()), testutil.SubName(tc.name), testutil.System(system))
 			os.Set(fs)
-			cfg := config.Read()
+			cfg, err := config.Read()
+			if err != nil {
+				t.Fatalf("%s: cannot read config: %v", tc.name, err)
+			}
 			svc := NewMbsync(cfg)
 			options.Set(options.OptVerbose(true))
-			err := svc.Remove()
+			err = svc.Remove()
 			if err != tc.err {
 				t.Fatalf("%s: got: %v, want: %v", tc.name, err, tc.err)
 			}
@@ -173,7 +176,10 @@ func TestGenerateimapnotify(t *testing.T) {
 			os.System = system
 			fs = testutil.NewFs(testutil.Name(t.Name()), testutil.SubName(tc.name), testutil.System(system))
 			os.Set(fs)
-			err := generateimapnotify(tc.profile, false)
+			cfg := &config.Config{
+				Profiles: []*config.Profile{tc.profile},
+			}
+			err := generateimapnotify(cfg, tc.profile, false)
 			if err != nil {
 				t.Fatalf("cannot generate file: %v", err)
 			}
@@ -214,6 +220,14 @@ func TestGeneratembsyncrc(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			"options",
+			[]string{
+				"linux",
+				"darwin",
+			},
+			nil,
+		},
 	}
 	for _, tc := range tt {
 		for _, system := range tc.systems {
@@ -223,9 +237,12 @@ func TestGeneratembsyncrc(t *testing.T) {
 			os.System = system
 			fs = testutil.NewFs(testutil.Name(t.Name()), testutil.SubName(tc.name), testutil.System(system))
 			os.Set(fs)
-			cfg := config.Read()
+			cfg, err := config.Read()
+			if err != nil {
+				t.Fatalf("%s: cannot read config: %v", tc.name, err)
+			}
 
-			err := generatembsyncrc(cfg, false)
+			err = generatembsyncrc(cfg, false)
 			if err != nil {
 				t.Fatalf("cannot generate file: %v", err)
 			}
@@ -267,6 +284,23 @@ func TestGenerateimapfilter(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			"rules",
+			[]string{
+				"linux",
+				"darwin",
+			},
+			nil,
+		},
+		// Personal is synced, and filtered, on its own.
+		{
+			"own_schedule",
+			[]string{
+				"linux",
+				"darwin",
+			},
+			nil,
+		},
 	}
 	for _, tc := range tt {
 		for _, system := range tc.systems {
@@ -276,9 +310,12 @@ func TestGenerateimapfilter(t *testing.T) {
 			os.System = system
 			fs = testutil.NewFs(testutil.Name(t.Name()), testutil.SubName(tc.name), testutil.System(system))
 			os.Set(fs)
-			cfg := config.Read()
+			cfg, err := config.Read()
+			if err != nil {
+				t.Fatalf("%s: cannot read config: %v", tc.name, err)
+			}
 
-			err := generateimapfilter(cfg, false)
+			err = generateimapfilter(cfg, false)
 			if err != nil {
 				t.Fatalf("cannot generate file: %v", err)
 			}
@@ -301,11 +338,13 @@ func TestGenerateimapfilter(t *testing.T) {
 func setupMockServices() {
 	SetMbsync(newMockMbsync)
 	SetImapnotify(newMockImapnotify)
+	SetMbsyncProfile(newMockMbsyncProfile)
 }
 
 func restoreServices() {
 	SetMbsync(MbsyncCtor)
 	SetImapnotify(ImapnotifyCtor)
+	SetMbsyncProfile(MbsyncProfileCtor)
 }
 
 func newMockMbsync(cfg *config.Config) Service {
@@ -320,6 +359,12 @@ func newMockImapnotify(cfg *config.Config, profile *config.Profile) Service {
 	}
 }
 
+func newMockMbsyncProfile(cfg *config.Config, profile *config.Profile) Service {
+	return &MockService{
+		Service: MbsyncProfileCtor(cfg, profile),
+	}
+}
+
 type MockService struct {
 	Service
 }
@@ -328,3 +373,21 @@ func (MockService) Start()   {}
 func (MockService) Stop()    {}
 func (MockService) Enable()  {}
 func (MockService) Disable() {}
+
+func TestInstance(t *testing.T) {
+	tt := []struct {
+		name string
+		want string
+	}{
+		{"Work", "Work"},
+		{"work-mail", `work\x2dmail`},
+		{"my.work_1", "my.work_1"},
+		{".hidden", `\x2ehidden`},
+		{"büro", `b\xc3\xbcro`},
+	}
+	for _, tc := range tt {
+		if got := instance(tc.name); got != tc.want {
+			t.Fatalf("%s: got %s, want: %s", tc.name, got, tc.want)
+		}
+	}
+}
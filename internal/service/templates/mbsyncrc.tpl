@@ -1,51 +1,35 @@
 SyncState *
 {{ $OS := .OS}}
-{{ range $Profile := .Profiles }}
+{{ range $Profile := .Profiles }}{{ $mbsync := $Profile.MbsyncOptions }}
 IMAPAccount {{ $Profile.Name }}
 Host {{ $Profile.ImapHost }}
-User {{ $Profile.ImapUser }}
-{{if eq $OS "linux"}}PassCmd "secret-tool lookup user {{ $Profile.ImapUser }} host {{ $Profile.ImapHost }} service imap port {{ $Profile.ImapPort }}"{{else if eq $OS "darwin"}}UseKeychain yes{{end}}
-SSLType IMAPS
-AuthMechs LOGIN
+{{ if ne $Profile.ImapPort $mbsync.DefaultPort }}Port {{ $Profile.ImapPort }}
+{{ end }}User {{ $Profile.ImapUser }}
+{{if eq $OS "linux"}}PassCmd "{{ $.PassCmd "imap" $Profile }}"{{else if eq $OS "darwin"}}UseKeychain yes{{end}}
+SSLType {{ $mbsync.SSL }}
+{{ with $mbsync.CertificateFile }}CertificateFile {{ . }}
+{{ end }}{{ with $mbsync.PipelineDepth }}PipelineDepth {{ . }}
+{{ end }}AuthMechs LOGIN
 
 IMAPStore {{ $Profile.Name }}-remote
 Account {{ $Profile.Name }}
 
 MaildirStore {{ $Profile.Name }}-local
 SubFolders Verbatim
-Path ~/Maildir/{{ $Profile.Name }}/
-Inbox ~/Maildir/{{ $Profile.Name }}/INBOX
-
-Channel {{ $Profile.Name }}-inbox
-Master :{{ $Profile.Name }}-remote:INBOX
-Slave :{{ $Profile.Name }}-local:INBOX
-Create Slave
-Sync All
-Expunge Both
-
-Channel {{ $Profile.Name }}-trash
-Master ":{{ $Profile.Name }}-remote:[Gmail]/Bin"
-Slave ":{{ $Profile.Name }}-local:trash"
-Create Slave
-Sync All
-
-Channel {{ $Profile.Name }}-sent
-Master ":{{ $Profile.Name }}-remote:[Gmail]/Sent Mail"
-Slave ":{{ $Profile.Name }}-local:sent"
-Create Slave
-Sync All
-Expunge Both
-
-Channel {{ $Profile.Name }}-allmail
-Master ":{{ $Profile.Name }}-remote:email-archive"
-Slave ":{{ $Profile.Name }}-local:email-archive"
-Create Slave
-Sync All
-Expunge Slave
-
-Group {{ $Profile.Name }}
-Channel {{ $Profile.Name }}-inbox
-Channel {{ $Profile.Name }}-trash
-Channel {{ $Profile.Name }}-sent
-Channel {{ $Profile.Name }}-allmail
-{{ end }}
\ No newline at end of file
+Path {{ $.Tilde ($.Maildir $Profile) }}/
+Inbox {{ $.Tilde ($.Maildir $Profile) }}/INBOX
+{{ with $mbsync.MaxSize }}MaxSize {{ . }}
+{{ end }}
+{{ range $Folder := $Profile.Channels }}{{ $ch := $Profile.Channel $Folder }}Channel {{ $Profile.Name }}-{{ $Folder.Name }}
+{{ $.Side "Far" }} {{ if eq $Folder.Remote "INBOX" }}:{{ $Profile.Name }}-remote:INBOX{{ else }}":{{ $Profile.Name }}-remote:{{ $Folder.Remote }}"{{ end }}
+{{ $.Side "Near" }} {{ if eq $Folder.Local "INBOX" }}:{{ $Profile.Name }}-local:INBOX{{ else }}":{{ $Profile.Name }}-local:{{ $Folder.Local }}"{{ end }}
+{{ with $Folder.Patterns }}Patterns{{ range . }} "{{ . }}"{{ end }}
+{{ end }}Create {{ $.Side $ch.Create }}
+Sync {{ $ch.Sync }}
+{{ if $Folder.Expunge }}Expunge {{ $.Side $Folder.Expunge }}
+{{ end }}{{ with $ch.MaxMessages }}MaxMessages {{ . }}
+{{ end }}{{ if $ch.CopyArrivalDate }}CopyArrivalDate yes
+{{ end }}
+{{ end }}{{ if not $Profile.Disabled }}Group {{ $Profile.Name }}
+{{ range $Folder := $Profile.Channels }}Channel {{ $Profile.Name }}-{{ $Folder.Name }}
+{{ end }}{{ end }}{{ end }}
\ No newline at end of file
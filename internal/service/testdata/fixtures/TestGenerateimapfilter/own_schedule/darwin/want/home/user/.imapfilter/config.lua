@@ -0,0 +1,20 @@
+
+function get_pass(server, username, port)
+	local status, output = pipe_from("security find-internet-password -a " .. username .. " -s " .. server .. " -r imap -P " .. port .. " -w")
+assert(status == 0, "password retrieve error")
+	return output
+end
+
+options.timeout = 300
+options.subscribe = true
+
+user_example_com = IMAP {
+	server = "imap.gmail.com",
+	port = 993,
+	ssl = "auto",
+	username = "user@example.com",
+	password = get_pass("imap.gmail.com", "user@example.com", "993"),
+}
+
+results = user_example_com["email-archive"]:is_unseen()
+results:mark_seen()
@@ -0,0 +1,42 @@
+
+function get_pass(server, username, port)
+	local status, output = pipe_from("secret-tool lookup user " .. username .. " host " .. server .. " service imap port " .. port)
+assert(status == 0, "password retrieve error")
+	return output
+end
+
+options.timeout = 300
+options.subscribe = true
+
+user_example_com = IMAP {
+	server = "imap.gmail.com",
+	port = 993,
+	ssl = "auto",
+	username = "user@example.com",
+	password = get_pass("imap.gmail.com", "user@example.com", "993"),
+}
+
+john_doe_gmail_com = IMAP {
+	server = "imap.gmail.com",
+	port = 993,
+	ssl = "auto",
+	username = "john.doe@gmail.com",
+	password = get_pass("imap.gmail.com", "john.doe@gmail.com", "993"),
+}
+
+results = user_example_com["email-archive"]:is_unseen()
+results:mark_seen()
+
+-- Reports from the boss
+results = user_example_com["INBOX"]:contain_from("boss@example.com") *
+	user_example_com["INBOX"]:contain_subject("weekly \"report\"")
+results:mark_flagged()
+results:copy_messages(john_doe_gmail_com["Work/Reports"])
+results:move_messages(user_example_com["Reports"])
+
+-- Old mailing list mail
+results = user_example_com["Lists"]:contain_field("List-Id", "golang-nuts.googlegroups.com") *
+	user_example_com["Lists"]:is_larger(100000) *
+	user_example_com["Lists"]:is_older(30)
+results:mark_seen()
+results:delete_messages()
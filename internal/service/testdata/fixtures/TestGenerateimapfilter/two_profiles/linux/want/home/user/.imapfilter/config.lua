@@ -16,9 +16,6 @@ user_example_com = IMAP {
 	password = get_pass("imap.gmail.com", "user@example.com", "993"),
 }
 
-results = user_example_com["email-archive"]:is_unseen()
-results:mark_seen()
-
 john_doe_gmail_com = IMAP {
 	server = "imap.gmail.com",
 	port = 993,
@@ -27,5 +24,8 @@ john_doe_gmail_com = IMAP {
 	password = get_pass("imap.gmail.com", "john.doe@gmail.com", "993"),
 }
 
+results = user_example_com["email-archive"]:is_unseen()
+results:mark_seen()
+
 results = john_doe_gmail_com["email-archive"]:is_unseen()
 results:mark_seen()
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/gianz74/mailconf/internal/exec"
+)
+
+var ErrMbsyncVersion = errors.New("cannot find the version in the output of mbsync --version")
+
+var mbsyncVersionRe = regexp.MustCompile(`isync ([0-9]+(\.[0-9]+)*)`)
+
+// MbsyncVersion returns the version of the installed mbsync, such as
+// "1.4.4", as printed by "mbsync --version".
+func MbsyncVersion() (string, error) {
+	out, err := exec.Output("mbsync", "--version")
+	if err != nil {
+		return "", err
+	}
+	m := mbsyncVersionRe.FindSubmatch(out)
+	if m == nil {
+		return "", ErrMbsyncVersion
+	}
+	return string(m[1]), nil
+}
@@ -1,20 +1,20 @@
 package setup
 
 import (
-	"embed"
 	"errors"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/gianz74/mailconf"
 	"github.com/gianz74/mailconf/internal/base"
 	"github.com/gianz74/mailconf/internal/config"
-	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/frontend"
 	"github.com/gianz74/mailconf/internal/myterm"
 	"github.com/gianz74/mailconf/internal/options"
 	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/service"
 )
 
 var CmdSetup = &base.Command{
@@ -28,8 +28,25 @@ It also provides some guidance to satisfy the requirements.  If the
 requirements are met, it prepares the system copying some scripts to
 the locations provided by the user.
 
+It asks for the maildir root, the directory holding the mail of all
+the profiles, ~/Maildir by default.
+
+It detects the version of mu and the directory holding mu4e, so that
+mu4e.el loads mu4e from there and calls the functions of that version;
+the directory is asked for if it cannot be found. It also detects the
+version of mbsync, as mbsync 1.4 renamed the Master and Slave sides of
+the channels to Far and Near.
+
+It asks for the mu4e preferences: the browser opening links, the key
+starting mu4e, the context policy, the signature and whether to compose
+in a new frame. Bookmarks and the other preferences are kept in the
+mu4e section of the configuration, and default to settings that need
+nothing but mu4e itself.
+
 It optionally allows the user to specify the email profiles to be
-configured.
+configured, and the key switching to the mu4e context of each. The mu
+index is created with their addresses, and the mail can be downloaded
+and indexed right away.
 
 The -dry-run option allows the user to preview the changes without
 actually making any to the system.
@@ -41,9 +58,6 @@ var (
 	dryrun  bool
 	verbose bool
 
-	//go:embed onnewmail.sh
-	//go:embed syncmail.sh
-	f               embed.FS
 	ErrExists       = errors.New("Config file exists.")
 	ErrRequirements = errors.New("Requirements not met.")
 	ErrNoTerm       = errors.New("Not in a terminal.")
@@ -57,10 +71,20 @@ func init() {
 
 func runSetup(cmd *base.Command, args []string) error {
 	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
-	cfg := config.Read()
-	if cfg != nil {
+	unlock, err := config.Lock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+	defer unlock()
+	cfg, err := config.Read()
+	if err == nil {
 		return ErrExists
 	}
+	if !errors.Is(err, config.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Cannot read config: %v\n", err)
+		return err
+	}
 
 	cfg = config.NewConfig()
 
@@ -69,22 +93,53 @@ func runSetup(cmd *base.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	cfg.EmacsCfgDir = expandUser(emacsdir)
+	cfg.EmacsCfgDir = os.ExpandUser(emacsdir)
 	bindir, err := t.ReadLine("enter user's bin directory: ")
 	if err != nil {
 		return err
 	}
 
-	cfg.BinDir = expandUser(bindir)
+	cfg.BinDir = os.ExpandUser(bindir)
 	if !checkRequirements(cfg.BinDir) {
 		return ErrRequirements
 	}
 
-	data, _ := f.ReadFile("onnewmail.sh")
-	io.Write(filepath.Join(cfg.BinDir, "onnewmail.sh"), data, 0750)
+	err = askMaildirRoot(t, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = detectMu(t, cfg)
+	if err != nil {
+		return err
+	}
+	detectMbsync(cfg)
+
+	cfg.Mu4e = &config.Mu4e{}
+	err = askMu4e(t, cfg.Mu4e)
+	if err != nil {
+		return err
+	}
+
+	err = config.CheckActive()
+	if errors.Is(err, config.ErrInactive) || errors.Is(err, config.ErrDetached) {
+		// the generated files belong to the active config.
+		fmt.Printf("%v\nConfig saved without generating any file.\n", err)
+		return cfg.Save()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = frontend.GenerateOnNewMail(cfg, true)
+	if err != nil {
+		return err
+	}
 
-	data, _ = f.ReadFile("syncmail.sh")
-	io.Write(filepath.Join(cfg.BinDir, "syncmail.sh"), data, 0750)
+	err = service.GenerateSyncmail(cfg, true)
+	if err != nil {
+		return err
+	}
 
 	ans, err := t.ReadLine("do you want to create an email profile? [y/n]: ")
 	if err != nil {
@@ -110,6 +165,10 @@ func runSetup(cmd *base.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+		err = askContextKey(t, profile, cfg)
+		if err != nil {
+			return err
+		}
 
 		ans, err := t.ReadLine("do you want to create another profile? [y/n]: ")
 		if err != nil {
@@ -123,6 +182,12 @@ func runSetup(cmd *base.Command, args []string) error {
 			break
 		}
 	}
+	if t.YesNo("do you want to download and index the mail now? [y/n]: ") {
+		err = firstSync(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot sync the mail: %v\n", err)
+		}
+	}
 	err = cfg.Save()
 	if err != nil {
 		return err
@@ -130,6 +195,136 @@ func runSetup(cmd *base.Command, args []string) error {
 	return nil
 }
 
+// firstSync downloads the mail of all the profiles and indexes it.
+func firstSync(cfg *config.Config) error {
+	err := exec.Run("mbsync", "-a")
+	if err != nil {
+		return err
+	}
+	return frontend.New(cfg).Index()
+}
+
+// detectMu stores the version of mu and the directory of mu4e in cfg,
+// asking for the directory if it cannot be found.
+func detectMu(t myterm.Terminal, cfg *config.Config) error {
+	version, err := frontend.MuVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot detect the version of mu: %v\n", err)
+	}
+	cfg.MuVersion = version
+	cfg.Mu4eDir = frontend.Mu4eDir(version)
+	for cfg.Mu4eDir == "" {
+		dir, err := t.ReadLine("enter the mu4e directory (empty if mu4e is in the emacs load-path): ")
+		if err != nil {
+			return err
+		}
+		dir = os.ExpandUser(strings.TrimSpace(dir))
+		if dir == "" {
+			return nil
+		}
+		if filepath.IsAbs(dir) {
+			cfg.Mu4eDir = dir
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%s is not an absolute path\n", dir)
+	}
+	return nil
+}
+
+// detectMbsync records the version of mbsync, which names the sides of
+// the channels Far and Near since 1.4, and Master and Slave before.
+func detectMbsync(cfg *config.Config) {
+	version, err := service.MbsyncVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot detect the version of mbsync: %v\n", err)
+	}
+	cfg.MbsyncVersion = version
+}
+
+// askMaildirRoot asks for the maildir root, leaving it unset for the
+// default ~/Maildir.
+func askMaildirRoot(t myterm.Terminal, cfg *config.Config) error {
+	for {
+		root, err := t.ReadLine("enter the maildir root [~/Maildir]: ")
+		if err != nil {
+			return err
+		}
+		root = strings.TrimSpace(root)
+		cfg.MaildirRoot = ""
+		if root != "" {
+			cfg.MaildirRoot = filepath.Clean(os.ExpandUser(root))
+		}
+		if cfg.MaildirRoot == (&config.Config{}).MaildirRootPath() {
+			cfg.MaildirRoot = ""
+		}
+		// the other fields are not complete yet.
+		err = (&config.Config{MaildirRoot: cfg.MaildirRoot}).Validate()
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// askMu4e asks for the preferences written to mu4e.el, repeating a
+// question until its answer is valid.
+func askMu4e(t myterm.Terminal, m *config.Mu4e) error {
+	questions := []struct {
+		prompt string
+		set    func(string)
+	}{
+		{"mu4e browser (chrome, firefox, eww; empty for the emacs default): ", func(s string) { m.Browser = s }},
+		{"key starting mu4e, e.g. C-c m (empty for none): ", func(s string) { m.Keybinding = s }},
+		{"mu4e context policy (pick-first, ask, ask-if-none, always-ask) [pick-first]: ", func(s string) { m.ContextPolicy = s }},
+		{"mu4e signature (empty for the full name of the profile): ", func(s string) { m.Signature = s }},
+	}
+	for _, q := range questions {
+		for {
+			ans, err := t.ReadLine(q.prompt)
+			if err != nil {
+				return err
+			}
+			q.set(strings.TrimSpace(ans))
+			err = m.Validate()
+			if err == nil {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	if m.Browser == "chrome" {
+		ans, err := t.ReadLine("chrome profile directory, e.g. Profile 1 (empty for the default): ")
+		if err != nil {
+			return err
+		}
+		m.ChromeProfile = strings.TrimSpace(ans)
+	}
+	m.ComposeInNewFrame = t.YesNo("compose messages in a new frame? [y/n]: ")
+	return nil
+}
+
+// askContextKey asks for the key switching to the mu4e context of the
+// profile called name, regenerating mu4e.el if one is given.
+func askContextKey(t myterm.Terminal, name string, cfg *config.Config) error {
+	for {
+		ans, err := t.ReadLine(fmt.Sprintf("key switching to the %s context, e.g. C-c 1 (empty for none): ", name))
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSpace(ans)
+		if key == "" {
+			return nil
+		}
+		cfg.Mu4e.SetContextKey(name, key)
+		err = cfg.Validate()
+		if err == nil {
+			return frontend.New(cfg).GenConf(true)
+		}
+		cfg.Mu4e.SetContextKey(name, "")
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
 var checkRequirements = _checkRequirements
 
 func _checkRequirements(bindir string) bool {
@@ -163,14 +358,3 @@ func _checkRequirements(bindir string) bool {
 	}
 	return true
 }
-
-func expandUser(path string) string {
-	home, _ := os.UserHomeDir()
-
-	if path == "~" {
-		return home
-	} else if strings.HasPrefix(path, "~/") {
-		return filepath.Join(home, path[2:])
-	}
-	return path
-}
@@ -2,10 +2,15 @@ package setup
 
 import (
 	"path"
+	"reflect"
 	"testing"
 
 	"github.com/gianz74/mailconf/internal/cred"
 	"github.com/gianz74/mailconf/internal/cred/memcred"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/exec/memexec"
+	"github.com/gianz74/mailconf/internal/imap"
+	"github.com/gianz74/mailconf/internal/imap/memimap"
 	"github.com/gianz74/mailconf/internal/myterm"
 	"github.com/gianz74/mailconf/internal/myterm/memterm"
 	"github.com/gianz74/mailconf/internal/os"
@@ -17,6 +22,9 @@ import (
 var (
 	mockTerm      = memterm.New()
 	mockCredStore = memcred.New()
+	mockExec      = memexec.New()
+	mockImap      = memimap.New()
+	oldExec       exec.Runner
 )
 
 func setup() error {
@@ -25,12 +33,26 @@ func setup() error {
 	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
 	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
 	cred.SetStore(mockCredStore)
+	imap.Set(mockImap)
 	mockservice.SetupMockServices()
-	return nil
+	mockExec.Reset()
+	mockExec.SetOutput("mu --version", "mu (mail indexer/searcher) version 1.8.13\nCopyright (C) 2008-2022 Dirk-Jan C. Binnema\n", nil)
+	mockExec.SetPath("mu", "/usr/bin/mu")
+	mockExec.SetOutput("mbsync --version", "isync 1.4.4\n", nil)
+	if old := exec.Set(mockExec); old != mockExec {
+		oldExec = old
+	}
+	mu4edir := "/usr/share/emacs/site-lisp/elpa-src/mu4e-1.8.13"
+	err := os.MkdirAll(mu4edir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(mu4edir, "mu4e.el"), []byte(";;; mu4e.el"), 0644)
 }
 
 func restore() {
 	mockservice.RestoreServices()
+	exec.Set(oldExec)
 }
 
 func readConf() (string, error) {
@@ -56,6 +78,7 @@ func TestSetup(t *testing.T) {
 		input   []string
 		cred    *creds
 		err     error
+		ran     []string
 	}{
 
 		{
@@ -68,6 +91,7 @@ func TestSetup(t *testing.T) {
 			[]string{},
 			nil,
 			ErrExists,
+			nil,
 		},
 		{
 			"ConfNotExisting",
@@ -78,10 +102,17 @@ func TestSetup(t *testing.T) {
 			[]string{},
 			[]string{"~/.emacs.d",
 				"~/.local/bin",
+				"",
+				"",
+				"",
+				"",
+				"",
+				"n",
 				"n",
 			},
 			nil,
 			nil,
+			nil,
 		},
 		{
 			"CreateProfile",
@@ -93,6 +124,13 @@ func TestSetup(t *testing.T) {
 			[]string{
 				"~/.emacs.d",
 				"~/.local/bin",
+				"~/Mail",
+				"chrome",
+				"C-c m",
+				"",
+				"",
+				"Profile 1",
+				"y",
 				"y",
 				"Test",
 				"John Doe",
@@ -105,13 +143,23 @@ func TestSetup(t *testing.T) {
 				"456",
 				"test@gmail.com",
 				"secret",
+				"C-c 1",
 				"n",
+				"y",
 			},
 			&creds{
 				"imap://test@gmail.com:secret@imap.gmail.com:997",
 				"smtp://test@gmail.com:secret@smtp.gmail.com:456",
 			},
 			nil,
+			[]string{
+				"mu --version",
+				"mbsync --version",
+				"mu init --maildir /home/user/Mail --my-address jdoe@gmail.com",
+				"mu index",
+				"mbsync -a",
+				"mu index",
+			},
 		},
 		{
 			"CreateProfile Existing Credentials",
@@ -125,6 +173,14 @@ func TestSetup(t *testing.T) {
 			[]string{
 				"~/.emacs.d",
 				"~/.local/bin",
+				"~/My Mail",
+				"",
+				"opera",
+				"eww",
+				"",
+				"ask",
+				"Best regards",
+				"n",
 				"y",
 				"Test",
 				"John Doe",
@@ -138,6 +194,8 @@ func TestSetup(t *testing.T) {
 				"456",
 				"test@gmail.com",
 				"secret",
+				"",
+				"n",
 				"n",
 			},
 			&creds{
@@ -145,6 +203,7 @@ func TestSetup(t *testing.T) {
 				"smtp://test@gmail.com:secret@smtp.gmail.com:456",
 			},
 			nil,
+			nil,
 		},
 	}
 	for _, tc := range tt {
@@ -177,6 +236,9 @@ func TestSetup(t *testing.T) {
 						t.Fatalf("%s: got: %s, want: %s\n", tc.name, got, want)
 					}
 				}
+				if tc.ran != nil && !reflect.DeepEqual(mockExec.Ran(), tc.ran) {
+					t.Fatalf("%s: got commands %q, want: %q\n", tc.name, mockExec.Ran(), tc.ran)
+				}
 				if tc.cred != nil {
 					got, want := testutil.CheckCreds(tc.cred.imappwd)
 					if got != want {
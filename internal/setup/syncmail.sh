@@ -1,4 +0,0 @@
-#!/bin/sh
-
-mbsync --all
-imapfilter
@@ -1,14 +1,21 @@
-(diminish 'overwrite-mode)
 (if (not (eq system-type 'windows-nt))
     (progn
-      (if (eq system-type 'darwin)
-	  (add-to-list 'load-path "/usr/local/share/emacs/site-lisp/mu/mu4e")
-	  )
-      (if (eq system-type 'gnu/linux)
-	  (add-to-list 'load-path "/usr/share/emacs/site-lisp/mu4e")
-	  )
+      (add-to-list 'load-path "/usr/share/emacs/site-lisp/elpa-src/mu4e-1.8.13")
       (require 'mu4e)
       (require 'smtpmail)
+      (require 'subr-x)
+
+      (defun mailconf-read-signature (file)
+	"Return the content of FILE, or nil if it cannot be read."
+	(when (file-readable-p file)
+	  (with-temp-buffer
+	    (insert-file-contents file)
+	    (string-trim-right (buffer-string)))))
+
+      (defun mailconf-addresses-regexp (addresses)
+	"Return a regexp matching exactly one of ADDRESSES."
+	(concat "\\`" (regexp-opt addresses) "\\'"))
+
       (setq mu4e-contexts
 	    `( ,(make-mu4e-context
 		 :name "Test"
@@ -21,18 +28,18 @@
 						smtpmail-default-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-server "smtp.gmail.com"
 						smtpmail-smtp-service 456
-						smtpmail-debug-info t)
-					  (if (eq system-type 'darwin)
-					      (setq browse-url-chrome-arguments '("--profile-directory=Profile 1"))
-					      )))
+						smtpmail-debug-info t)))
 		 :leave-func (lambda () (mu4e-message "Leaving Test context"))
-		 ;; we match based on the contact-fields of the message
+		 ;; we match based on the maildir and on the contact-fields of the message
 		 :match-func (lambda (msg)
 			       (when msg
-				 (string-match-p "^/Test" (mu4e-message-field msg :maildir))))
-		 :vars '( ( user-mail-address      . "jdoe@gmail.com"  )
+				 (or (string-match-p "^/Test/" (mu4e-message-field msg :maildir))
+				     (mu4e-message-contact-field-matches
+				      msg '(:to :cc :bcc)
+				      (mailconf-addresses-regexp '("jdoe@gmail.com"))))))
+		 :vars `( ( user-mail-address      . "jdoe@gmail.com"  )
 			 ( user-full-name         . "John Doe" )
-			 ( mu4e-compose-signature . "John Doe")
+			 ( mu4e-compose-signature . "Best regards")
 			 ( mu4e-drafts-folder     . "/Test/drafts")
 			 ( mu4e-sent-folder       . "/Test/sent")
 			 ( mu4e-refile-folder     . "/Test/email-archive")
@@ -51,7 +58,7 @@
 		
 		))
 
-      (setq mu4e-context-policy 'pick-first)
+      (setq mu4e-context-policy 'ask)
 
       (setq mu4e-compose-context-policy nil)
 
@@ -64,13 +71,10 @@
 	    mu4e-update-interval 300
 	    mu4e-headers-leave-behavior 'apply
 	    mu4e-view-show-addresses t
-	    mu4e-compose-in-new-frame t
+	    mu4e-compose-in-new-frame nil
 	    mu4e-user-agent-string nil
 	    message-kill-buffer-on-exit t)
-      (setq browse-url-browser-function 'browse-url-chrome)
-      (if (eq system-type 'darwin)
-	  (setq browse-url-chrome-program "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome")
-	  )
+      (setq browse-url-browser-function 'eww-browse-url)
       (add-to-list 'mu4e-view-actions
 		   '("ViewInBrowser" . mu4e-action-view-in-browser) t)
       ;; enable inline images
@@ -91,8 +95,8 @@
       (advice-add #'shr-colorize-region :around (defun shr-no-colourise-region (&rest ignore)))
 
 
-      (require 'org-mu4e)
-      (global-set-key "\C-cm" 'mu4e)
+      ;; org links to messages.
+      (require 'mu4e-org)
       )
     (defvar mu4e-reindex-request-file "/tmp/mail/mu_reindex_now"
       "Location of the reindex request, signaled by existance")
@@ -115,9 +119,7 @@ Prevents a series of redisplays from being called (when set to an appropriate va
       (if mu4e-reindex-request--file-watcher
 	  (file-notify-rm-watch mu4e-reindex-request--file-watcher)))
 
-    (if (fboundp 'mu4e~proc-kill)
-	(advice-add 'mu4e~proc-kill :after 'mu4e-stop-watching-for-reindex-request)
-	(advice-add 'mu4e--server-kill :after 'mu4e-stop-watching-for-reindex-request))
+    (advice-add 'mu4e--server-kill :after 'mu4e-stop-watching-for-reindex-request)
 
     (defun mu4e-watch-for-reindex-request ()
       (let (directory) (setq directory (file-name-directory mu4e-reindex-request-file))
@@ -127,9 +129,7 @@ Prevents a series of redisplays from being called (when set to an appropriate va
       (when (file-exists-p mu4e-reindex-request-file)
 	(delete-file mu4e-reindex-request-file))
       (mu4e-reindex-request--add-watcher))
-    (if (fboundp 'mu4e~proc-start)
-	(advice-add 'mu4e~proc-start :after 'mu4e-watch-for-reindex-request)
-	(advice-add 'mu4e--server-start :after 'mu4e-watch-for-reindex-request))
+    (advice-add 'mu4e--server-start :after 'mu4e-watch-for-reindex-request)
 
     (defun mu4e-file-reindex-request (event)
       "Act based on the existance of `mu4e-reindex-request-file'"
@@ -142,16 +142,14 @@ Prevents a series of redisplays from being called (when set to an appropriate va
 	    (mu4e-reindex-maybe t))))
 
     (defun mu4e-reindex-maybe (&optional new-request)
-      "Run `mu4e~proc-index' if it's been more than
+      "Run `mu4e--server-index' if it's been more than
 `mu4e-reindex-request-min-seperation'seconds since the last request,"
       (let ((time-since-last-request (- (float-time)
 					mu4e-reindex-request--last-time)))
 	(when new-request
 	  (setq mu4e-reindex-request--last-time (float-time)))
 	(if (> time-since-last-request mu4e-reindex-request-min-seperation)
-	    (if (fboundp 'mu4e~proc-index)
-		(mu4e~proc-index nil t)
-		(mu4e--server-index nil t))
+	    (mu4e--server-index nil t)
 	    (when new-request
 	      (run-at-time (* 1.1 mu4e-reindex-request-min-seperation) nil
 			   #'mu4e-reindex-maybe)))))
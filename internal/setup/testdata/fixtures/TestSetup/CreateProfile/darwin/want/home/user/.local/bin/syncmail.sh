@@ -1,4 +1,16 @@
 #!/bin/sh
 
-mbsync --all
+# syncmail.sh [profile...] syncs the given profiles, each filtered with
+# its own imapfilter config, or the profiles sharing the mbsync timer
+# when none is given.
+if [ $# -gt 0 ]; then
+	status=0
+	for profile; do
+		mbsync "$profile" || status=1
+		imapfilter -c "$HOME/.imapfilter/profiles/$profile.lua" || status=1
+	done
+	exit $status
+fi
+
+mbsync Test
 imapfilter
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/options"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/templates"
+)
+
+var CmdExport = &base.Command{
+	UsageLine: "export [-f -dry-run -v] [dir]",
+	Short:     "export copies the default templates for editing",
+	Long: `
+
+Export copies the embedded default templates to dir, which defaults to
+$XDG_CONFIG_HOME/mailconf/templates (~/.config/mailconf/templates if
+XDG_CONFIG_HOME is unset), where they override the defaults.
+
+Existing files are left untouched, unless the -f option is given.
+
+The -dry-run option allows the user to preview the changes without
+actually making any to the system.
+
+The -v option increases verbosity, printing the content of the files
+that are to be written.`,
+}
+
+var (
+	force   bool
+	dryrun  bool
+	verbose bool
+)
+
+func init() {
+	CmdExport.Run = runExport
+	CmdExport.Flag.BoolVar(&force, "f", false, "Overwrite existing files.")
+	CmdExport.Flag.BoolVar(&dryrun, "dry-run", false, "Show changes without making any.")
+	CmdExport.Flag.BoolVar(&verbose, "v", false, "Show content of files to be written.")
+}
+
+func runExport(cmd *base.Command, args []string) error {
+	options.Set(options.OptDryrun(dryrun), options.OptVerbose(verbose))
+	dir, err := templates.Dir()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	err = templates.Export(dir, force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot export templates: %v\n", err)
+		return err
+	}
+	return nil
+}
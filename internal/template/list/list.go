@@ -0,0 +1,31 @@
+package list
+
+import (
+	"fmt"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/templates"
+)
+
+var CmdList = &base.Command{
+	UsageLine: "list",
+	Short:     "list templates.",
+	Long: `
+List prints the name of every template, marking the ones overridden
+by the user with an asterisk.`,
+}
+
+func init() {
+	CmdList.Run = runList
+}
+
+func runList(cmd *base.Command, args []string) error {
+	for _, name := range templates.Names() {
+		mark := " "
+		if _, ok := templates.Override(name); ok {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, name)
+	}
+	return nil
+}
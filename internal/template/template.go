@@ -0,0 +1,116 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/template/export"
+	"github.com/gianz74/mailconf/internal/template/list"
+)
+
+var CmdTemplate = &base.Command{
+	UsageLine: "template command",
+	Short:     "template manages the templates of generated files",
+}
+
+func init() {
+	CmdTemplate.Run = runTemplate
+	CmdTemplate.Commands = []*base.Command{
+		list.CmdList,
+		export.CmdExport,
+	}
+	CmdTemplate.Long = tmpl(usageTemplate, CmdTemplate.Commands)
+}
+
+func runTemplate(cmd *base.Command, args []string) error {
+	for _, cmd := range cmd.Commands {
+		cmd.Flag.Usage = cmd.Usage
+		if len(args) > 0 && cmd.Name() == args[0] {
+			cmd.Flag.Parse(args[1:])
+			args = cmd.Flag.Args()
+			return cmd.Run(cmd, args)
+		}
+	}
+	fmt.Println(tmpl(usageTemplate, cmd.Commands))
+	return nil
+}
+
+func tmpl(text string, data interface{}) string {
+	t := template.New("top")
+	t.Funcs(template.FuncMap{"trim": strings.TrimSpace})
+	template.Must(t.Parse(text))
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, data); err != nil {
+		panic(err)
+	}
+	return string(out.Bytes())
+}
+
+const usageTemplate = `template is a subcommand to inspect and customize the templates used
+to generate configuration files.
+
+Every template is first looked up in $XDG_CONFIG_HOME/mailconf/templates
+(default ~/.config), using the same relative name as the embedded
+default; if no override is found the embedded default is used.
+Overrides are parsed every time mailconf starts, and errors are
+reported before any file is touched.
+
+Every template receives the same data:
+
+	.Cfg         the whole mailconf configuration
+	.Profiles    all the configured profiles
+	.Enabled     the profiles that are not disabled
+	.Scheduled   the enabled profiles synced by the shared timer
+	.Identities  the alias identities of all the profiles
+	.Profile     the profile being generated, nil for shared files
+	.OS          the operating system, "linux" or "darwin"
+	.HomeDir     the user's home directory
+	.CfgDir      the user's config directory
+	.BinDir      the directory holding mailconf's scripts
+	.XDGCfgDir   the config directory of msmtp, aerc and neomutt
+	.Version     the version of mailconf
+	.Interval    the sync interval in seconds, of .Profile if set
+	.MaildirRoot the directory holding the maildirs of the profiles
+
+	.PassCmd service profile
+	             the command printing the "imap" or "smtp" password
+	             of profile
+	.Maildir profile
+	             the absolute path of the maildir of profile
+	.MaildirFolder profile
+	             the maildir of profile relative to .MaildirRoot
+	.Side value  the mbsync side value, such as Far or Near, named
+	             Master or Slave for mbsync before 1.4
+	.Tilde path  path with the home directory replaced by ~
+	.Filtered    the profiles the imapfilter config filters: .Profile
+	             if set, .Scheduled otherwise
+	.Accounts    the .Filtered profiles and those their imapfilter
+	             rules move or copy mail to
+	.Rules profile
+	             the imapfilter rules of profile compiled to Lua
+
+and can use the following functions:
+
+	normalize s     s with '.' and '@' replaced by '_'
+	elisp s         s as an Emacs Lisp string literal
+	lua s           s as a Lua string literal
+	json v          v encoded as JSON
+	shell s         s quoted for a POSIX shell, when needed
+	join elem...    the path made of elem
+	default d v     v, or d when v is empty
+	timespan n      n seconds as a systemd time span, such as 5m
+	mutt s          s as a muttrc string
+	address n e     the mail address e with the display name n
+	mu s            s quoted for a mu query, when needed
+
+Usage:
+	mailconf template command [arguments]
+
+The commands are:
+{{range .}}
+	{{.Name | printf "%-11s"}} {{.Short}}{{end}}
+
+Use "mailconf help template [command]" for more information about a command.`
@@ -0,0 +1,280 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gianz74/mailconf/internal/base"
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+// Context is the data passed to every template, so that the same
+// fields are available no matter which file is being generated.
+type Context struct {
+	// Cfg is the whole mailconf configuration.
+	Cfg *config.Config
+	// Profiles lists all the configured profiles.
+	Profiles []*config.Profile
+	// Enabled lists the profiles that are not disabled.
+	Enabled []*config.Profile
+	// Scheduled lists the enabled profiles synced together by the
+	// shared mbsync timer, that is those not synced on their own.
+	Scheduled []*config.Profile
+	// Identities lists the identities of Profiles, with the full
+	// name and the signature file of their profile when unset.
+	Identities []*config.Identity
+	// Profile is the profile the file is generated for; it is nil
+	// for files shared by all the profiles.
+	Profile *config.Profile
+	// OS is the operating system the files are generated for, as
+	// in runtime.GOOS.
+	OS string
+	// HomeDir is the user's home directory.
+	HomeDir string
+	// CfgDir is the user's config directory.
+	CfgDir string
+	// BinDir is the directory holding mailconf's scripts and
+	// goimapnotify.
+	BinDir string
+	// XDGCfgDir is the config directory of the programs following
+	// the XDG base directory spec, such as msmtp, aerc and neomutt.
+	XDGCfgDir string
+	// Version is the version of mailconf generating the file.
+	Version string
+	// Interval is the sync interval in seconds: the one of Profile,
+	// or the shared one for files shared by all the profiles.
+	Interval int
+	// MaildirRoot is the directory holding the maildirs of the
+	// profiles.
+	MaildirRoot string
+}
+
+// NewContext returns the context for cfg; profile may be nil.
+func NewContext(cfg *config.Config, profile *config.Profile) (*Context, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	cfgdir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	var enabled, scheduled []*config.Profile
+	var identities []*config.Identity
+	for _, p := range cfg.Profiles {
+		for _, id := range p.Identities {
+			resolved := *id
+			if resolved.FullName == "" {
+				resolved.FullName = p.FullName
+			}
+			if resolved.SignatureFile == "" {
+				resolved.SignatureFile = p.SignatureFile
+			}
+			identities = append(identities, &resolved)
+		}
+		if p.Disabled {
+			continue
+		}
+		enabled = append(enabled, p)
+		if !cfg.OwnSchedule(p) {
+			scheduled = append(scheduled, p)
+		}
+	}
+	xdgcfgdir, err := os.XDGConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	interval := cfg.Interval()
+	if profile != nil {
+		interval = cfg.ProfileInterval(profile)
+	}
+	return &Context{
+		Cfg:         cfg,
+		Profiles:    cfg.Profiles,
+		Enabled:     enabled,
+		Scheduled:   scheduled,
+		Identities:  identities,
+		Profile:     profile,
+		OS:          os.System,
+		HomeDir:     home,
+		CfgDir:      cfgdir,
+		BinDir:      cfg.BinDir,
+		XDGCfgDir:   xdgcfgdir,
+		Version:     base.Version,
+		Interval:    interval,
+		MaildirRoot: cfg.MaildirRootPath(),
+	}, nil
+}
+
+// PassCmd returns the shell command printing the password stored for
+// service ("imap" or "smtp") of profile in the OS credentials store.
+func (c *Context) PassCmd(service string, profile *config.Profile) string {
+	user, host, port := profile.ImapUser, profile.ImapHost, profile.ImapPort
+	if service == "smtp" {
+		user, host, port = profile.SmtpUser, profile.SmtpHost, profile.SmtpPort
+	}
+	switch c.OS {
+	case "linux":
+		return fmt.Sprintf("secret-tool lookup user %s host %s service %s port %d", shell(user), shell(host), service, port)
+	case "darwin":
+		return fmt.Sprintf("security find-internet-password -a %s -s %s -r %s -P %d -w", shell(user), shell(host), service, port)
+	default:
+		return ""
+	}
+}
+
+// Maildir returns the absolute path of the maildir of profile.
+func (c *Context) Maildir(profile *config.Profile) string {
+	return c.Cfg.MaildirPath(profile)
+}
+
+// MaildirFolder returns the maildir of profile relative to
+// MaildirRoot, such as "Work", which prefixes its folders in mu4e and
+// notmuch.
+func (c *Context) MaildirFolder(profile *config.Profile) string {
+	return c.Cfg.MaildirFolder(profile)
+}
+
+// Side returns the mbsync keyword value, such as "Far" or "Near",
+// under the name known to the installed mbsync: versions before 1.4
+// call the sides Master and Slave.
+func (c *Context) Side(value string) string {
+	if c.Cfg.MbsyncAtLeast("1.4") {
+		switch value {
+		case "Master":
+			return "Far"
+		case "Slave":
+			return "Near"
+		}
+		return value
+	}
+	switch value {
+	case "Far":
+		return "Master"
+	case "Near":
+		return "Slave"
+	}
+	return value
+}
+
+// Tilde returns path with the home directory replaced by "~", for the
+// programs expanding it.
+func (c *Context) Tilde(path string) string {
+	if path == c.HomeDir {
+		return "~"
+	}
+	if strings.HasPrefix(path, c.HomeDir+"/") {
+		return "~" + strings.TrimPrefix(path, c.HomeDir)
+	}
+	return path
+}
+
+// Filtered returns the profiles the imapfilter config filters: Profile
+// for the config of a profile synced on its own, Scheduled otherwise.
+func (c *Context) Filtered() []*config.Profile {
+	if c.Profile != nil {
+		return []*config.Profile{c.Profile}
+	}
+	return c.Scheduled
+}
+
+// Accounts returns the profiles the imapfilter config connects to: the
+// Filtered ones and those their rules move or copy messages to.
+func (c *Context) Accounts() []*config.Profile {
+	filtered := c.Filtered()
+	accounts := append([]*config.Profile{}, filtered...)
+	for _, p := range filtered {
+		for _, name := range config.Targets(p.Rules) {
+			if target := c.profile(name); target != nil && !containsProfile(accounts, target) {
+				accounts = append(accounts, target)
+			}
+		}
+	}
+	return accounts
+}
+
+// Rules returns the rules of profile compiled to imapfilter Lua, every
+// one preceded by an empty line and a comment with its name.
+func (c *Context) Rules(profile *config.Profile) (string, error) {
+	var b strings.Builder
+	account := normalize(profile.ImapUser)
+	for _, r := range profile.Rules {
+		// without criteria a rule would act on the whole mailbox.
+		if r.Match == nil {
+			continue
+		}
+		mailbox := fmt.Sprintf("%s[%s]", account, lua(r.RuleMailbox()))
+		var criteria []string
+		search := func(method string, args ...string) {
+			criteria = append(criteria, fmt.Sprintf("%s:%s(%s)", mailbox, method, strings.Join(args, ", ")))
+		}
+		m := r.Match
+		if m.From != "" {
+			search("contain_from", lua(m.From))
+		}
+		if m.To != "" {
+			search("contain_to", lua(m.To))
+		}
+		if m.Subject != "" {
+			search("contain_subject", lua(m.Subject))
+		}
+		for _, name := range m.Headers() {
+			search("contain_field", lua(name), lua(m.Header[name]))
+		}
+		for _, n := range []struct {
+			method string
+			value  int
+		}{
+			{"is_larger", m.LargerThan},
+			{"is_smaller", m.SmallerThan},
+			{"is_older", m.OlderThan},
+			{"is_newer", m.NewerThan},
+		} {
+			if n.value != 0 {
+				search(n.method, fmt.Sprint(n.value))
+			}
+		}
+		fmt.Fprintf(&b, "\n-- %s\nresults = %s\n", r.Name, strings.Join(criteria, " *\n\t"))
+		for _, a := range r.Actions {
+			switch a.Action {
+			case "move", "copy":
+				target := account
+				if a.Profile != "" {
+					p := c.profile(a.Profile)
+					if p == nil {
+						return "", fmt.Errorf("rule %q: there is no profile %q", r.Name, a.Profile)
+					}
+					target = normalize(p.ImapUser)
+				}
+				fmt.Fprintf(&b, "results:%s_messages(%s[%s])\n", a.Action, target, lua(a.Mailbox))
+			case "delete":
+				b.WriteString("results:delete_messages()\n")
+			case "flag":
+				b.WriteString("results:mark_flagged()\n")
+			case "mark_seen":
+				b.WriteString("results:mark_seen()\n")
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// profile returns the profile called name, or nil.
+func (c *Context) profile(name string) *config.Profile {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func containsProfile(profiles []*config.Profile, p *config.Profile) bool {
+	for _, other := range profiles {
+		if other == p {
+			return true
+		}
+	}
+	return false
+}
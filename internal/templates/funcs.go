@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Funcs returns the functions available to every template:
+//
+//	normalize s     s with '.' and '@' replaced by '_', usable as an identifier
+//	elisp s         s as an Emacs Lisp string literal
+//	lua s           s as a Lua string literal
+//	json v          v encoded as JSON
+//	shell s         s quoted for a POSIX shell, when needed
+//	join elem...    the path made of elem
+//	default d v     v, or d when v is the zero value
+//	timespan n      n seconds as a systemd time span, such as 5m
+//	mutt s          s as a muttrc string
+//	address n e     the mail address e with the display name n
+//	mu s            s quoted for a mu query, when needed
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"normalize": normalize,
+		"elisp":     elisp,
+		"lua":       lua,
+		"json":      jsonValue,
+		"shell":     shell,
+		"join":      path.Join,
+		"default":   defaultValue,
+		"timespan":  timespan,
+		"mutt":      mutt,
+		"address":   address,
+		"mu":        mu,
+	}
+}
+
+func normalize(input string) string {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == '.' || r == '@'
+	})
+	return strings.Join(fields, "_")
+}
+
+var elispEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func elisp(s string) string {
+	return `"` + elispEscaper.Replace(s) + `"`
+}
+
+var luaEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+func lua(s string) string {
+	return `"` + luaEscaper.Replace(s) + `"`
+}
+
+func jsonValue(v any) (string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+var muttEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+
+func mutt(s string) string {
+	return `"` + muttEscaper.Replace(s) + `"`
+}
+
+func address(name, email string) string {
+	a := &mail.Address{Name: name, Address: email}
+	return a.String()
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+func shell(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var muSafe = regexp.MustCompile(`^[^\s"()]+$`)
+
+func mu(s string) string {
+	if muSafe.MatchString(s) {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func defaultValue(def, v any) any {
+	if v == nil {
+		return def
+	}
+	if reflect.ValueOf(v).IsZero() {
+		return def
+	}
+	return v
+}
+
+func timespan(seconds int) string {
+	switch {
+	case seconds != 0 && seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	case seconds != 0 && seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
@@ -0,0 +1,186 @@
+// Package templates resolves the templates used to generate the
+// configuration files, preferring the user's overrides found in
+// $XDG_CONFIG_HOME/mailconf/templates to the embedded defaults.
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/io"
+	"github.com/gianz74/mailconf/internal/os"
+)
+
+var (
+	ErrNotFound = errors.New("template not found")
+	ErrExists   = errors.New("template exists")
+
+	sources []fs.FS
+)
+
+// InvalidError reports the overrides that cannot be parsed.
+type InvalidError []error
+
+func (e InvalidError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid template overrides:\n\t" + strings.Join(msgs, "\n\t")
+}
+
+// Register adds the embedded defaults found in fsys. Names are
+// relative to the root of fsys.
+func Register(fsys fs.FS) {
+	sources = append(sources, fsys)
+}
+
+// Dir returns the directory holding the user's overrides, in the XDG
+// config directory on macOS too, as the configs of msmtp, aerc and
+// neomutt.
+func Dir() (string, error) {
+	cfgdir, err := os.XDGConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, "mailconf", "templates"), nil
+}
+
+// Names returns the sorted names of all the embedded defaults.
+func Names() []string {
+	var names []string
+	for _, src := range sources {
+		fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				names = append(names, name)
+			}
+			return nil
+		})
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the embedded default for name.
+func Default(name string) ([]byte, error) {
+	for _, src := range sources {
+		data, err := fs.ReadFile(src, name)
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+}
+
+// Override returns the user's override for name, if any.
+func Override(name string) ([]byte, bool) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Get returns the user's override for name, falling back to the
+// embedded default.
+func Get(name string) ([]byte, error) {
+	data, ok := Override(name)
+	if ok {
+		return data, nil
+	}
+	return Default(name)
+}
+
+// Parse returns the template called name, ready to be executed.
+func Parse(name string) (*template.Template, error) {
+	data, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(path.Base(name)).Funcs(Funcs()).Parse(string(data))
+}
+
+// Execute renders the template called name with data.
+func Execute(name string, data any) ([]byte, error) {
+	tmpl, err := Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	err = tmpl.Execute(out, data)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// IsTemplate reports whether name is a template, as opposed to a
+// file copied verbatim.
+func IsTemplate(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".tpl" || ext == ".tmpl"
+}
+
+// Validate parses every override, reporting all the ones with errors.
+func Validate() error {
+	var errs InvalidError
+	for _, name := range Names() {
+		if !IsTemplate(name) {
+			continue
+		}
+		data, ok := Override(name)
+		if !ok {
+			continue
+		}
+		_, err := template.New(path.Base(name)).Funcs(Funcs()).Parse(string(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Export copies the embedded defaults to dir, so that they can be
+// edited. Existing files are overwritten only if force is set; if
+// any exists, or a default cannot be read, nothing is written.
+func Export(dir string, force bool) error {
+	data := make(map[string][]byte)
+	var existing []string
+	for _, name := range Names() {
+		out := path.Join(dir, name)
+		if _, err := os.ReadFile(out); err == nil && !force {
+			existing = append(existing, out)
+		}
+		tmpl, err := Default(name)
+		if err != nil {
+			return err
+		}
+		data[name] = tmpl
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s: %w", strings.Join(existing, ", "), ErrExists)
+	}
+	for _, name := range Names() {
+		err := io.Write(path.Join(dir, name), data[name], 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/gianz74/mailconf/internal/config"
+	"github.com/gianz74/mailconf/internal/os"
+	"github.com/spf13/afero"
+)
+
+func setup(overrides map[string]string) {
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	memfs := &afero.Afero{
+		Fs: afero.NewMemMapFs(),
+	}
+	for name, data := range overrides {
+		memfs.WriteFile("/home/user/.config/mailconf/templates/"+name, []byte(data), 0644)
+	}
+	os.Set(memfs)
+	sources = []fs.FS{
+		fstest.MapFS{
+			"mbsyncrc.tpl":            {Data: []byte("default {{ .Name }}")},
+			"linux/mbsync.timer.tmpl": {Data: []byte("timer")},
+			"imapfilter/certificates": {Data: []byte("certs")},
+		},
+	}
+}
+
+func TestGet(t *testing.T) {
+	tt := []struct {
+		name      string
+		overrides map[string]string
+		template  string
+		want      string
+		err       error
+	}{
+		{
+			"default",
+			map[string]string{},
+			"mbsyncrc.tpl",
+			"default {{ .Name }}",
+			nil,
+		},
+		{
+			"override",
+			map[string]string{
+				"mbsyncrc.tpl": "override {{ .Name }}",
+			},
+			"mbsyncrc.tpl",
+			"override {{ .Name }}",
+			nil,
+		},
+		{
+			"nested override",
+			map[string]string{
+				"linux/mbsync.timer.tmpl": "my timer",
+			},
+			"linux/mbsync.timer.tmpl",
+			"my timer",
+			nil,
+		},
+		{
+			"unknown",
+			map[string]string{},
+			"unknown.tpl",
+			"",
+			ErrNotFound,
+		},
+	}
+	for _, tc := range tt {
+		setup(tc.overrides)
+		got, err := Get(tc.template)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("%s: got: %s, want: %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		name      string
+		overrides map[string]string
+		invalid   int
+	}{
+		{
+			"no overrides",
+			map[string]string{},
+			0,
+		},
+		{
+			"valid",
+			map[string]string{
+				"mbsyncrc.tpl": "{{ range .Profiles }}{{ normalize .Email }}{{ end }}",
+			},
+			0,
+		},
+		{
+			"invalid",
+			map[string]string{
+				"mbsyncrc.tpl":            "{{ range .Profiles }}",
+				"linux/mbsync.timer.tmpl": "{{ unknownfunc }}",
+			},
+			2,
+		},
+		{
+			"verbatim files are not parsed",
+			map[string]string{
+				"imapfilter/certificates": "{{",
+			},
+			0,
+		},
+	}
+	for _, tc := range tt {
+		setup(tc.overrides)
+		err := Validate()
+		if tc.invalid == 0 {
+			if err != nil {
+				t.Fatalf("%s: got error %v, want: <nil>", tc.name, err)
+			}
+			continue
+		}
+		var invalid InvalidError
+		if !errors.As(err, &invalid) {
+			t.Fatalf("%s: got error %v, want: InvalidError", tc.name, err)
+		}
+		if len(invalid) != tc.invalid {
+			t.Fatalf("%s: got %d invalid templates, want: %d", tc.name, len(invalid), tc.invalid)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	defer func(system string, getenv func(string) string) {
+		os.System, os.Getenv = system, getenv
+	}(os.System, os.Getenv)
+	os.System = "darwin"
+	os.UserConfigDir = func() (string, error) { return "/Users/jdoe/Library/Application Support", nil }
+	os.UserHomeDir = func() (string, error) { return "/Users/jdoe", nil }
+	tt := []struct {
+		name   string
+		xdgcfg string
+		want   string
+	}{
+		{"default", "", "/Users/jdoe/.config/mailconf/templates"},
+		{"xdg", "/Users/jdoe/xdg", "/Users/jdoe/xdg/mailconf/templates"},
+	}
+	for _, tc := range tt {
+		os.Getenv = func(string) string { return tc.xdgcfg }
+		got, err := Dir()
+		if err != nil || got != tc.want {
+			t.Fatalf("%s: got: %s (%v), want: %s", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	tt := []struct {
+		name      string
+		overrides map[string]string
+		force     bool
+		err       error
+	}{
+		{
+			"empty dir",
+			map[string]string{},
+			false,
+			nil,
+		},
+		{
+			"existing",
+			map[string]string{
+				"mbsyncrc.tpl": "override",
+			},
+			false,
+			ErrExists,
+		},
+		{
+			"force",
+			map[string]string{
+				"mbsyncrc.tpl": "override",
+			},
+			true,
+			nil,
+		},
+	}
+	for _, tc := range tt {
+		setup(tc.overrides)
+		dir, _ := Dir()
+		err := Export(dir, tc.force)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if tc.err != nil {
+			// nothing is exported if a file exists.
+			for _, name := range Names() {
+				if _, ok := tc.overrides[name]; ok {
+					continue
+				}
+				if _, ok := Override(name); ok {
+					t.Fatalf("%s: %s exported", tc.name, name)
+				}
+			}
+			continue
+		}
+		for _, name := range Names() {
+			got, ok := Override(name)
+			if !ok {
+				t.Fatalf("%s: %s not exported", tc.name, name)
+			}
+			want, _ := Default(name)
+			if string(got) != string(want) {
+				t.Fatalf("%s: got: %s, want: %s", tc.name, got, want)
+			}
+		}
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	tt := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"normalize", `{{ normalize "john.doe@gmail.com" }}`, `john_doe_gmail_com`},
+		{"elisp", `{{ elisp "John \"Jr\" O'Doe" }}`, `"John \"Jr\" O'Doe"`},
+		{"lua", "{{ lua \"a\\\\b\\n\" }}", `"a\\b\n"`},
+		{"json", `{{ json "imap.gmail.com" }} {{ json 993 }}`, `"imap.gmail.com" 993`},
+		{"shell safe", `{{ shell "user@gmail.com" }}`, `user@gmail.com`},
+		{"shell quoted", `{{ shell "it's me" }}`, `'it'\''s me'`},
+		{"join", `{{ join "/home/user" "Maildir" "Work" }}`, `/home/user/Maildir/Work`},
+		{"default empty", `{{ default "~/Maildir" "" }}`, `~/Maildir`},
+		{"default set", `{{ default 300 600 }}`, `600`},
+		{"timespan minutes", `{{ timespan 300 }}`, `5m`},
+		{"timespan hours", `{{ timespan 7200 }}`, `2h`},
+		{"timespan seconds", `{{ timespan 90 }}`, `90s`},
+		{"mutt", `{{ mutt "say \"hi\" to $USER" }}`, `"say \"hi\" to \$USER"`},
+		{"address", `{{ address "Doe, John" "jdoe@gmail.com" }}`, `"Doe, John" <jdoe@gmail.com>`},
+		{"mu safe", `{{ mu "/Work/INBOX" }}`, `/Work/INBOX`},
+		{"mu quoted", `{{ mu "/Work/Sent Items" }}`, `"/Work/Sent Items"`},
+	}
+	for _, tc := range tt {
+		tmpl, err := template.New(tc.name).Funcs(Funcs()).Parse(tc.text)
+		if err != nil {
+			t.Fatalf("%s: cannot parse: %v", tc.name, err)
+		}
+		got := &bytes.Buffer{}
+		err = tmpl.Execute(got, nil)
+		if err != nil {
+			t.Fatalf("%s: cannot execute: %v", tc.name, err)
+		}
+		if got.String() != tc.want {
+			t.Fatalf("%s: got: %s, want: %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPassCmd(t *testing.T) {
+	profile := &config.Profile{
+		ImapHost: "imap.gmail.com",
+		ImapPort: 993,
+		ImapUser: "user@gmail.com",
+		SmtpHost: "smtp.gmail.com",
+		SmtpPort: 587,
+		SmtpUser: "user@gmail.com",
+	}
+	tt := []struct {
+		system  string
+		service string
+		want    string
+	}{
+		{"linux", "imap", "secret-tool lookup user user@gmail.com host imap.gmail.com service imap port 993"},
+		{"linux", "smtp", "secret-tool lookup user user@gmail.com host smtp.gmail.com service smtp port 587"},
+		{"darwin", "imap", "security find-internet-password -a user@gmail.com -s imap.gmail.com -r imap -P 993 -w"},
+	}
+	for _, tc := range tt {
+		ctx := &Context{OS: tc.system}
+		got := ctx.PassCmd(tc.service, profile)
+		if got != tc.want {
+			t.Fatalf("%s (%s): got: %s, want: %s", tc.service, tc.system, got, tc.want)
+		}
+	}
+}
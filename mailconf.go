@@ -2,20 +2,29 @@ package mailconf
 
 import (
 	"bytes"
-	_ "embed"
+	"embed"
 	"errors"
 	"fmt"
-	"html/template"
+	"io/fs"
 	"path"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gianz74/mailconf/internal/certs"
+	"github.com/gianz74/mailconf/internal/client"
 	"github.com/gianz74/mailconf/internal/config"
 	"github.com/gianz74/mailconf/internal/cred"
+	"github.com/gianz74/mailconf/internal/filter"
+	"github.com/gianz74/mailconf/internal/frontend"
+	"github.com/gianz74/mailconf/internal/imap"
 	"github.com/gianz74/mailconf/internal/io"
 	"github.com/gianz74/mailconf/internal/myterm"
 	"github.com/gianz74/mailconf/internal/os"
 	"github.com/gianz74/mailconf/internal/service"
+	"github.com/gianz74/mailconf/internal/templates"
 )
 
 var (
@@ -27,29 +36,43 @@ var (
 	ErrMbsyncNotFound          = errors.New("Mbsync: Service not found")
 	ErrImapnotifyStatusUnknown = errors.New("Imapnotify: unknown status")
 	ErrImapnotifyNotFound      = errors.New("Imapnotify: Service not found")
+	ErrMaildirNested           = errors.New("Maildir root inside the current one")
+	ErrMaildirRelative         = errors.New("Maildir root not an absolute path")
+	ErrCertificateRejected     = errors.New("Certificate not trusted")
+
+	//go:embed templates
+	embedded embed.FS
 )
 
+func init() {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		panic(err)
+	}
+	templates.Register(sub)
+}
+
 func AddProfile(profile string, cfg *config.Config) error {
 
-	if isConfModified(cfg) {
-		t := myterm.New()
-		yes := t.YesNo("Configuration modified by an external program. Overwrite? [y/n]: ")
-		if !yes {
-			return ErrModified
-		}
+	if err := confirmModified(cfg); err != nil {
+		return err
 	}
+	before := indexed(cfg)
 
 	for _, p := range cfg.Profiles {
 		if profile == p.Name {
 			return ErrProfileExists
 		}
 	}
+	err := config.ValidateName(profile)
+	if err != nil {
+		return err
+	}
 	p := &config.Profile{
 		Name: profile,
 	}
 
 	t := myterm.New()
-	var err error
 	p.FullName, err = t.ReadLine("full user name: ")
 	if err != nil {
 		return err
@@ -70,7 +93,7 @@ func AddProfile(profile string, cfg *config.Config) error {
 		return err
 	}
 
-	port, err := strconv.ParseInt(line, 10, 16)
+	port, err := strconv.ParseUint(line, 10, 16)
 	if err != nil {
 		return err
 	}
@@ -85,23 +108,9 @@ func AddProfile(profile string, cfg *config.Config) error {
 		return fmt.Errorf("cannot read imap password.")
 	}
 	c := cred.New()
-	err = c.Add(p.ImapUser, "imap", p.ImapHost, p.ImapPort, string(pwd))
+	err = setPassword(c, "imap", p.ImapUser, p.ImapHost, p.ImapPort, pwd)
 	if err != nil {
-		prompt := fmt.Sprintf("credentials for imap://%s@%s:%d already exist.\ndo you want to provide a new password? [y/n]: ", p.ImapUser, p.ImapHost, p.ImapPort)
-		fmt.Printf("%s\n", prompt)
-		ans, err := t.ReadLine(prompt)
-		if err != nil {
-			return fmt.Errorf("cannot read answer.")
-		}
-		if len(ans) == 0 {
-			ans = "n"
-		}
-		if ans[0] == 'y' || ans[0] == 'Y' {
-			err = c.Update(p.ImapUser, "imap", p.ImapHost, p.ImapPort, string(pwd))
-			if err != nil {
-				return err
-			}
-		}
+		return err
 	}
 
 	p.SmtpHost, err = t.ReadLine("smtp host: ")
@@ -114,7 +123,7 @@ func AddProfile(profile string, cfg *config.Config) error {
 		return err
 	}
 
-	port, err = strconv.ParseInt(line, 10, 16)
+	port, err = strconv.ParseUint(line, 10, 16)
 	if err != nil {
 		return err
 	}
@@ -129,201 +138,1317 @@ func AddProfile(profile string, cfg *config.Config) error {
 		return fmt.Errorf("cannot read smtp password.")
 	}
 
-	err = c.Add(p.SmtpUser, "smtp", p.SmtpHost, p.SmtpPort, string(pwd))
+	err = setPassword(c, "smtp", p.SmtpUser, p.SmtpHost, p.SmtpPort, pwd)
 	if err != nil {
-		prompt := fmt.Sprintf("credentials for smtp://%s@%s:%d already exist.\ndo you want to provide a new password? [y/n]: ", p.SmtpUser, p.SmtpHost, p.SmtpPort)
-		ans, err := t.ReadLine(prompt)
-		if err != nil {
-			return fmt.Errorf("cannot read answer.")
-		}
-		if len(ans) == 0 {
-			ans = "n"
-		}
-		if ans[0] == 'y' || ans[0] == 'Y' {
-			err = c.Update(p.SmtpUser, "smtp", p.SmtpHost, p.SmtpPort, string(pwd))
-			if err != nil {
-				return err
-			}
-		}
+		return err
 	}
+	err = validate(cfg, p)
 	if err != nil {
 		return err
 	}
+	_, err = trustCertificate(p, cfg)
+	if errors.Is(err, ErrCertificateRejected) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot fetch the certificate of %s: %v\nrun \"mailconf check\" once the server can be reached.\n", p.ImapHost, err)
+	}
 	cfg.Profiles = append(cfg.Profiles, p)
 
 	err = Generate(cfg, p)
 	if err != nil {
 		return err
 	}
+	reindex(cfg, before)
 
 	return nil
 }
 
-func Generate(cfg *config.Config, profile *config.Profile) error {
-	err := generatemu4e(cfg, true)
+// setPassword stores pwd in the credentials store, asking before
+// replacing an existing password.
+func setPassword(c cred.CredentialsStore, service, user, host string, port uint16, pwd string) error {
+	err := c.Add(user, service, host, port, pwd)
+	if err == nil {
+		return nil
+	}
+	t := myterm.New()
+	prompt := fmt.Sprintf("credentials for %s://%s@%s:%d already exist.\ndo you want to provide a new password? [y/n]: ", service, user, host, port)
+	ans, err := t.ReadLine(prompt)
 	if err != nil {
+		return fmt.Errorf("cannot read answer.")
+	}
+	if len(ans) == 0 {
+		ans = "n"
+	}
+	if ans[0] == 'y' || ans[0] == 'Y' {
+		return c.Update(user, service, host, port, pwd)
+	}
+	return nil
+}
+
+// RenameProfile renames the profile called oldname to newname. The
+// imapnotify service of the profile is stopped, its maildir and
+// imapnotify config are moved to the new name, and the configuration
+// is generated again, which starts the service under the new name.
+func RenameProfile(oldname, newname string, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
 		return err
 	}
+	before := indexed(cfg)
 
-	mbsync := service.NewMbsync(cfg)
-	err = mbsync.GenConf(true)
-	t := myterm.New()
+	p, err := findProfile(oldname, newname, cfg)
 	if err != nil {
-		yes := t.YesNo("service file for mbsync already exists. Overwrite? [y/n]: ")
-		if yes {
-			mbsync.Stop()
-			mbsync.Disable()
-			err = mbsync.GenConf(true)
-			if err != nil {
-				return err
-			}
-		}
+		return err
 	}
 
-	status := mbsync.Status()
-
-	switch status {
-	case service.DisabledStopped:
-		mbsync.Enable()
-		mbsync.Start()
-	case service.DisabledRunning:
-		mbsync.Enable()
-	case service.EnabledStopped:
-		mbsync.Start()
-	case service.EnabledRunning:
-		break
-	case service.NotFound:
-		return ErrMbsyncNotFound
-	case service.Unknown:
-		return ErrMbsyncStatusUnknown
+	old := *p
+	imapnotify := service.NewImapnotify(cfg, &old)
+	imapnotify.Stop()
+	imapnotify.Disable()
+	mbsyncsvc := service.NewMbsyncProfile(cfg, &old)
+	mbsyncsvc.Stop()
+	mbsyncsvc.Disable()
+	err = mbsyncsvc.Remove()
+	if err != nil {
+		return err
 	}
 
-	imapnotify := service.NewImapnotify(cfg, profile)
-	err = imapnotify.GenConf(true)
+	cfgdir, err := os.UserConfigDir()
 	if err != nil {
-		yes := t.YesNo("imapnotify service file for " + profile.Name + " already exists. Overwrite? [y/n]: ")
-		if yes {
-			imapnotify.Stop()
-			imapnotify.Disable()
-			err = imapnotify.GenConf(true)
-			if err != nil {
-				return err
+		return err
+	}
+	dir := path.Join(cfgdir, "imapnotify")
+	err = io.Move(path.Join(dir, oldname), path.Join(dir, newname))
+	if err != nil {
+		return err
+	}
+	// a maildir set in the profile is not named after it.
+	if p.Maildir == "" {
+		renamed := *p
+		renamed.Name = newname
+		err = io.Move(cfg.MaildirPath(p), cfg.MaildirPath(&renamed))
+		if err != nil {
+			// keep syncing the profile under its old name.
+			rerr := io.Move(path.Join(dir, newname), path.Join(dir, oldname))
+			if rerr == nil {
+				rerr = Generate(cfg, p)
+			}
+			if rerr != nil {
+				fmt.Fprintf(os.Stderr, "Cannot restart the services: %v\n", rerr)
 			}
+			return err
 		}
 	}
-	status = imapnotify.Status()
-
-	switch status {
-	case service.DisabledStopped:
-		imapnotify.Enable()
-		imapnotify.Start()
-	case service.DisabledRunning:
-		imapnotify.Enable()
-	case service.EnabledStopped:
-		imapnotify.Start()
-	case service.EnabledRunning:
-		break
-	case service.NotFound:
-		return ErrImapnotifyNotFound
-	case service.Unknown:
-		return ErrImapnotifyStatusUnknown
+	// the files left behind, such as the launchd agent.
+	err = imapnotify.Remove()
+	if err != nil {
+		return err
 	}
 
+	cfg.Mu4e.RenameContext(oldname, newname)
+	p.Name = newname
+	err = Generate(cfg, p)
+	if err != nil {
+		return err
+	}
+	reindex(cfg, before)
 	return nil
 }
 
-//go:embed templates/mu4e.tpl
-var mu4e string
+// CloneProfile adds a profile called dst with the servers and folders
+// of src, asking for the identity and the credentials of the new
+// account.
+func CloneProfile(src, dst string, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+	before := indexed(cfg)
 
-func generatemu4e(cfg *config.Config, force bool) error {
-	tmpl, err := template.New("mu4e").Parse(mu4e)
+	from, err := findProfile(src, dst, cfg)
 	if err != nil {
 		return err
 	}
-	var mu4e = &bytes.Buffer{}
+	p := from.Copy()
+	p.Name = dst
+	// the aliases and the maildir belong to the account of src, and
+	// the clone is synced whether src is or not.
+	p.Identities = nil
+	p.Maildir = ""
+	p.Disabled = false
 
-	err = tmpl.Execute(mu4e, cfg.Profiles)
+	t := myterm.New()
+	p.FullName, err = readDefault(t, "full user name", from.FullName)
 	if err != nil {
 		return err
-
 	}
-	tmp, err := os.ReadFile(path.Join(cfg.EmacsCfgDir, "mu4e.el"))
-	if err == nil && !(reflect.DeepEqual(tmp, mu4e.Bytes()) || force) {
-		return ErrModified
+	p.Email, err = t.ReadLine("email address: ")
+	if err != nil {
+		return err
+	}
+	p.ImapUser, err = readDefault(t, "imap Username", p.Email)
+	if err != nil {
+		return err
+	}
+	pwd, err := t.ReadPass("imap Password: ")
+	if err != nil {
+		return fmt.Errorf("cannot read imap password.")
+	}
+	c := cred.New()
+	err = setPassword(c, "imap", p.ImapUser, p.ImapHost, p.ImapPort, pwd)
+	if err != nil {
+		return err
+	}
+	p.SmtpUser, err = readDefault(t, "smtp Username", p.ImapUser)
+	if err != nil {
+		return err
+	}
+	pwd, err = t.ReadPass("smtp Password: ")
+	if err != nil {
+		return fmt.Errorf("cannot read smtp password.")
+	}
+	err = setPassword(c, "smtp", p.SmtpUser, p.SmtpHost, p.SmtpPort, pwd)
+	if err != nil {
+		return err
 	}
-	io.Write(path.Join(cfg.EmacsCfgDir, "mu4e.el"), mu4e.Bytes(), 0644)
 
+	err = validate(cfg, p)
+	if err != nil {
+		return err
+	}
+	cfg.Profiles = append(cfg.Profiles, p)
+	err = Generate(cfg, p)
+	if err != nil {
+		return err
+	}
+	reindex(cfg, before)
 	return nil
 }
 
-func RmProfile(profile string, cfg *config.Config) error {
-	var p *config.Profile
-	modified := isConfModified(cfg)
-	if modified {
-		t := myterm.New()
-		if !t.YesNo("Configuration modified by an external program. Overwrite? [y/n]: ") {
-			return ErrModified
+// findProfile returns the profile called name, checking that newname
+// can be given to another profile.
+func findProfile(name, newname string, cfg *config.Config) (*config.Profile, error) {
+	var found *config.Profile
+	for _, p := range cfg.Profiles {
+		if p.Name == newname {
+			return nil, ErrProfileExists
+		}
+		if p.Name == name {
+			found = p
 		}
 	}
-	mbsync := service.NewMbsync(cfg)
-	err := mbsync.GenConf(true)
+	if found == nil {
+		return nil, ErrProfileNotFound
+	}
+	err := config.ValidateName(newname)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// readDefault reads a line, returning def if it is empty.
+func readDefault(t myterm.Terminal, prompt, def string) (string, error) {
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, def)
+	}
+	ans, err := t.ReadLine(prompt + ": ")
 	if err != nil {
+		return "", err
+	}
+	if ans == "" {
+		return def, nil
+	}
+	return ans, nil
+}
+
+// DisableProfile stops syncing the profile called name, keeping its
+// configuration, credentials and mu4e context. If readOnly is set,
+// the mu4e context of the profile cannot send mail.
+func DisableProfile(name string, readOnly bool, cfg *config.Config) error {
+	return setDisabled(name, true, readOnly, cfg)
+}
+
+// EnableProfile resumes syncing the profile called name.
+func EnableProfile(name string, cfg *config.Config) error {
+	return setDisabled(name, false, false, cfg)
+}
+
+func setDisabled(name string, disabled, readOnly bool, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
 		return err
 	}
 
-	for idx, tmp := range cfg.Profiles {
-		if profile == tmp.Name {
+	var p *config.Profile
+	for _, tmp := range cfg.Profiles {
+		if tmp.Name == name {
+			p = tmp
+		}
+	}
+	if p == nil {
+		return ErrProfileNotFound
+	}
+	p.Disabled = disabled
+	p.ReadOnly = readOnly
+
+	return Generate(cfg, p)
+}
+
+// EditProfile lists the mailboxes on the IMAP server of the profile
+// called name and asks which of them are synced besides its folders,
+// setting the profile includes to the chosen mailboxes. Includes with
+// wildcards are kept as they are.
+func EditProfile(name string, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	var p *config.Profile
+	for _, tmp := range cfg.Profiles {
+		if tmp.Name == name {
 			p = tmp
-			cfg.Profiles = append(cfg.Profiles[:idx], cfg.Profiles[idx+1:]...)
 		}
 	}
 	if p == nil {
 		return ErrProfileNotFound
 	}
-	imapnotifysvc := service.NewImapnotify(cfg, p)
-	imapnotifysvc.Stop()
-	imapnotifysvc.Disable()
-	err = imapnotifysvc.Remove()
+	pwd, err := cred.New().Get(p.ImapUser, "imap", p.ImapHost, p.ImapPort)
+	if err != nil {
+		return err
+	}
+	boxes, err := imap.List(&imap.Account{
+		Host:     p.ImapHost,
+		Port:     p.ImapPort,
+		SSL:      p.MbsyncOptions().SSL(),
+		User:     p.ImapUser,
+		Password: pwd,
+	})
 	if err != nil {
 		return err
 	}
 
-	generatemu4e(cfg, true)
+	synced := make(map[string]bool)
+	for _, f := range p.FolderMap() {
+		synced[f.Remote] = true
+	}
+	var include []string
+	selected := make(map[string]bool)
+	for _, box := range p.Include {
+		if strings.ContainsAny(box, "*%") {
+			include = append(include, box)
+			continue
+		}
+		selected[box] = true
+	}
+	var choices []string
+	for _, box := range boxes {
+		if synced[box] {
+			continue
+		}
+		choices = append(choices, box)
+		mark := " "
+		if selected[box] {
+			mark = "x"
+		}
+		fmt.Fprintf(os.Stdout, "%3d [%s] %s\n", len(choices), mark, box)
+	}
+	if len(choices) == 0 {
+		fmt.Fprintf(os.Stdout, "no mailboxes besides the folders of %s.\n", name)
+		return nil
+	}
 
-	if len(cfg.Profiles) == 0 {
-		mbsync.Stop()
-		mbsync.Disable()
-		err := mbsync.Remove()
+	t := myterm.New()
+	for {
+		ans, err := t.ReadLine("mailboxes to sync, by number (empty keeps the selection, - for none): ")
 		if err != nil {
 			return err
 		}
-		return nil
+		chosen, ok := pickMailboxes(ans, choices, selected)
+		if !ok {
+			fmt.Fprintf(os.Stdout, "enter numbers between 1 and %d, separated by spaces or commas.\n", len(choices))
+			continue
+		}
+		include = append(include, chosen...)
+		break
 	}
-	err = mbsync.GenConf(true)
+
+	edited := *p
+	edited.Include = include
+	if len(include) == 0 {
+		edited.Include = nil
+	}
+	tmp := *cfg
+	tmp.Profiles = nil
+	for _, other := range cfg.Profiles {
+		if other == p {
+			other = &edited
+		}
+		tmp.Profiles = append(tmp.Profiles, other)
+	}
+	err = tmp.Validate()
+	if err != nil {
+		return err
+	}
+	p.Include = edited.Include
+	return Generate(cfg, p)
+}
+
+// pickMailboxes returns the choices whose numbers are in ans, or those
+// already selected if ans is empty.
+func pickMailboxes(ans string, choices []string, selected map[string]bool) ([]string, bool) {
+	var picked []string
+	ans = strings.TrimSpace(ans)
+	switch ans {
+	case "":
+		for _, box := range choices {
+			if selected[box] {
+				picked = append(picked, box)
+			}
+		}
+		return picked, true
+	case "-":
+		return nil, true
+	}
+	seen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(ans, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(choices) {
+			return nil, false
+		}
+		if !seen[n] {
+			seen[n] = true
+			picked = append(picked, choices[n-1])
+		}
+	}
+	return picked, true
+}
+
+// CheckImport checks that profiles can be added to cfg by
+// ImportProfiles, without changing anything. A profile with the same
+// name as an existing one is an error, ErrProfileExists, unless force
+// is set.
+func CheckImport(profiles []*config.Profile, cfg *config.Config, force bool) error {
+	_, err := imported(profiles, cfg, force)
+	return err
+}
+
+// imported returns the profiles of cfg once profiles are imported.
+func imported(profiles []*config.Profile, cfg *config.Config, force bool) ([]*config.Profile, error) {
+	err := config.CheckActive()
+	if err != nil {
+		return nil, err
+	}
+	result := append([]*config.Profile{}, cfg.Profiles...)
+	seen := make(map[string]bool)
+	for _, p := range profiles {
+		err := config.ValidateName(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: %w", p.Name, ErrProfileExists)
+		}
+		seen[p.Name] = true
+		idx := -1
+		for i, old := range result {
+			if p.Name == old.Name {
+				idx = i
+			}
+		}
+		if idx >= 0 && !force {
+			return nil, fmt.Errorf("%s: %w", p.Name, ErrProfileExists)
+		}
+		if idx >= 0 {
+			result[idx] = p
+		} else {
+			result = append(result, p)
+		}
+	}
+	tmp := *cfg
+	tmp.Profiles = result
+	err = tmp.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportProfiles adds profiles, read from a bundle or from existing
+// configs, to cfg and generates their configuration. The profiles are
+// checked as by CheckImport before any is added; if generating the
+// configuration fails, cfg is left as it was and its configuration
+// generated again.
+func ImportProfiles(profiles []*config.Profile, cfg *config.Config, force bool) error {
+	result, err := imported(profiles, cfg, force)
 	if err != nil {
 		return err
 	}
+	before := indexed(cfg)
+	saved := cfg.Profiles
+	cfg.Profiles = result
+	for _, p := range profiles {
+		err = Generate(cfg, p)
+		if err != nil {
+			rerr := rollback(cfg, saved, profiles)
+			cfg.Profiles = saved
+			if rerr == nil {
+				rerr = regenerate(cfg)
+			}
+			if rerr != nil {
+				fmt.Fprintf(os.Stderr, "Cannot restore the configuration: %v\n", rerr)
+			}
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+	}
+	reindex(cfg, before)
+	return nil
+}
 
+// rollback removes the services of the imported profiles that were
+// not among the profiles saved before the import.
+func rollback(cfg *config.Config, saved, profiles []*config.Profile) error {
+	names := make(map[string]bool)
+	for _, p := range saved {
+		names[p.Name] = true
+	}
+	for _, p := range profiles {
+		if names[p.Name] {
+			continue
+		}
+		err := removeServices(cfg, p)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func isConfModified(cfg *config.Config) bool {
-	err := generatemu4e(cfg, false)
+// removeServices stops and removes the imapnotify and mbsync services
+// of the profile p of cfg.
+func removeServices(cfg *config.Config, p *config.Profile) error {
+	imapnotify := service.NewImapnotify(cfg, p)
+	imapnotify.Stop()
+	imapnotify.Disable()
+	err := imapnotify.Remove()
 	if err != nil {
-		return true
+		return err
 	}
-	mbsync := service.NewMbsync(cfg)
-	err = mbsync.GenConf(false)
+	mbsyncsvc := service.NewMbsyncProfile(cfg, p)
+	mbsyncsvc.Stop()
+	mbsyncsvc.Disable()
+	return mbsyncsvc.Remove()
+}
+
+// reindex creates the index database of the frontend again after the
+// profiles changed, if the maildir root or the addresses it is created
+// with changed from before, as returned by indexed; indexing all the
+// mail takes long, so if they did not, it only tells the user how to
+// rebuild the index. It only warns on failure, because mu cannot open
+// the database while mu4e is running, and the user can rebuild it
+// later with "mailconf index -rebuild".
+func reindex(cfg *config.Config, before []string) {
+	if reflect.DeepEqual(indexed(cfg), before) {
+		fmt.Fprintf(os.Stdout, "run \"mailconf index -rebuild\" to update the mail index.\n")
+		return
+	}
+	err := frontend.New(cfg).Init()
 	if err != nil {
-		return true
+		fmt.Fprintf(os.Stderr, "Cannot update the mail index: %v\nrun \"mailconf index -rebuild\" once mu4e is closed.\n", err)
 	}
+}
 
+// indexed returns the maildir root of cfg followed by the sorted
+// addresses of the profiles and of their identities, which the index
+// is created with.
+func indexed(cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	var addrs []string
 	for _, p := range cfg.Profiles {
-		imapnotify := service.NewImapnotify(cfg, p)
+		for _, addr := range p.Addresses() {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	sort.Strings(addrs)
+	return append([]string{cfg.MaildirRootPath()}, addrs...)
+}
+
+// Index adds the new mail to the index of the frontend, or creates the
+// index again from scratch if rebuild is set.
+func Index(rebuild bool, cfg *config.Config) error {
+	// the index, as the generated files, is in the home directory.
+	err := config.CheckAttached()
+	if err != nil {
+		return err
+	}
+	fe := frontend.New(cfg)
+	if rebuild {
+		return fe.Init()
+	}
+	return fe.Index()
+}
+
+// TryFilters runs the imapfilter rules of the profile called name for
+// mailbox on the messages of sample, a maildir or an mbox file, and
+// prints the rules every message matches with their actions. No mail
+// is touched.
+func TryFilters(name, mailbox, sample string, cfg *config.Config) error {
+	var p *config.Profile
+	for _, tmp := range cfg.Profiles {
+		if tmp.Name == name {
+			p = tmp
+		}
+	}
+	if p == nil {
+		return ErrProfileNotFound
+	}
+	msgs, err := filter.Read(sample)
+	if err != nil {
+		return err
+	}
+	for _, res := range filter.Run(p.Rules, mailbox, msgs, time.Now()) {
+		msg := res.Message
+		fmt.Fprintf(os.Stdout, "%s: %q from %s\n", msg.Name, msg.Header.Get("Subject"), msg.Header.Get("From"))
+		if len(res.Rules) == 0 {
+			fmt.Fprintf(os.Stdout, "\tno rule matches\n")
+		}
+		for _, r := range res.Rules {
+			var actions []string
+			for _, a := range r.Actions {
+				actions = append(actions, a.String())
+			}
+			fmt.Fprintf(os.Stdout, "\t%s: %s\n", r.Name, strings.Join(actions, ", "))
+		}
+	}
+	return nil
+}
+
+// MoveMaildir moves the mail of all the profiles to the maildir root
+// newroot, an absolute path or one starting with ~, which must not
+// exist yet. Syncing is stopped, so that no mail is written during the
+// move, and the whole maildir root is moved at once, so that the mail
+// is either all in the old root or all in the new one; the maildirs
+// set in the profiles are moved along with it. On another file system
+// the root is copied and checked before the old one is removed; if the
+// move fails, the configuration is left unchanged and syncing the old
+// root restarted. The configuration is then generated again,
+// restarting the services, and the mail indexed from scratch.
+func MoveMaildir(newroot string, cfg *config.Config) error {
+	root := path.Clean(os.ExpandUser(newroot))
+	if !path.IsAbs(root) {
+		return fmt.Errorf("%s: %w", newroot, ErrMaildirRelative)
+	}
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+	before := indexed(cfg)
+
+	oldroot := cfg.MaildirRootPath()
+	tmp := *cfg
+	tmp.MaildirRoot = root
+	if tmp.MaildirRoot == (&config.Config{}).MaildirRootPath() {
+		tmp.MaildirRoot = ""
+	}
+	tmp.Profiles = nil
+	for _, p := range cfg.Profiles {
+		cp := *p
+		if strings.HasPrefix(p.Maildir, oldroot+"/") {
+			cp.Maildir = path.Join(tmp.MaildirRootPath(), strings.TrimPrefix(p.Maildir, oldroot+"/"))
+		}
+		tmp.Profiles = append(tmp.Profiles, &cp)
+	}
+	err := tmp.Validate()
+	if err != nil {
+		return err
+	}
+	dst := tmp.MaildirRootPath()
+	if dst == oldroot {
+		return nil
+	}
+	if strings.HasPrefix(dst, oldroot+"/") {
+		return ErrMaildirNested
+	}
+
+	service.NewMbsync(cfg).Stop()
+	for _, p := range cfg.Profiles {
+		service.NewMbsyncProfile(cfg, p).Stop()
+		service.NewImapnotify(cfg, p).Stop()
+	}
+	err = io.Move(oldroot, dst)
+	if err != nil {
+		// start syncing the old root again.
+		if rerr := regenerate(cfg); rerr != nil {
+			fmt.Fprintf(os.Stderr, "Cannot restart the services: %v\n", rerr)
+		}
+		return err
+	}
+
+	cfg.MaildirRoot = tmp.MaildirRoot
+	for i, p := range tmp.Profiles {
+		cfg.Profiles[i].Maildir = p.Maildir
+	}
+	err = regenerate(cfg)
+	if err != nil {
+		return err
+	}
+	reindex(cfg, before)
+	return nil
+}
+
+// ActivateSet makes cfg, the configuration selected with
+// config.SetLocation, the active one. The services of the profiles of
+// the previously active configuration missing from cfg are stopped
+// and removed, the files of all the profiles of cfg are generated
+// again and the mail indexed from scratch.
+func ActivateSet(cfg *config.Config) error {
+	err := config.CheckAttached()
+	if err != nil {
+		return err
+	}
+	old, err := config.ReadActive()
+	if err != nil && !errors.Is(err, config.ErrNotFound) {
+		return err
+	}
+	var before []string
+	if old != nil {
+		before = indexed(old)
+	}
+	if old != nil {
+		names := make(map[string]bool)
+		for _, p := range cfg.Profiles {
+			names[p.Name] = true
+		}
+		for _, p := range old.Profiles {
+			if names[p.Name] {
+				continue
+			}
+			err = removeServices(old, p)
+			if err != nil {
+				return err
+			}
+		}
+		if len(cfg.Profiles) == 0 && len(old.Profiles) > 0 {
+			mbsync := service.NewMbsync(old)
+			mbsync.Stop()
+			mbsync.Disable()
+			err = mbsync.Remove()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = config.Activate()
+	if err != nil {
+		return err
+	}
+	err = regenerate(cfg)
+	if err != nil {
+		return err
+	}
+	reindex(cfg, before)
+	return nil
+}
+
+// regenerate generates the configuration of all the profiles again.
+func regenerate(cfg *config.Config) error {
+	if len(cfg.Profiles) == 0 {
+		err := frontend.New(cfg).GenConf(true)
+		if err != nil {
+			return err
+		}
+		return client.Generate(cfg, true)
+	}
+	for _, p := range cfg.Profiles {
+		err := Generate(cfg, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks cfg as it would be with p added.
+func validate(cfg *config.Config, p *config.Profile) error {
+	tmp := *cfg
+	tmp.Profiles = append(append([]*config.Profile{}, cfg.Profiles...), p)
+	return tmp.Validate()
+}
+
+func Generate(cfg *config.Config, profile *config.Profile) error {
+	err := frontend.New(cfg).GenConf(true)
+	if err != nil {
+		return err
+	}
+	err = generatemsmtp(cfg, true)
+	if err != nil {
+		return err
+	}
+	err = client.Generate(cfg, true)
+	if err != nil {
+		return err
+	}
+
+	mbsync := service.NewMbsync(cfg)
+	err = mbsync.GenConf(true)
+	t := myterm.New()
+	if err != nil {
+		yes := t.YesNo("service file for mbsync already exists. Overwrite? [y/n]: ")
+		if yes {
+			mbsync.Stop()
+			mbsync.Disable()
+			err = mbsync.GenConf(true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if cfg.SharedSchedule() {
+		switch mbsync.Status() {
+		case service.DisabledStopped:
+			mbsync.Enable()
+			mbsync.Start()
+		case service.DisabledRunning:
+			mbsync.Enable()
+		case service.EnabledStopped:
+			mbsync.Start()
+		case service.EnabledRunning:
+			break
+		case service.NotFound:
+			return ErrMbsyncNotFound
+		case service.Unknown:
+			return ErrMbsyncStatusUnknown
+		}
+	} else {
+		mbsync.Stop()
+		mbsync.Disable()
+	}
+
+	err = schedule(cfg)
+	if err != nil {
+		return err
+	}
+
+	imapnotify := service.NewImapnotify(cfg, profile)
+	err = imapnotify.GenConf(true)
+	if err != nil {
+		yes := t.YesNo("imapnotify service file for " + profile.Name + " already exists. Overwrite? [y/n]: ")
+		if yes {
+			imapnotify.Stop()
+			imapnotify.Disable()
+			err = imapnotify.GenConf(true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if profile.Disabled {
+		imapnotify.Stop()
+		imapnotify.Disable()
+		return nil
+	}
+	switch imapnotify.Status() {
+	case service.DisabledStopped:
+		imapnotify.Enable()
+		imapnotify.Start()
+	case service.DisabledRunning:
+		imapnotify.Enable()
+	case service.EnabledStopped:
+		imapnotify.Start()
+	case service.EnabledRunning:
+		break
+	case service.NotFound:
+		return ErrImapnotifyNotFound
+	case service.Unknown:
+		return ErrImapnotifyStatusUnknown
+	}
+
+	return nil
+}
+
+// schedule gives the profiles synced on their own an mbsync service of
+// their own, and removes it from the other profiles.
+func schedule(cfg *config.Config) error {
+	for _, p := range cfg.Profiles {
+		svc := service.NewMbsyncProfile(cfg, p)
+		if !cfg.OwnSchedule(p) {
+			svc.Stop()
+			svc.Disable()
+			err := svc.Remove()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := svc.GenConf(true)
+		if err != nil {
+			return err
+		}
+		svc.Stop()
+		svc.Enable()
+		svc.Start()
+	}
+	return nil
+}
+
+// SetSyncInterval sets the sync interval, in seconds, of the profile
+// called name, or the shared one if name is empty; zero restores the
+// default. The mu4e config and the mbsync services are generated again
+// with the new interval.
+func SetSyncInterval(name string, seconds int, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	tmp := *cfg
+	if name == "" {
+		tmp.SyncInterval = seconds
+	} else {
+		tmp.Profiles = append([]*config.Profile{}, cfg.Profiles...)
+		found := false
+		for i, p := range tmp.Profiles {
+			if p.Name == name {
+				cp := *p
+				cp.SyncInterval = seconds
+				tmp.Profiles[i] = &cp
+				found = true
+			}
+		}
+		if !found {
+			return ErrProfileNotFound
+		}
+	}
+	err := tmp.Validate()
+	if err != nil {
+		return err
+	}
+	cfg.SyncInterval = tmp.SyncInterval
+	for i, p := range tmp.Profiles {
+		cfg.Profiles[i].SyncInterval = p.SyncInterval
+	}
+
+	return reschedule(cfg)
+}
+
+// SetPerProfileSync turns on or off the per-profile sync mode, where
+// every profile is synced by its own mbsync service.
+func SetPerProfileSync(on bool, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	cfg.PerProfileSync = on
+	return reschedule(cfg)
+}
+
+// reschedule generates the mu4e config and the mbsync services again
+// after a change to the schedule, and restarts them.
+func reschedule(cfg *config.Config) error {
+	err := frontend.New(cfg).GenConf(true)
+	if err != nil {
+		return err
+	}
+	mbsync := service.NewMbsync(cfg)
+	err = mbsync.GenConf(true)
+	if err != nil {
+		return err
+	}
+	mbsync.Stop()
+	if cfg.SharedSchedule() {
+		mbsync.Enable()
+		mbsync.Start()
+	} else {
+		mbsync.Disable()
+	}
+
+	return schedule(cfg)
+}
+
+// msmtpConfig returns the path of the msmtp config.
+func msmtpConfig() (string, error) {
+	cfgdir, err := os.XDGConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cfgdir, "msmtp/config"), nil
+}
+
+// msmtpHeader starts the msmtp configs written by mailconf.
+const msmtpHeader = "# msmtp config generated by mailconf"
+
+// errHandWritten is returned by generatemsmtp, unless force is set, if
+// the msmtp config was not written by mailconf.
+var errHandWritten = errors.New("msmtp config not written by mailconf")
+
+// generatemsmtp writes the msmtp config, with an account for every
+// profile that can send mail, so that any program can send mail
+// through msmtp. Unless force is set, it returns ErrModified if the
+// config differs from the one generated for cfg, or errHandWritten if
+// it was not written by mailconf. A config not written by mailconf is
+// replaced only if the user agrees, keeping a copy.
+func generatemsmtp(cfg *config.Config, force bool) error {
+	ctx, err := templates.NewContext(cfg, nil)
+	if err != nil {
+		return err
+	}
+	msmtp, err := templates.Execute("msmtp.tpl", ctx)
+	if err != nil {
+		return err
+	}
+	file, err := msmtpConfig()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.ReadFile(file)
+	if err == nil && !reflect.DeepEqual(tmp, msmtp) {
+		if !force && !bytes.HasPrefix(tmp, []byte(msmtpHeader)) {
+			return errHandWritten
+		}
+		if !force {
+			return ErrModified
+		}
+		if !bytes.HasPrefix(tmp, []byte(msmtpHeader)) {
+			t := myterm.New()
+			if !t.YesNo(fmt.Sprintf("%s was not written by mailconf. Replace it, keeping a copy in %s.pre-mailconf? [y/n]: ", file, file)) {
+				fmt.Fprintf(os.Stdout, "%s left unchanged: add the accounts to it by hand.\n", file)
+				return nil
+			}
+			err = io.Backup(file)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return io.Write(file, msmtp, 0600)
+}
+
+// SetUseMsmtp makes mu4e send mail with msmtp if on is set, or with
+// smtpmail otherwise.
+func SetUseMsmtp(on bool, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	cfg.UseMsmtp = on
+	err := generatemsmtp(cfg, true)
+	if err != nil {
+		return err
+	}
+	return frontend.New(cfg).GenConf(true)
+}
+
+// SetFrontend selects the program indexing the mail, "mu4e" or
+// "notmuch": the configuration of the previous one is removed and the
+// one of the new one generated.
+func SetFrontend(name string, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	tmp := *cfg
+	tmp.Frontend = name
+	err := tmp.Validate()
+	if err != nil {
+		return err
+	}
+	err = frontend.New(cfg).Remove()
+	if err != nil {
+		return err
+	}
+	cfg.Frontend = name
+	return frontend.New(cfg).GenConf(true)
+}
+
+// SetClients selects the mail clients, other than mu4e, configured
+// for the profiles: the configuration of the clients left out is
+// removed.
+func SetClients(names []string, cfg *config.Config) error {
+	if err := confirmModified(cfg); err != nil {
+		return err
+	}
+
+	tmp := *cfg
+	tmp.Clients = names
+	err := tmp.Validate()
+	if err != nil {
+		return err
+	}
+	for _, name := range cfg.Clients {
+		c := client.New(name, cfg)
+		if c == nil || contains(names, name) {
+			continue
+		}
+		err = c.Remove()
+		if err != nil {
+			return err
+		}
+	}
+	cfg.Clients = names
+	return client.Generate(cfg, true)
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+func RmProfile(profile string, cfg *config.Config) error {
+	var p *config.Profile
+	err := confirmModified(cfg)
+	if err != nil {
+		return err
+	}
+	before := indexed(cfg)
+	mbsync := service.NewMbsync(cfg)
+	err = mbsync.GenConf(true)
+	if err != nil {
+		return err
+	}
+
+	for idx, tmp := range cfg.Profiles {
+		if profile == tmp.Name {
+			p = tmp
+			cfg.Profiles = append(cfg.Profiles[:idx], cfg.Profiles[idx+1:]...)
+		}
+	}
+	if p == nil {
+		return ErrProfileNotFound
+	}
+	cfg.Mu4e.SetContextKey(p.Name, "")
+	err = forgetCertificate(p, cfg)
+	if err != nil {
+		return err
+	}
+	err = removeServices(cfg, p)
+	if err != nil {
+		return err
+	}
+
+	err = frontend.New(cfg).GenConf(true)
+	if err != nil {
+		return err
+	}
+	reindex(cfg, before)
+
+	if len(cfg.Profiles) == 0 {
+		mbsync.Stop()
+		mbsync.Disable()
+		err := mbsync.Remove()
+		if err != nil {
+			return err
+		}
+		msmtp, err := msmtpConfig()
+		if err != nil {
+			return err
+		}
+		return io.Remove(msmtp)
+	}
+	err = generatemsmtp(cfg, true)
+	if err != nil {
+		return err
+	}
+	err = client.Generate(cfg, true)
+	if err != nil {
+		return err
+	}
+	err = mbsync.GenConf(true)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Check fetches the certificates of the IMAP servers of the profiles
+// and asks whether to trust those missing from the certificates file
+// of imapfilter or changed since they were trusted. The certificates
+// of servers no profile uses are removed from the file.
+func Check(cfg *config.Config) error {
+	err := config.CheckActive()
+	if err != nil {
+		return err
+	}
+	var first error
+	checked := make(map[string]bool)
+	for _, p := range cfg.Profiles {
+		server := certs.Server(p.ImapHost, p.ImapPort)
+		if checked[server] || p.MbsyncOptions().SSL() == "None" {
+			continue
+		}
+		checked[server] = true
+		trusted, err := trustCertificate(p, cfg)
+		switch {
+		case errors.Is(err, ErrCertificateRejected):
+			fmt.Fprintf(os.Stdout, "%s: certificate not trusted, imapfilter will refuse the server.\n", server)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Cannot fetch the certificate of %s: %v\n", server, err)
+			if first == nil {
+				first = err
+			}
+		case trusted:
+			fmt.Fprintf(os.Stdout, "%s: certificate trusted.\n", server)
+		default:
+			fmt.Fprintf(os.Stdout, "%s: certificate unchanged.\n", server)
+		}
+	}
+
+	stored, err := certs.Read()
+	if err != nil {
+		return err
+	}
+	used := imapServers(cfg)
+	var left []*certs.Entry
+	for _, e := range stored {
+		// the certificates imapfilter stored itself are left alone.
+		if e.Server != "" && !contains(used, e.Server) {
+			fmt.Fprintf(os.Stdout, "%s: removing the certificate, no profile uses the server.\n", e.Server)
+			continue
+		}
+		left = append(left, e)
+	}
+	if len(left) != len(stored) {
+		err = certs.Write(left)
+		if err != nil {
+			return err
+		}
+	}
+	return first
+}
+
+// trustCertificate fetches the certificate of the IMAP server of p and,
+// unless the certificates file of imapfilter holds it already for the
+// server, shows it and asks whether to trust it, replacing the one
+// stored for the server. It reports whether the certificate was added.
+func trustCertificate(p *config.Profile, cfg *config.Config) (bool, error) {
+	if p.MbsyncOptions().SSL() == "None" {
+		return false, nil
+	}
+	cert, err := imap.Certificate(&imap.Account{
+		Host: p.ImapHost,
+		Port: p.ImapPort,
+		SSL:  p.MbsyncOptions().SSL(),
+	})
+	if err != nil {
+		return false, err
+	}
+	stored, err := certs.Read()
+	if err != nil {
+		return false, err
+	}
+	server := certs.Server(p.ImapHost, p.ImapPort)
+	old := certs.For(stored, server)
+	for _, e := range old {
+		if e.Cert.Equal(cert) {
+			return false, nil
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "certificate of %s:\n", server)
+	fmt.Fprintf(os.Stdout, "\tsubject:     %s\n", cert.Subject)
+	fmt.Fprintf(os.Stdout, "\tissuer:      %s\n", cert.Issuer)
+	fmt.Fprintf(os.Stdout, "\tvalid until: %s\n", cert.NotAfter.Format("2006-01-02"))
+	fmt.Fprintf(os.Stdout, "\tSHA-256:     %s\n", certs.Fingerprint(cert))
+	for _, e := range old {
+		fmt.Fprintf(os.Stdout, "it replaces the certificate trusted so far, SHA-256 %s.\n", certs.Fingerprint(e.Cert))
+	}
+	t := myterm.New()
+	if !t.YesNo("Trust this certificate? [y/n]: ") {
+		return false, ErrCertificateRejected
+	}
+	stored = certs.Remove(stored, server)
+	return true, certs.Write(append(stored, &certs.Entry{Server: server, Cert: cert}))
+}
+
+// forgetCertificate removes the certificate of the IMAP server of p,
+// which has been removed from cfg, unless other profiles use the
+// server.
+func forgetCertificate(p *config.Profile, cfg *config.Config) error {
+	server := certs.Server(p.ImapHost, p.ImapPort)
+	if contains(imapServers(cfg), server) {
+		return nil
+	}
+	stored, err := certs.Read()
+	if err != nil {
+		return err
+	}
+	left := certs.Remove(stored, server)
+	if len(left) == len(stored) {
+		return nil
+	}
+	return certs.Write(left)
+}
+
+// imapServers returns the IMAP servers of the profiles of cfg, as
+// named in the certificates file.
+func imapServers(cfg *config.Config) []string {
+	var servers []string
+	for _, p := range cfg.Profiles {
+		server := certs.Server(p.ImapHost, p.ImapPort)
+		if !contains(servers, server) {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// confirmModified fails with config.ErrInactive if cfg is not the
+// active configuration, and asks whether to overwrite the generated
+// files if they were modified by another program, failing with
+// ErrModified if not.
+func confirmModified(cfg *config.Config) error {
+	err := config.CheckActive()
+	if err != nil {
+		return err
+	}
+	if !isConfModified(cfg) {
+		return nil
+	}
+	t := myterm.New()
+	if !t.YesNo("Configuration modified by an external program. Overwrite? [y/n]: ") {
+		return ErrModified
+	}
+	return nil
+}
+
+func isConfModified(cfg *config.Config) bool {
+	err := frontend.New(cfg).GenConf(false)
+	if err != nil {
+		return true
+	}
+	err = generatemsmtp(cfg, false)
+	// replacing a msmtp config not written by mailconf is asked when
+	// generating it, not twice.
+	if err != nil && err != errHandWritten {
+		return true
+	}
+	err = client.Generate(cfg, false)
+	if err != nil {
+		return true
+	}
+	mbsync := service.NewMbsync(cfg)
+	err = mbsync.GenConf(false)
+	if err != nil {
+		return true
+	}
+
+	for _, p := range cfg.Profiles {
+		imapnotify := service.NewImapnotify(cfg, p)
 		err := imapnotify.GenConf(false)
 		if err != nil {
 			return true
 		}
+		if cfg.OwnSchedule(p) {
+			err = service.NewMbsyncProfile(cfg, p).GenConf(false)
+			if err != nil {
+				return true
+			}
+		}
 	}
 	return false
 }
@@ -1,19 +1,36 @@
 package mailconf
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"math/big"
 	"path"
 	"reflect"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/gianz74/mailconf/internal/certs"
 	"github.com/gianz74/mailconf/internal/config"
 	"github.com/gianz74/mailconf/internal/cred"
 	"github.com/gianz74/mailconf/internal/cred/memcred"
+	"github.com/gianz74/mailconf/internal/exec"
+	"github.com/gianz74/mailconf/internal/exec/memexec"
+	"github.com/gianz74/mailconf/internal/imap"
+	"github.com/gianz74/mailconf/internal/imap/memimap"
 	"github.com/gianz74/mailconf/internal/myterm"
 	"github.com/gianz74/mailconf/internal/myterm/memterm"
 	"github.com/gianz74/mailconf/internal/os"
+	"github.com/gianz74/mailconf/internal/service"
 	"github.com/gianz74/mailconf/internal/service/mockservice"
 	"github.com/spf13/afero"
 )
@@ -22,8 +39,12 @@ var (
 	oldFs         os.FsAccess
 	oldCredStore  cred.CredentialsStore
 	oldTerm       myterm.Terminal
+	oldExec       exec.Runner
+	oldImap       imap.Client
 	mockTerm      = memterm.New()
 	mockCredStore = memcred.New()
+	mockExec      = memexec.New()
+	mockImap      = memimap.New()
 )
 
 func setup() {
@@ -33,23 +54,21 @@ func setup() {
 		Fs: afero.NewMemMapFs(),
 	})
 	mockservice.SetupMockServices()
+	mockExec.Reset()
+	oldExec = exec.Set(mockExec)
+	mockImap.Reset()
+	oldImap = imap.Set(mockImap)
 }
 
 func restore() {
+	imap.Set(oldImap)
+	exec.Set(oldExec)
 	cred.SetStore(oldCredStore)
 	myterm.SetTerm(oldTerm)
 	os.Set(oldFs)
 	mockservice.RestoreServices()
 }
 
-func fixture(path string) []byte {
-	b, err := ioutil.ReadFile("testdata/fixtures" + path)
-	if err != nil {
-		panic(err)
-	}
-	return b
-}
-
 func TestAddProfile(t *testing.T) {
 	tt := []struct {
 		name     string
@@ -170,46 +189,1092 @@ func TestAddProfile(t *testing.T) {
 	}
 }
 
-func TestGeneratemu4e(t *testing.T) {
+func work() *config.Profile {
+	return &config.Profile{
+		Name:     "Work",
+		FullName: "John Doe",
+		Email:    "jdoe@gmail.com",
+		ImapHost: "imap.gmail.com",
+		ImapPort: 993,
+		ImapUser: "user@gmail.com",
+		SmtpHost: "smtp.gmail.com",
+		SmtpPort: 587,
+		SmtpUser: "user@gmail.com",
+	}
+}
+
+func TestRenameProfile(t *testing.T) {
+	tt := []struct {
+		name    string
+		oldname string
+		newname string
+		want    []string
+		err     error
+	}{
+		{
+			"rename",
+			"Work",
+			"Office",
+			[]string{"Office", "Home"},
+			nil,
+		},
+		{
+			"missing",
+			"Job",
+			"Office",
+			[]string{"Work", "Home"},
+			ErrProfileNotFound,
+		},
+		{
+			"exists",
+			"Work",
+			"Home",
+			[]string{"Work", "Home"},
+			ErrProfileExists,
+		},
+	}
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		home := work()
+		home.Name, home.Email = "Home", "jdoe@home.org"
+		cfg := &config.Config{
+			EmacsCfgDir: "/home/user/.emacs.d",
+			Profiles:    []*config.Profile{work(), home},
+			Mu4e:        &config.Mu4e{ContextKeys: map[string]string{"Work": "C-c w"}},
+		}
+		err := RenameProfile(tc.oldname, tc.newname, cfg)
+		if err != tc.err {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		var got []string
+		for _, p := range cfg.Profiles {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got profiles %v, want: %v", tc.name, got, tc.want)
+		}
+		if tc.err != nil {
+			continue
+		}
+		if _, err := os.ReadFile("/home/user/.config/imapnotify/Office/notify.conf"); err != nil {
+			t.Fatalf("%s: imapnotify config not generated: %v", tc.name, err)
+		}
+		mbsyncrc, _ := os.ReadFile("/home/user/.mbsyncrc")
+		if !strings.Contains(string(mbsyncrc), "Channel Office-inbox") || strings.Contains(string(mbsyncrc), "Work") {
+			t.Fatalf("%s: mbsyncrc not regenerated:\n%s", tc.name, mbsyncrc)
+		}
+		if got := cfg.Mu4e.ContextKeys; !reflect.DeepEqual(got, map[string]string{"Office": "C-c w"}) {
+			t.Fatalf("%s: got context keys %v, want the key of Work moved to Office", tc.name, got)
+		}
+		// the addresses did not change, so the index is not rebuilt.
+		if got := mockExec.Ran(); len(got) != 0 {
+			t.Fatalf("%s: got commands %q, want none", tc.name, got)
+		}
+	}
+}
+
+func TestCloneProfile(t *testing.T) {
+	setup()
+	defer restore()
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	src := work()
+	src.Folders = []*config.Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+	}
+	src.Identities = []*config.Identity{{Email: "john@example.com"}}
+	src.Maildir = "/home/user/Maildir/john"
+	src.Disabled = true
+	src.Include = []string{"Projects/*"}
+	src.Mbsync = &config.Mbsync{MaxMessages: 1000}
+	src.Rules = []*config.Rule{{
+		Name:    "lists",
+		Match:   &config.Match{Header: map[string]string{"List-Id": "golang-nuts"}},
+		Actions: []*config.Action{{Action: "move", Mailbox: "Lists"}},
+	}}
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		Profiles:    []*config.Profile{src},
+	}
+	mockTerm.SetLines([]string{
+		"",
+		"jane@gmail.com",
+		"",
+		"imapsecret",
+		"",
+		"smtpsecret",
+	})
+	err := CloneProfile("Work", "Jane", cfg)
+	if err != nil {
+		t.Fatalf("cannot clone profile: %v", err)
+	}
+	want := work()
+	want.Name, want.Email, want.ImapUser, want.SmtpUser = "Jane", "jane@gmail.com", "jane@gmail.com", "jane@gmail.com"
+	want.Folders = []*config.Folder{
+		{Name: "inbox", Remote: "INBOX", Local: "INBOX", Expunge: "Both"},
+	}
+	want.Include = []string{"Projects/*"}
+	want.Mbsync = &config.Mbsync{MaxMessages: 1000}
+	want.Rules = []*config.Rule{{
+		Name:    "lists",
+		Match:   &config.Match{Header: map[string]string{"List-Id": "golang-nuts"}},
+		Actions: []*config.Action{{Action: "move", Mailbox: "Lists"}},
+	}}
+	if len(cfg.Profiles) != 2 || !reflect.DeepEqual(cfg.Profiles[1], want) {
+		t.Fatalf("got: %+v, want: %+v", cfg.Profiles[len(cfg.Profiles)-1], want)
+	}
+	clone := cfg.Profiles[1]
+	clone.Folders[0].Expunge = "None"
+	clone.Include[0] = "Archive/*"
+	clone.Mbsync.MaxMessages = 10
+	clone.Rules[0].Match.Header["List-Id"] = "golang-dev"
+	clone.Rules[0].Actions[0].Mailbox = "Dev"
+	if src.Folders[0].Expunge != "Both" || src.Include[0] != "Projects/*" || src.Mbsync.MaxMessages != 1000 ||
+		src.Rules[0].Match.Header["List-Id"] != "golang-nuts" || src.Rules[0].Actions[0].Mailbox != "Lists" {
+		t.Fatalf("editing the clone changed the source profile: %+v", src)
+	}
+	pwd, err := cred.New().Get("jane@gmail.com", "imap", "imap.gmail.com", 993)
+	if err != nil || pwd != "imapsecret" {
+		t.Fatalf("got imap password %q (%v), want: imapsecret", pwd, err)
+	}
+}
+
+func TestDisableProfile(t *testing.T) {
+	setup()
+	defer restore()
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{work(), home},
+	}
+	read := func(file string) string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("missing %s", file)
+		}
+		return string(data)
+	}
+
+	err := DisableProfile("Work", true, cfg)
+	if err != nil {
+		t.Fatalf("cannot disable profile: %v", err)
+	}
+	if !cfg.Profiles[0].Disabled || !cfg.Profiles[0].ReadOnly {
+		t.Fatalf("profile not disabled: %+v", cfg.Profiles[0])
+	}
+	mbsyncrc := read("/home/user/.mbsyncrc")
+	if strings.Contains(mbsyncrc, "Group Work") || !strings.Contains(mbsyncrc, "Channel Work-inbox") || !strings.Contains(mbsyncrc, "Group Home") {
+		t.Fatalf("disabled profile still synced:\n%s", mbsyncrc)
+	}
+	if got, want := read("/home/user/.local/bin/syncmail.sh"), "\nmbsync Home\nimapfilter\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got syncmail.sh: %s, want it to end with: %s", got, want)
+	}
+	if !strings.Contains(read("/home/user/.emacs.d/mu4e.el"), `(user-error "Work is read-only: mail cannot be sent")`) {
+		t.Fatalf("mu4e context of read-only profile can send mail")
+	}
+
+	err = EnableProfile("Work", cfg)
+	if err != nil {
+		t.Fatalf("cannot enable profile: %v", err)
+	}
+	if cfg.Profiles[0].Disabled || cfg.Profiles[0].ReadOnly {
+		t.Fatalf("profile not enabled: %+v", cfg.Profiles[0])
+	}
+	if got, want := read("/home/user/.local/bin/syncmail.sh"), "\nmbsync Work Home\nimapfilter\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got syncmail.sh: %s, want it to end with: %s", got, want)
+	}
+
+	err = EnableProfile("Job", cfg)
+	if err != ErrProfileNotFound {
+		t.Fatalf("got error %v, want: %v", err, ErrProfileNotFound)
+	}
+}
+
+func TestEditProfile(t *testing.T) {
+	setup()
+	defer restore()
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	client := mockImap
+	client.SetMailboxes("user@gmail.com", "imap.gmail.com", 993, []string{
+		"Clients", "INBOX", "Lists/golang-nuts", "Projects/Alpha", "[Gmail]/Bin", "[Gmail]/Sent Mail", "email-archive",
+	}, nil)
+	cred.New().Add("user@gmail.com", "imap", "imap.gmail.com", 993, "secret")
+	p := work()
+	p.Include = []string{"Projects/*", "Clients"}
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{p},
+	}
+
+	mockTerm.SetLines([]string{"4", "2,1 2"})
+	err := EditProfile("Work", cfg)
+	if err != nil {
+		t.Fatalf("cannot edit profile: %v", err)
+	}
+	if want := []string{"Projects/*", "Lists/golang-nuts", "Clients"}; !reflect.DeepEqual(p.Include, want) {
+		t.Fatalf("got include %q, want: %q", p.Include, want)
+	}
+	if got := client.Listed(); len(got) != 1 || got[0].Password != "secret" || got[0].SSL != "IMAPS" {
+		t.Fatalf("got listed accounts %+v, want the Work account", got)
+	}
+	data, err := os.ReadFile("/home/user/.mbsyncrc")
+	if err != nil {
+		t.Fatalf("missing .mbsyncrc: %v", err)
+	}
+	if want := `Patterns "Projects/*" "Lists/golang-nuts" "Clients" "!INBOX"`; !strings.Contains(string(data), want) {
+		t.Fatalf("got .mbsyncrc:\n%s\nwant it to contain: %s", data, want)
+	}
+
+	mockTerm.SetLines([]string{""})
+	err = EditProfile("Work", cfg)
+	if err != nil {
+		t.Fatalf("cannot edit profile: %v", err)
+	}
+	if want := []string{"Projects/*", "Clients", "Lists/golang-nuts"}; !reflect.DeepEqual(p.Include, want) {
+		t.Fatalf("got include %q, want: %q", p.Include, want)
+	}
+
+	mockTerm.SetLines([]string{"-"})
+	err = EditProfile("Work", cfg)
+	if err != nil {
+		t.Fatalf("cannot edit profile: %v", err)
+	}
+	if want := []string{"Projects/*"}; !reflect.DeepEqual(p.Include, want) {
+		t.Fatalf("got include %q, want: %q", p.Include, want)
+	}
+
+	err = EditProfile("Job", cfg)
+	if err != ErrProfileNotFound {
+		t.Fatalf("got error %v, want: %v", err, ErrProfileNotFound)
+	}
+}
+
+func TestTryFilters(t *testing.T) {
+	setup()
+	defer restore()
+	out, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatalf("cannot create stdout: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = oldStdout }()
+	os.WriteFile("/sample/cur/1", []byte("From: Boss <boss@example.com>\nSubject: Weekly report\n\nNumbers.\n"), 0644)
+	os.WriteFile("/sample/cur/2", []byte("From: Jane <jane@example.com>\nSubject: Lunch?\n\nAt noon.\n"), 0644)
+	p := work()
+	p.Rules = []*config.Rule{{
+		Name:    "reports",
+		Match:   &config.Match{From: "boss@example.com"},
+		Actions: []*config.Action{{Action: "flag"}, {Action: "move", Mailbox: "Reports"}},
+	}}
+	cfg := &config.Config{Profiles: []*config.Profile{p}}
+
+	err = TryFilters("Work", "INBOX", "/sample", cfg)
+	if err != nil {
+		t.Fatalf("cannot try the rules: %v", err)
+	}
+	got, _ := ioutil.ReadFile(out.Name())
+	want := "cur/1: \"Weekly report\" from Boss <boss@example.com>\n\treports: flag, move Reports\n" +
+		"cur/2: \"Lunch?\" from Jane <jane@example.com>\n\tno rule matches\n"
+	if string(got) != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+
+	err = TryFilters("Job", "INBOX", "/sample", cfg)
+	if err != ErrProfileNotFound {
+		t.Fatalf("got error %v, want: %v", err, ErrProfileNotFound)
+	}
+}
+
+func newCertificate(t *testing.T, cn string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCheck(t *testing.T) {
+	setup()
+	defer restore()
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	servers := func() []string {
+		stored, err := certs.Read()
+		if err != nil {
+			t.Fatalf("cannot read the certificates: %v", err)
+		}
+		var got []string
+		for _, e := range stored {
+			got = append(got, e.Server)
+		}
+		return got
+	}
+	// a self-signed certificate not valid for the name of the server,
+	// which only the certificates file makes imapfilter accept.
+	gmail := newCertificate(t, "mx.internal")
+	mockImap.SetCertificate("imap.gmail.com", 993, gmail)
+	certs.Write([]*certs.Entry{
+		{Server: "imap.old.org:993", Cert: newCertificate(t, "imap.old.org")},
+		{Cert: newCertificate(t, "imap.own.org")},
+	})
+	cfg := &config.Config{EmacsCfgDir: "/home/user/.emacs.d", BinDir: "/home/user/.local/bin"}
+
+	mockTerm.SetLines([]string{"John Doe", "jdoe@gmail.com", "imap.gmail.com", "993", "user@gmail.com", "secret",
+		"smtp.gmail.com", "587", "user@gmail.com", "secret", "n"})
+	err := AddProfile("Work", cfg)
+	if err != ErrCertificateRejected || len(cfg.Profiles) != 0 {
+		t.Fatalf("got error %v and %d profiles, want: %v and none", err, len(cfg.Profiles), ErrCertificateRejected)
+	}
+	// the credentials stored by the rejected add are kept.
+	mockTerm.SetLines([]string{"John Doe", "jdoe@gmail.com", "imap.gmail.com", "993", "user@gmail.com", "secret", "n",
+		"smtp.gmail.com", "587", "user@gmail.com", "secret", "n", "y"})
+	err = AddProfile("Work", cfg)
+	if err != nil {
+		t.Fatalf("cannot add profile: %v", err)
+	}
+	if got, want := servers(), []string{"imap.old.org:993", "", "imap.gmail.com:993"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got certificates of %q, want: %q", got, want)
+	}
+
+	// no answer: the certificate is not asked about again.
+	mockTerm.SetLines(nil)
+	err = Check(cfg)
+	if err != nil {
+		t.Fatalf("cannot check: %v", err)
+	}
+	if got, want := servers(), []string{"", "imap.gmail.com:993"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got certificates of %q, want the stale one removed: %q", got, want)
+	}
+
+	renewed := newCertificate(t, "mx.internal")
+	mockImap.SetCertificate("imap.gmail.com", 993, renewed)
+	mockTerm.SetLines([]string{"n"})
+	err = Check(cfg)
+	if err != nil {
+		t.Fatalf("cannot check: %v", err)
+	}
+	if stored, _ := certs.Read(); !stored[1].Cert.Equal(gmail) {
+		t.Fatalf("rejected certificate replaced the trusted one")
+	}
+	mockTerm.SetLines([]string{"y"})
+	err = Check(cfg)
+	if err != nil {
+		t.Fatalf("cannot check: %v", err)
+	}
+	if stored, _ := certs.Read(); len(stored) != 2 || !stored[1].Cert.Equal(renewed) {
+		t.Fatalf("certificate of imap.gmail.com not replaced")
+	}
+
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	err = forgetCertificate(cfg.Profiles[0], &config.Config{Profiles: []*config.Profile{home}})
+	if err != nil {
+		t.Fatalf("cannot forget the certificate: %v", err)
+	}
+	if got := servers(); len(got) != 2 {
+		t.Fatalf("removed the certificate of a server still used")
+	}
+	err = forgetCertificate(home, &config.Config{})
+	if err != nil {
+		t.Fatalf("cannot forget the certificate: %v", err)
+	}
+	if got, want := servers(), []string{""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got certificates of %q, want: %q", got, want)
+	}
+}
+
+func TestSetSyncInterval(t *testing.T) {
+	setup()
+	defer restore()
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{work(), home},
+	}
+	read := func(file string) string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("missing %s", file)
+		}
+		return string(data)
+	}
+
+	err := SetSyncInterval("Home", 900, cfg)
+	if err != nil {
+		t.Fatalf("cannot set interval: %v", err)
+	}
+	if !strings.Contains(read("/home/user/.config/systemd/user/mbsync.timer"), "OnUnitInactiveSec=5m\n") {
+		t.Fatalf("shared timer does not use the shared interval")
+	}
+	if !strings.Contains(read("/home/user/.config/systemd/user/mbsync@Home.timer"), "OnUnitInactiveSec=15m\n") {
+		t.Fatalf("profile timer does not use the profile interval")
+	}
+	if !strings.Contains(read("/home/user/.config/systemd/user/mbsync@.service"), "syncmail.sh %I\n") {
+		t.Fatalf("profile service does not sync the profile")
+	}
+	if got, want := read("/home/user/.local/bin/syncmail.sh"), "\nmbsync Work\nimapfilter\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got syncmail.sh: %s, want it to end with: %s", got, want)
+	}
+
+	err = SetSyncInterval("", 900, cfg)
+	if err != nil {
+		t.Fatalf("cannot set interval: %v", err)
+	}
+	if cfg.OwnSchedule(cfg.Profiles[1]) {
+		t.Fatalf("profile with the shared interval has its own schedule")
+	}
+	if !strings.Contains(read("/home/user/.config/systemd/user/mbsync.timer"), "OnUnitInactiveSec=15m\n") {
+		t.Fatalf("shared timer does not use the new interval")
+	}
+	if !strings.Contains(read("/home/user/.emacs.d/mu4e.el"), "mu4e-update-interval 900\n") {
+		t.Fatalf("mu4e does not use the new interval")
+	}
+	if got, want := read("/home/user/.local/bin/syncmail.sh"), "\nmbsync Work Home\nimapfilter\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got syncmail.sh: %s, want it to end with: %s", got, want)
+	}
+
+	err = SetSyncInterval("Work", 30, cfg)
+	if _, ok := err.(config.ValidationError); !ok {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+	if cfg.Profiles[0].SyncInterval != 0 {
+		t.Fatalf("invalid interval saved: %d", cfg.Profiles[0].SyncInterval)
+	}
+	err = SetSyncInterval("Job", 600, cfg)
+	if err != ErrProfileNotFound {
+		t.Fatalf("got error %v, want: %v", err, ErrProfileNotFound)
+	}
+}
+
+func TestSetPerProfileSync(t *testing.T) {
+	setup()
+	defer restore()
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	home := work()
+	home.Name, home.Email, home.ImapUser = "Home", "jdoe@home.org", "jdoe@home.org"
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{work(), home},
+	}
+	read := func(file string) string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("missing %s", file)
+		}
+		return string(data)
+	}
+
+	err := SetPerProfileSync(true, cfg)
+	if err != nil {
+		t.Fatalf("cannot set per-profile sync: %v", err)
+	}
+	if cfg.SharedSchedule() {
+		t.Fatalf("profiles still synced by the shared service")
+	}
+	for _, p := range cfg.Profiles {
+		if !strings.Contains(read("/home/user/.config/systemd/user/mbsync@"+p.Name+".timer"), "OnUnitInactiveSec=5m\n") {
+			t.Fatalf("%s: timer does not use the shared interval", p.Name)
+		}
+		lua := read("/home/user/.imapfilter/profiles/" + p.Name + ".lua")
+		for _, other := range cfg.Profiles {
+			if got, want := strings.Contains(lua, `username = "`+other.ImapUser+`"`), other == p; got != want {
+				t.Fatalf("%s: imapfilter config filters %s: %v, want: %v", p.Name, other.Name, got, want)
+			}
+		}
+	}
+	if got := read("/home/user/.local/bin/syncmail.sh"); !strings.Contains(got, "# no profile is synced by the shared timer.") {
+		t.Fatalf("got syncmail.sh: %s, want no shared sync", got)
+	}
+
+	err = SetPerProfileSync(false, cfg)
+	if err != nil {
+		t.Fatalf("cannot set shared sync: %v", err)
+	}
+	if got, want := read("/home/user/.local/bin/syncmail.sh"), "\nmbsync Work Home\nimapfilter\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got syncmail.sh: %s, want it to end with: %s", got, want)
+	}
+}
+
+func TestGenerateHandWrittenMsmtp(t *testing.T) {
+	setup()
+	defer restore()
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	p := work()
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{p},
+	}
+	own := "account personal\nhost smtp.example.org\n"
+	os.WriteFile("/home/user/.config/msmtp/config", []byte(own), 0600)
+
+	mockTerm.SetLines([]string{"n"})
+	err := Generate(cfg, p)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	if got, _ := os.ReadFile("/home/user/.config/msmtp/config"); string(got) != own {
+		t.Fatalf("got msmtp config: %s, want the one written by hand kept", got)
+	}
+
+	mockTerm.SetLines([]string{"y"})
+	err = Generate(cfg, p)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	if got, _ := os.ReadFile("/home/user/.config/msmtp/config"); !strings.Contains(string(got), "account Work\n") {
+		t.Fatalf("got msmtp config: %s, want the generated one", got)
+	}
+	if got, _ := os.ReadFile("/home/user/.config/msmtp/config.pre-mailconf"); string(got) != own {
+		t.Fatalf("got backup: %s, want: %s", got, own)
+	}
+
+	// the configs written by mailconf are replaced without asking.
+	mockTerm.SetLines(nil)
+	p.SmtpPort = 465
+	err = Generate(cfg, p)
+	if err != nil {
+		t.Fatalf("cannot generate: %v", err)
+	}
+	if got, _ := os.ReadFile("/home/user/.config/msmtp/config"); !strings.Contains(string(got), "port 465\n") {
+		t.Fatalf("got msmtp config: %s, want it updated", got)
+	}
+
+	// a config written by hand is asked about once, not also as a
+	// modified configuration.
+	os.WriteFile("/home/user/.config/msmtp/config", []byte(own), 0600)
+	mockTerm.SetLines([]string{"y"})
+	err = SetUseMsmtp(true, cfg)
+	if err != nil {
+		t.Fatalf("cannot use msmtp: %v", err)
+	}
+	if got, _ := os.ReadFile("/home/user/.config/msmtp/config"); !strings.Contains(string(got), "account Work\n") {
+		t.Fatalf("got msmtp config: %s, want the generated one", got)
+	}
+}
+
+func TestSetUseMsmtp(t *testing.T) {
+	setup()
+	defer restore()
+	os.System = "linux"
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+	home := work()
+	home.Name, home.Email, home.SmtpHost, home.SmtpPort, home.SmtpUser = "Home", "jdoe@home.org", "mail.home.org", 465, "jdoe"
+	archive := work()
+	archive.Name, archive.Email, archive.ReadOnly = "Archive", "old@gmail.com", true
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		BinDir:      "/home/user/.local/bin",
+		Profiles:    []*config.Profile{archive, work(), home},
+	}
+	read := func(file string) string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("missing %s", file)
+		}
+		return string(data)
+	}
+
+	err := SetUseMsmtp(true, cfg)
+	if err != nil {
+		t.Fatalf("cannot use msmtp: %v", err)
+	}
+	want := `# msmtp config generated by mailconf: changes are overwritten.
+defaults
+auth on
+tls on
+logfile ~/.msmtp.log
+
+account Work
+host smtp.gmail.com
+port 587
+tls_starttls on
+from jdoe@gmail.com
+user user@gmail.com
+passwordeval secret-tool lookup user user@gmail.com host smtp.gmail.com service smtp port 587
+
+account Home
+host mail.home.org
+port 465
+tls_starttls off
+from jdoe@home.org
+user jdoe
+passwordeval secret-tool lookup user jdoe host mail.home.org service smtp port 465
+
+account default : Work
+`
+	if got := read("/home/user/.config/msmtp/config"); got != want {
+		t.Fatalf("got msmtp config: %s, want: %s", got, want)
+	}
+	mu4e := read("/home/user/.emacs.d/mu4e.el")
+	if !strings.Contains(mu4e, `(setq sendmail-program (executable-find "msmtp")`) || strings.Contains(mu4e, "'smtpmail-send-it") {
+		t.Fatalf("mu4e does not send mail with msmtp:\n%s", mu4e)
+	}
+
+	err = SetUseMsmtp(false, cfg)
+	if err != nil {
+		t.Fatalf("cannot use smtpmail: %v", err)
+	}
+	mu4e = read("/home/user/.emacs.d/mu4e.el")
+	if strings.Contains(mu4e, "msmtp") || !strings.Contains(mu4e, "'smtpmail-send-it") {
+		t.Fatalf("mu4e does not send mail with smtpmail:\n%s", mu4e)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	home.Identities = []*config.Identity{{Email: "john@home.org"}}
+	tt := []struct {
+		name     string
+		frontend string
+		rebuild  bool
+		want     []string
+	}{
+		{
+			"index",
+			"",
+			false,
+			[]string{"mu index"},
+		},
+		{
+			"rebuild",
+			"mu4e",
+			true,
+			[]string{
+				"mu init --maildir /home/user/Maildir --my-address jdoe@gmail.com --my-address jdoe@home.org --my-address john@home.org",
+				"mu index",
+			},
+		},
+		{
+			"notmuch",
+			"notmuch",
+			true,
+			[]string{"notmuch new"},
+		},
+	}
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		cfg := &config.Config{
+			Frontend: tc.frontend,
+			Profiles: []*config.Profile{work(), home},
+		}
+		err := Index(tc.rebuild, cfg)
+		if err != nil {
+			t.Fatalf("%s: cannot index: %v", tc.name, err)
+		}
+		if got := mockExec.Ran(); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got commands %q, want: %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMoveMaildir(t *testing.T) {
+	tt := []struct {
+		name    string
+		root    string
+		newroot string
+		exists  string
+		want    string
+		err     error
+	}{
+		{
+			"move",
+			"",
+			"/home/user/Mail",
+			"",
+			"/home/user/Mail",
+			nil,
+		},
+		{
+			"default",
+			"/home/user/Mail",
+			"/home/user/Maildir",
+			"",
+			"",
+			nil,
+		},
+		{
+			"exists",
+			"",
+			"/home/user/Mail",
+			"/home/user/Mail/old",
+			"",
+			fs.ErrExist,
+		},
+		{
+			"nested",
+			"",
+			"/home/user/Maildir/new",
+			"",
+			"",
+			ErrMaildirNested,
+		},
+		{
+			"tilde",
+			"",
+			"~/Mail",
+			"",
+			"/home/user/Mail",
+			nil,
+		},
+		{
+			"relative",
+			"",
+			"Mail",
+			"",
+			"",
+			ErrMaildirRelative,
+		},
+	}
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		cfg := &config.Config{
+			EmacsCfgDir: "/home/user/.emacs.d",
+			MaildirRoot: tc.root,
+			Profiles:    []*config.Profile{work(), work()},
+		}
+		home := cfg.Profiles[1]
+		home.Name, home.Email = "Home", "jdoe@home.org"
+		oldroot := cfg.MaildirRootPath()
+		home.Maildir = path.Join(oldroot, "archive", "home")
+		for _, file := range []string{
+			path.Join(oldroot, "Work", "INBOX", "cur", "1"),
+			path.Join(oldroot, "archive", "home", "INBOX", "cur", "2"),
+			path.Join(tc.exists, "INBOX", "cur", "3"),
+		} {
+			os.MkdirAll(path.Dir(file), 0755)
+			os.WriteFile(file, []byte("mail"), 0644)
+		}
+
+		err := MoveMaildir(tc.newroot, cfg)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if tc.err != nil {
+			if cfg.MaildirRoot != tc.root || home.Maildir != path.Join(oldroot, "archive", "home") {
+				t.Fatalf("%s: config changed: %q, %q", tc.name, cfg.MaildirRoot, home.Maildir)
+			}
+			if _, err := os.ReadFile(path.Join(oldroot, "Work", "INBOX", "cur", "1")); err != nil {
+				t.Fatalf("%s: mail moved: %v", tc.name, err)
+			}
+			continue
+		}
+		if cfg.MaildirRoot != tc.want {
+			t.Fatalf("%s: got maildir root %q, want: %q", tc.name, cfg.MaildirRoot, tc.want)
+		}
+		newroot := cfg.MaildirRootPath()
+		if want := path.Join(newroot, "archive", "home"); home.Maildir != want {
+			t.Fatalf("%s: got maildir %q, want: %q", tc.name, home.Maildir, want)
+		}
+		// the in-memory file system renames the directory alone.
+		if _, err := os.ReadDir(newroot); err != nil {
+			t.Fatalf("%s: maildir root not moved: %v", tc.name, err)
+		}
+		if _, err := os.ReadDir(oldroot); err == nil {
+			t.Fatalf("%s: old maildir root left behind", tc.name)
+		}
+		mbsyncrc, _ := os.ReadFile("/home/user/.mbsyncrc")
+		want := "Path " + strings.Replace(newroot, "/home/user", "~", 1) + "/Work/"
+		if !strings.Contains(string(mbsyncrc), want) {
+			t.Fatalf("%s: mbsyncrc not regenerated, want %s:\n%s", tc.name, want, mbsyncrc)
+		}
+		init := "mu init --maildir " + newroot + " --my-address jdoe@gmail.com --my-address jdoe@home.org"
+		if got := mockExec.Ran(); len(got) == 0 || got[0] != init {
+			t.Fatalf("%s: got commands %q, want: %q first", tc.name, got, init)
+		}
+	}
+}
+
+// otherFs is a file system where renaming from fails with err and
+// writing files in broken, or holding refused, fails, as when moving to
+// another file system.
+type otherFs struct {
+	*afero.Afero
+	from    string
+	err     error
+	broken  string
+	refused string
+}
+
+func (f *otherFs) Rename(from, to string) error {
+	if from == f.from {
+		return &fs.PathError{Op: "rename", Path: from, Err: f.err}
+	}
+	return f.Afero.Rename(from, to)
+}
+
+func (f *otherFs) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if f.broken != "" && strings.HasPrefix(name, f.broken+"/") || f.refused != "" && strings.Contains(string(data), f.refused) {
+		return &fs.PathError{Op: "write", Path: name, Err: syscall.ENOSPC}
+	}
+	return f.Afero.WriteFile(name, data, perm)
+}
+
+func TestMoveMaildirAcrossFileSystems(t *testing.T) {
 	tt := []struct {
 		name   string
-		config *config.Config
 		err    error
+		broken bool
+		want   error
 	}{
 		{
-			"single",
-			&config.Config{
-				EmacsCfgDir: "/home/user/.emacs.d",
-				Profiles: []*config.Profile{
-					{
-						Name:     "Work",
-						FullName: "John Doe",
-						Email:    "jdoe@gmail.com",
-						ImapHost: "imap.gmail.com",
-						ImapPort: 993,
-						ImapUser: "user@gmail.com",
-						SmtpHost: "smtp.gmail.com",
-						SmtpPort: 587,
-						SmtpUser: "user@gmail.com",
-					},
-				},
-			},
+			"copy",
+			syscall.EXDEV,
+			false,
 			nil,
 		},
+		{
+			"copy fails",
+			syscall.EXDEV,
+			true,
+			syscall.ENOSPC,
+		},
+		{
+			"rename fails",
+			syscall.EACCES,
+			false,
+			syscall.EACCES,
+		},
 	}
 	for _, tc := range tt {
 		setup()
 		defer restore()
-		generatemu4e(tc.config, false)
-		got, err := os.ReadFile(path.Join(tc.config.EmacsCfgDir, "mu4e.el"))
-		if err != nil {
-			t.Fatalf("file not saved: %v", err)
+		os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		oldroot, newroot := "/home/user/Maildir", "/mnt/mail/Maildir"
+		ofs := &otherFs{
+			Afero: &afero.Afero{Fs: afero.NewMemMapFs()},
+			from:  oldroot,
+			err:   tc.err,
+		}
+		if tc.broken {
+			ofs.broken = newroot
+		}
+		os.Set(ofs)
+		cfg := &config.Config{
+			EmacsCfgDir: "/home/user/.emacs.d",
+			Profiles:    []*config.Profile{work()},
+		}
+		mail := map[string]string{
+			"Work/INBOX/cur/1":   "first",
+			"Work/Archive/cur/2": "second",
+			"Work/.mbsyncstate":  "state",
+		}
+		for name, data := range mail {
+			file := path.Join(oldroot, name)
+			os.MkdirAll(path.Dir(file), 0755)
+			os.WriteFile(file, []byte(data), 0644)
+		}
+
+		err := MoveMaildir(newroot, cfg)
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.want)
+		}
+		root := newroot
+		if tc.want != nil {
+			root = oldroot
+			if cfg.MaildirRoot != "" || cfg.Profiles[0].Maildir != "" {
+				t.Fatalf("%s: config changed: %q, %q", tc.name, cfg.MaildirRoot, cfg.Profiles[0].Maildir)
+			}
+			if _, err := os.ReadDir(newroot); err == nil {
+				t.Fatalf("%s: partial copy left in %s", tc.name, newroot)
+			}
+			for _, s := range []service.Service{service.NewMbsync(cfg), service.NewImapnotify(cfg, cfg.Profiles[0])} {
+				if got := s.Status(); got != service.EnabledRunning {
+					t.Fatalf("%s: got service status %v, want: %v", tc.name, got, service.EnabledRunning)
+				}
+			}
+		} else if _, err := os.ReadDir(oldroot); err == nil {
+			t.Fatalf("%s: old maildir root left behind", tc.name)
+		}
+		for name, want := range mail {
+			got, err := os.ReadFile(path.Join(root, name))
+			if err != nil || string(got) != want {
+				t.Fatalf("%s: got %s %q, %v, want: %q", tc.name, name, got, err, want)
+			}
+		}
+	}
+}
+
+func TestActivateSet(t *testing.T) {
+	setup()
+	defer restore()
+	defer config.SetLocation("")
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	os.UserHomeDir = func() (string, error) { return "/home/user", nil }
 
+	// the files were generated from the set home, with the profile Home.
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
+	old := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		Profiles:    []*config.Profile{home},
+	}
+	config.SetLocation("home")
+	err := old.Save()
+	if err != nil {
+		t.Fatalf("cannot save the active config: %v", err)
+	}
+	err = Generate(old, home)
+	if err != nil {
+		t.Fatalf("cannot generate the active config: %v", err)
+	}
+
+	config.SetLocation("work")
+	cfg := &config.Config{
+		EmacsCfgDir: "/home/user/.emacs.d",
+		Profiles:    []*config.Profile{work()},
+		Mu4e:        &config.Mu4e{},
+	}
+	err = cfg.Save()
+	if err != nil {
+		t.Fatalf("cannot save config: %v", err)
+	}
+	err = AddProfile("Office", cfg)
+	if !errors.Is(err, config.ErrInactive) {
+		t.Fatalf("got error %v, want: %v", err, config.ErrInactive)
+	}
+
+	err = ActivateSet(cfg)
+	if err != nil {
+		t.Fatalf("cannot activate config: %v", err)
+	}
+	if err := config.CheckActive(); err != nil {
+		t.Fatalf("config not activated: %v", err)
+	}
+	if got := service.NewImapnotify(old, home).Status(); got != service.DisabledStopped {
+		t.Fatalf("got imapnotify status of Home %v, want: %v", got, service.DisabledStopped)
+	}
+	if _, err := os.ReadFile("/home/user/.config/imapnotify/Work/notify.conf"); err != nil {
+		t.Fatalf("imapnotify config of Work not generated: %v", err)
+	}
+	mbsyncrc, _ := os.ReadFile("/home/user/.mbsyncrc")
+	if !strings.Contains(string(mbsyncrc), "Channel Work-inbox") || strings.Contains(string(mbsyncrc), "Home") {
+		t.Fatalf("mbsyncrc not generated from the activated config:\n%s", mbsyncrc)
+	}
+}
+
+func TestImportProfiles(t *testing.T) {
+	tt := []struct {
+		name    string
+		refused string
+		err     error
+	}{
+		{
+			"imported",
+			"",
+			nil,
+		},
+		{
+			"generation fails",
+			"jdoe@office.org",
+			syscall.ENOSPC,
+		},
+	}
+	for _, tc := range tt {
+		setup()
+		defer restore()
+		os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+		os.UserHomeDir = func() (string, error) { return "/home/user", nil }
+		os.Set(&otherFs{Afero: &afero.Afero{Fs: afero.NewMemMapFs()}, refused: tc.refused})
+		cfg := &config.Config{
+			EmacsCfgDir: "/home/user/.emacs.d",
+			Profiles:    []*config.Profile{work()},
+		}
+		home, office := work(), work()
+		home.Name, home.Email = "Home", "jdoe@home.org"
+		office.Name, office.Email = "Office", "jdoe@office.org"
+
+		err := ImportProfiles([]*config.Profile{home, office}, cfg, false)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		mbsyncrc, _ := os.ReadFile("/home/user/.mbsyncrc")
+		if tc.err == nil {
+			if len(cfg.Profiles) != 3 || !strings.Contains(string(mbsyncrc), "Channel Office-inbox") {
+				t.Fatalf("%s: profiles not imported: %d profiles\n%s", tc.name, len(cfg.Profiles), mbsyncrc)
+			}
+			continue
 		}
-		want := fixture("/generatemu4e/" + tc.name + "/mu4e.el")
-		if !reflect.DeepEqual(want, got) {
-			t.Fatalf("%s: got: %s, want: %s", tc.name, got, want)
+		if len(cfg.Profiles) != 1 || cfg.Profiles[0].Name != "Work" {
+			t.Fatalf("%s: config changed: %d profiles", tc.name, len(cfg.Profiles))
 		}
+		// the files are generated again from the config.
+		if strings.Contains(string(mbsyncrc), "Home") || !strings.Contains(string(mbsyncrc), "Channel Work-inbox") {
+			t.Fatalf("%s: generated files do not match the config:\n%s", tc.name, mbsyncrc)
+		}
+		if got := service.NewImapnotify(cfg, home).Status(); got != service.DisabledStopped {
+			t.Fatalf("%s: got imapnotify status of Home %v, want: %v", tc.name, got, service.DisabledStopped)
+		}
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	setup()
+	defer restore()
+	os.UserConfigDir = func() (string, error) { return "/home/user/.config", nil }
+	cfg := &config.Config{Profiles: []*config.Profile{work()}}
+	home := work()
+	home.Name, home.Email = "Home", "jdoe@home.org"
 
+	for _, tc := range []struct {
+		name     string
+		profiles []*config.Profile
+		force    bool
+		err      error
+	}{
+		{"new", []*config.Profile{home}, false, nil},
+		{"exists", []*config.Profile{home, work()}, false, ErrProfileExists},
+		{"replace", []*config.Profile{home, work()}, true, nil},
+		{"twice", []*config.Profile{home, home}, true, ErrProfileExists},
+	} {
+		err := CheckImport(tc.profiles, cfg, tc.force)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: got error %v, want: %v", tc.name, err, tc.err)
+		}
+		if len(cfg.Profiles) != 1 {
+			t.Fatalf("%s: config changed: %d profiles", tc.name, len(cfg.Profiles))
+		}
 	}
 }
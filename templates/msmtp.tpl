@@ -0,0 +1,16 @@
+# msmtp config generated by mailconf: changes are overwritten.
+defaults
+auth on
+tls on
+logfile ~/.msmtp.log
+{{ $default := "" }}{{ range $Profile := .Profiles }}{{ if not $Profile.ReadOnly }}{{ if not $default }}{{ $default = $Profile.Name }}{{ end }}
+account {{ $Profile.Name }}
+host {{ $Profile.SmtpHost }}
+port {{ $Profile.SmtpPort }}
+tls_starttls {{ if eq $Profile.SmtpPort 465 }}off{{ else }}on{{ end }}
+from {{ $Profile.Email }}
+user {{ $Profile.SmtpUser }}
+passwordeval {{ $.PassCmd "smtp" $Profile }}
+{{ end }}{{ end }}{{ if $default }}
+account default : {{ $default }}
+{{ end }}
\ No newline at end of file